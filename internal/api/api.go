@@ -9,36 +9,162 @@ import (
 type controller interface {
 	authController
 	documentsController
+	adminController
+	groupsController
 }
 
 type authController interface {
 	Register(ctx *fiber.Ctx) error
 	Auth(ctx *fiber.Ctx) error
 	Logout(ctx *fiber.Ctx) error
+	Refresh(ctx *fiber.Ctx) error
+	ChangePassword(ctx *fiber.Ctx) error
+	DeleteAccount(ctx *fiber.Ctx) error
+	GetSessions(ctx *fiber.Ctx) error
+	RevokeSession(ctx *fiber.Ctx) error
+	RevokeOtherSessions(ctx *fiber.Ctx) error
+	VerifyTwoFactor(ctx *fiber.Ctx) error
+	EnrollTOTP(ctx *fiber.Ctx) error
+	ConfirmTOTP(ctx *fiber.Ctx) error
+	DisableTOTP(ctx *fiber.Ctx) error
+	CreateAPIKey(ctx *fiber.Ctx) error
+	GetAPIKeys(ctx *fiber.Ctx) error
+	RevokeAPIKey(ctx *fiber.Ctx) error
+	OIDCLogin(ctx *fiber.Ctx) error
+	OIDCCallback(ctx *fiber.Ctx) error
+	BeginPasskeyRegistration(ctx *fiber.Ctx) error
+	FinishPasskeyRegistration(ctx *fiber.Ctx) error
+	GetPasskeys(ctx *fiber.Ctx) error
+	RemovePasskey(ctx *fiber.Ctx) error
+	BeginPasskeyLogin(ctx *fiber.Ctx) error
+	FinishPasskeyLogin(ctx *fiber.Ctx) error
+}
+
+type adminController interface {
+	CreateInviteCode(ctx *fiber.Ctx) error
+	GetInviteCodes(ctx *fiber.Ctx) error
+	RevokeInviteCode(ctx *fiber.Ctx) error
+	ResetUserPassword(ctx *fiber.Ctx) error
+	ResetUserTwoFactor(ctx *fiber.Ctx) error
+	DeleteUser(ctx *fiber.Ctx) error
+	GetUsers(ctx *fiber.Ctx) error
+	UpdateUser(ctx *fiber.Ctx) error
+	GetRoles(ctx *fiber.Ctx) error
+	LogoutUser(ctx *fiber.Ctx) error
+	GetLockouts(ctx *fiber.Ctx) error
+	ClearLockout(ctx *fiber.Ctx) error
+}
+
+type groupsController interface {
+	CreateGroup(ctx *fiber.Ctx) error
+	GetGroups(ctx *fiber.Ctx) error
+	GetGroup(ctx *fiber.Ctx) error
+	DeleteGroup(ctx *fiber.Ctx) error
+	SetGroupMember(ctx *fiber.Ctx) error
+	RemoveGroupMember(ctx *fiber.Ctx) error
 }
 
 type documentsController interface {
 	UploadDocument(ctx *fiber.Ctx) error
 	GetDocument(ctx *fiber.Ctx) error
 	GetDocuments(ctx *fiber.Ctx) error
+	UpdateDocument(ctx *fiber.Ctx) error
+	PatchDocument(ctx *fiber.Ctx) error
 	DeleteDocument(ctx *fiber.Ctx) error
+	GetDocumentVersions(ctx *fiber.Ctx) error
+	RestoreDocumentVersion(ctx *fiber.Ctx) error
+	GetDocumentGrants(ctx *fiber.Ctx) error
+	AddDocumentGrants(ctx *fiber.Ctx) error
+	RevokeDocumentGrants(ctx *fiber.Ctx) error
+	CreateShareLink(ctx *fiber.Ctx) error
+	GetShareLinks(ctx *fiber.Ctx) error
+	RevokeShareLink(ctx *fiber.Ctx) error
+	OpenShareLink(ctx *fiber.Ctx) error
 }
 
-func SetRoutes(app *fiber.App, controller controller) {
+func SetRoutes(app *fiber.App, controller controller, authorizer authorizer) {
 	app.Use(cors.New())
 	app.Use(recover.New(recover.Config{EnableStackTrace: true}))
 
 	api := app.Group("api")
+	auth := authMiddleware(authorizer)
+	passwordChanged := passwordChangedMiddleware()
+	session := sessionMiddleware()
 
 	api.Post("register", controller.Register)
 	api.Post("auth", controller.Auth)
+	api.Post("auth/password", auth, session, controller.ChangePassword)
+	api.Post("auth/2fa", controller.VerifyTwoFactor)
+	api.Post("auth/refresh", controller.Refresh)
+	api.Get("auth/oidc/login", controller.OIDCLogin)
+	api.Get("auth/oidc/callback", controller.OIDCCallback)
+	// Passkey login routes must be set before passkeys group to skip its auth middleware.
+	api.Post("auth/passkeys/login", controller.BeginPasskeyLogin)
+	api.Post("auth/passkeys/login/finish", controller.FinishPasskeyLogin)
+
+	// Sessions, TOTP, API keys, passkeys and account routes must be set before logout route to not be shadowed by it.
+	sessions := api.Group("auth/sessions", auth, session)
+	sessions.Get("", controller.GetSessions)
+	sessions.Delete("", controller.RevokeOtherSessions)
+	sessions.Delete("/:id", controller.RevokeSession)
+	totp := api.Group("auth/totp", auth, session)
+	totp.Post("", controller.EnrollTOTP)
+	totp.Post("/confirm", controller.ConfirmTOTP)
+	totp.Delete("", controller.DisableTOTP)
+	keys := api.Group("auth/keys", auth, session)
+	keys.Post("", controller.CreateAPIKey)
+	keys.Get("", controller.GetAPIKeys)
+	keys.Delete("/:id", controller.RevokeAPIKey)
+	passkeys := api.Group("auth/passkeys", auth, session)
+	passkeys.Post("/register", controller.BeginPasskeyRegistration)
+	passkeys.Post("/register/finish", controller.FinishPasskeyRegistration)
+	passkeys.Get("", controller.GetPasskeys)
+	passkeys.Delete("/:id", controller.RemovePasskey)
+	api.Delete("auth/account", auth, session, controller.DeleteAccount)
+
 	api.Delete("auth/:token", controller.Logout)
 
-	docs := api.Group("docs")
+	docs := api.Group("docs", auth, passwordChanged)
 	docs.Post("", controller.UploadDocument)
 	docs.Get("/:id", controller.GetDocument)
 	docs.Head("/:id", controller.GetDocument)
 	docs.Get("", controller.GetDocuments)
 	docs.Head("", controller.GetDocuments)
+	docs.Put("/:id", controller.UpdateDocument)
+	docs.Patch("/:id", controller.PatchDocument)
 	docs.Delete("/:id", controller.DeleteDocument)
+	docs.Get("/:id/versions", controller.GetDocumentVersions)
+	docs.Post("/:id/versions/:version/restore", controller.RestoreDocumentVersion)
+	docs.Get("/:id/grants", controller.GetDocumentGrants)
+	docs.Post("/:id/grants", controller.AddDocumentGrants)
+	docs.Delete("/:id/grants", controller.RevokeDocumentGrants)
+	docs.Post("/:id/links", controller.CreateShareLink)
+	docs.Get("/:id/links", controller.GetShareLinks)
+	docs.Delete("/:id/links/:link", controller.RevokeShareLink)
+
+	// Share links are opened without session, link token is the credential.
+	api.Get("links/:token", controller.OpenShareLink)
+	api.Post("links/:token", controller.OpenShareLink)
+
+	groups := api.Group("groups", auth, passwordChanged, session)
+	groups.Post("", controller.CreateGroup)
+	groups.Get("", controller.GetGroups)
+	groups.Get("/:name", controller.GetGroup)
+	groups.Delete("/:name", controller.DeleteGroup)
+	groups.Put("/:name/members/:login", controller.SetGroupMember)
+	groups.Delete("/:name/members/:login", controller.RemoveGroupMember)
+
+	admin := api.Group("admin", auth, passwordChanged, session)
+	admin.Post("invites", controller.CreateInviteCode)
+	admin.Get("invites", controller.GetInviteCodes)
+	admin.Delete("invites/:id", controller.RevokeInviteCode)
+	admin.Get("users", controller.GetUsers)
+	admin.Patch("users/:login", controller.UpdateUser)
+	admin.Delete("users/:login", controller.DeleteUser)
+	admin.Post("users/:login/password", controller.ResetUserPassword)
+	admin.Post("users/:login/logout", controller.LogoutUser)
+	admin.Delete("users/:login/totp", controller.ResetUserTwoFactor)
+	admin.Get("roles", controller.GetRoles)
+	admin.Get("lockouts", controller.GetLockouts)
+	admin.Delete("lockouts", controller.ClearLockout)
 }
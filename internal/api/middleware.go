@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+const authCookieName = "token"
+
+type authorizer interface {
+	AuthorizeUserByToken(ctx context.Context, token string) (bool, user.Principal, error)
+}
+
+// authMiddleware resolves principal by auth token once and puts it on request context.
+func authMiddleware(authorizer authorizer) fiber.Handler {
+	return func(fc *fiber.Ctx) error {
+		isAuthorized, principal, err := authorizer.AuthorizeUserByToken(fc.Context(), authToken(fc))
+		if errors.Is(err, user.ErrAuthTokenExpired) {
+			return fc.Status(http.StatusUnauthorized).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+				Code: apperrors.AuthTokenExpiredErrorCode,
+				Text: apperrors.AuthTokenExpiredErrorText,
+			}, nil, nil))
+		}
+		if errors.Is(err, user.ErrUserDisabled) {
+			return fc.Status(http.StatusForbidden).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+				Code: apperrors.UserDisabledErrorCode,
+				Text: apperrors.UserDisabledErrorText,
+			}, nil, nil))
+		}
+		if err != nil {
+			logger.Error("authorization error", slog.String("error", err.Error()))
+			return fc.Status(http.StatusInternalServerError).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+				Code: apperrors.AuthInternalErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil))
+		}
+		if !isAuthorized {
+			return fc.Status(http.StatusUnauthorized).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+				Code: apperrors.UnauthorizedErrorCode,
+				Text: apperrors.UnauthorizedErrorText,
+			}, nil, nil))
+		}
+
+		fc.SetUserContext(user.WithPrincipal(fc.UserContext(), principal))
+
+		return fc.Next()
+	}
+}
+
+// passwordChangedMiddleware rejects users who must change temporary password first.
+// Must be set after authMiddleware.
+func passwordChangedMiddleware() fiber.Handler {
+	return func(fc *fiber.Ctx) error {
+		principal, _ := user.PrincipalFromContext(fc.UserContext())
+		if principal.MustChangePassword {
+			return fc.Status(http.StatusForbidden).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+				Code: apperrors.PasswordChangeRequiredErrorCode,
+				Text: apperrors.PasswordChangeRequiredErrorText,
+			}, nil, nil))
+		}
+
+		return fc.Next()
+	}
+}
+
+// sessionMiddleware rejects principals authorized by API key, so keys can't manage
+// account, sessions, other keys or act as admin. Must be set after authMiddleware.
+func sessionMiddleware() fiber.Handler {
+	return func(fc *fiber.Ctx) error {
+		principal, _ := user.PrincipalFromContext(fc.UserContext())
+		if principal.APIKey != nil {
+			return fc.Status(http.StatusForbidden).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+				Code: apperrors.ForbiddenErrorCode,
+				Text: apperrors.APIKeyNotAllowedErrorText,
+			}, nil, nil))
+		}
+
+		return fc.Next()
+	}
+}
+
+// authToken looks for auth token in Authorization header, then in cookie,
+// then in legacy request body field.
+func authToken(fc *fiber.Ctx) string {
+	header := fc.Get(fiber.HeaderAuthorization)
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok && token != "" {
+		return strings.TrimSpace(token)
+	}
+
+	if token := fc.Cookies(authCookieName); token != "" {
+		return token
+	}
+
+	var legacy struct {
+		Token string `json:"token"`
+	}
+
+	// Multipart upload requests keep token inside meta field.
+	if strings.HasPrefix(fc.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		_ = json.Unmarshal([]byte(fc.FormValue("meta")), &legacy)
+		return legacy.Token
+	}
+
+	if len(fc.Body()) != 0 {
+		_ = json.Unmarshal(fc.Body(), &legacy)
+	}
+
+	return legacy.Token
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"log/slog"
 	"time"
@@ -19,11 +20,18 @@ import (
 type app struct {
 	app  *fiber.App
 	conn *sql.DB
+	// ctx is cancelled on shutdown to stop background jobs.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func New() *app {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &app{
-		app: fiber.New(),
+		app:    fiber.New(),
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
@@ -48,24 +56,41 @@ func (a *app) Run() error {
 	a.conn = conn
 
 	// TODO: Migrations to cfg.
-	err = database.Migrate(conn, "file://migrations", 7)
+	err = database.Migrate(conn, "file://migrations", 26)
 	if err != nil {
 		logger.Error("database migration error", slog.String("error", err.Error()))
 		return err
 	}
 
-	err = database.SeedAdminToken(conn, config.Cfg.Modules.Auth.AdminToken)
+	cache.Init(time.Duration(config.Cfg.Cache.Lifespan) * time.Second)
+
+	repository := repository.New(conn)
+	usecase, err := usecase.New(repository, config.Cfg.Modules.Auth, config.Cfg.Modules.Documents)
 	if err != nil {
-		logger.Error("SeedAdminToken error", slog.String("error", err.Error()))
+		logger.Error("usecase error while starting app", slog.String("error", err.Error()))
 		return err
 	}
 
-	cache.Init(time.Duration(config.Cfg.Cache.Lifespan) * time.Second)
+	err = usecase.EnsureArchiveUser(context.Background())
+	if err != nil {
+		logger.Error("archive user error", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = usecase.BootstrapAdminInviteCode(context.Background())
+	if err != nil {
+		logger.Error("admin bootstrap error", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = usecase.StartDenylistSync(a.ctx)
+	if err != nil {
+		logger.Error("denylist sync error", slog.String("error", err.Error()))
+		return err
+	}
 
-	repository := repository.New(conn)
-	usecase := usecase.New(repository)
 	controller := controller.New(usecase)
-	api.SetRoutes(a.app, controller)
+	api.SetRoutes(a.app, controller, usecase)
 
 	if err := a.app.Listen("0.0.0.0:3000"); err != nil {
 		logger.Error("Server listen error.", slog.String("error", err.Error()))
@@ -76,6 +101,8 @@ func (a *app) Run() error {
 }
 
 func (a *app) Shutdown() {
+	a.cancel()
+
 	err := database.Shutdown(a.conn)
 	if err != nil {
 		logger.Error("database shutdown error", slog.String("error", err.Error()))
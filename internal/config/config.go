@@ -20,11 +20,184 @@ type Cache struct {
 }
 
 type Modules struct {
-	Auth Auth `json:"auth"`
+	Auth      Auth      `json:"auth"`
+	Documents Documents `json:"documents"`
+}
+
+type Documents struct {
+	// VersionRetention is a number of newest content versions kept per document.
+	// Zero keeps all versions.
+	VersionRetention int `json:"versionRetention"`
+	// ShareLinkTTL is share link lifetime in seconds used when link is created without one.
+	ShareLinkTTL int `json:"shareLinkTTL"`
+	// ShareLinkMaxTTL is the longest share link lifetime in seconds.
+	ShareLinkMaxTTL int `json:"shareLinkMaxTTL"`
 }
 
 type Auth struct {
-	AdminToken string `json:"adminToken"`
+	// TokenTTL is auth token lifetime in seconds.
+	TokenTTL int `json:"tokenTTL"`
+	// TokenRenewalWindow is a period in seconds before token expiration
+	// in which token usage extends its lifetime by TokenTTL. Zero disables renewal.
+	TokenRenewalWindow int `json:"tokenRenewalWindow"`
+	// TokenPepper is a server secret for auth tokens HMAC. Plain SHA-256 is used if empty.
+	TokenPepper string `json:"tokenPepper"`
+	// ArchiveLogin is an account owning documents of deleted users in archive mode.
+	ArchiveLogin string `json:"archiveLogin"`
+	// DefaultRole is given to registered and provisioned users. Defaults to editor.
+	DefaultRole string  `json:"defaultRole"`
+	Lockout     Lockout `json:"lockout"`
+	// Empty policies fall back to built-in defaults.
+	LoginPolicy    LoginPolicy    `json:"loginPolicy"`
+	PasswordPolicy PasswordPolicy `json:"passwordPolicy"`
+	// PasswordHashing sets algorithm and parameters for new password hashes.
+	// Stored hashes made with other settings are rehashed on successful login.
+	PasswordHashing PasswordHashing `json:"passwordHashing"`
+	TwoFactor       TwoFactor       `json:"twoFactor"`
+	AccessTokens    AccessTokens    `json:"accessTokens"`
+	OIDC            OIDC            `json:"oidc"`
+	// Backends lists password auth backends tried in order: "local" and "ldap".
+	// Defaults to local only.
+	Backends []string `json:"backends"`
+	LDAP     LDAP     `json:"ldap"`
+	Passkeys Passkeys `json:"passkeys"`
+}
+
+// Passkeys configures WebAuthn registration and login.
+type Passkeys struct {
+	Enabled bool `json:"enabled"`
+	// RPID is a relying party ID, usually site domain without scheme and port.
+	RPID          string `json:"rpID"`
+	RPDisplayName string `json:"rpDisplayName"`
+	// RPOrigins are origins allowed to run ceremonies, like https://astral.example.com.
+	RPOrigins []string `json:"rpOrigins"`
+	// Timeout is a time in seconds to finish ceremony.
+	Timeout int `json:"timeout"`
+}
+
+// LDAP configures password auth by directory search and bind.
+type LDAP struct {
+	// URL is a server address like ldap://host:389 or ldaps://host:636.
+	URL                string `json:"url"`
+	StartTLS           bool   `json:"startTLS"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	// BindDN and BindPassword are service account to search users. Anonymous if empty.
+	BindDN       string `json:"bindDN"`
+	BindPassword string `json:"bindPassword"`
+	BaseDN       string `json:"baseDN"`
+	// UserFilter has %s placeholder for login, like (uid=%s) or (sAMAccountName=%s).
+	UserFilter string `json:"userFilter"`
+	// LoginAttribute is mapped to astral login.
+	LoginAttribute string `json:"loginAttribute"`
+	// GroupAttribute is a user attribute listing groups, memberOf by default.
+	GroupAttribute string `json:"groupAttribute"`
+	// GroupBaseDN enables groups search by GroupFilter with %s placeholder for user DN
+	// instead of GroupAttribute. Groups are named by GroupNameAttribute.
+	GroupBaseDN        string `json:"groupBaseDN"`
+	GroupFilter        string `json:"groupFilter"`
+	GroupNameAttribute string `json:"groupNameAttribute"`
+	// AdminGroups grant admin role on each login, other users lose it. Role is not synced if empty.
+	AdminGroups []string `json:"adminGroups"`
+	// GroupRoles map groups to roles set on each login, the most privileged one wins.
+	// Users out of the groups lose these roles. Combined with AdminGroups.
+	GroupRoles    map[string]string `json:"groupRoles"`
+	AutoProvision bool              `json:"autoProvision"`
+	// Timeout is a connection and search timeout in seconds.
+	Timeout int `json:"timeout"`
+}
+
+// OIDC configures login with OpenID Connect provider by authorization code flow with PKCE.
+type OIDC struct {
+	Enabled bool `json:"enabled"`
+	// Issuer is a provider URL serving /.well-known/openid-configuration.
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"clientID"`
+	ClientSecret string   `json:"clientSecret"`
+	RedirectURL  string   `json:"redirectURL"`
+	Scopes       []string `json:"scopes"`
+	// LoginClaim is an ID token claim mapped to astral login. Defaults to preferred_username.
+	LoginClaim  string `json:"loginClaim"`
+	GroupsClaim string `json:"groupsClaim"`
+	// AdminGroups grant admin role on each login, other users lose it. Role is not synced if empty.
+	AdminGroups []string `json:"adminGroups"`
+	// GroupRoles map groups to roles set on each login, the most privileged one wins.
+	// Users out of the groups lose these roles. Combined with AdminGroups.
+	GroupRoles map[string]string `json:"groupRoles"`
+	// AutoProvision creates users on first login. Otherwise unknown identities are rejected.
+	AutoProvision bool `json:"autoProvision"`
+	// StateTTL is a time in seconds to complete login at provider.
+	StateTTL int `json:"stateTTL"`
+}
+
+// AccessTokens enables stateless mode: auth returns short-lived signed access token
+// and refresh token stored as session. Durations are in seconds.
+type AccessTokens struct {
+	Enabled bool `json:"enabled"`
+	// SigningKey is a base64 encoded Ed25519 private key or its 32-byte seed.
+	SigningKey string `json:"signingKey"`
+	TTL        int    `json:"ttl"`
+	RefreshTTL int    `json:"refreshTTL"`
+	// DenylistSyncInterval is a period of revoked sessions sync. Revocation takes effect
+	// on other instances within this period.
+	DenylistSyncInterval int `json:"denylistSyncInterval"`
+}
+
+// TwoFactor configures TOTP second factor. Durations are in seconds.
+type TwoFactor struct {
+	// Issuer is an account issuer shown in authenticator apps.
+	Issuer string `json:"issuer"`
+	// ChallengeTTL is a time to enter second factor after password check.
+	ChallengeTTL int `json:"challengeTTL"`
+	// ChallengeMaxAttempts is a number of wrong codes after which challenge is dropped.
+	ChallengeMaxAttempts int `json:"challengeMaxAttempts"`
+	RecoveryCodes        int `json:"recoveryCodes"`
+}
+
+// PasswordHashing configures password hashing. Algorithm is "argon2id" or "bcrypt".
+// Zero values fall back to defaults. Argon2Memory is in KiB.
+type PasswordHashing struct {
+	Algorithm         string `json:"algorithm"`
+	Argon2Memory      uint32 `json:"argon2Memory"`
+	Argon2Iterations  uint32 `json:"argon2Iterations"`
+	Argon2Parallelism uint8  `json:"argon2Parallelism"`
+	Argon2SaltLength  uint32 `json:"argon2SaltLength"`
+	Argon2KeyLength   uint32 `json:"argon2KeyLength"`
+	BcryptCost        int    `json:"bcryptCost"`
+}
+
+type LoginPolicy struct {
+	MinLength    int    `json:"minLength"`
+	MaxLength    int    `json:"maxLength"`
+	AllowUnicode bool   `json:"allowUnicode"`
+	SpecialChars string `json:"specialChars"`
+}
+
+type PasswordPolicy struct {
+	MinLength      int    `json:"minLength"`
+	MaxLength      int    `json:"maxLength"`
+	RequireUpper   bool   `json:"requireUpper"`
+	RequireLower   bool   `json:"requireLower"`
+	RequireDigit   bool   `json:"requireDigit"`
+	RequireSpecial bool   `json:"requireSpecial"`
+	SpecialChars   string `json:"specialChars"`
+	AllowUnicode   bool   `json:"allowUnicode"`
+	AllowSpaces    bool   `json:"allowSpaces"`
+	// PassphraseMinLength is a length from which password is a passphrase
+	// and is not checked for required character classes. Zero disables passphrases.
+	PassphraseMinLength int     `json:"passphraseMinLength"`
+	MinEntropy          float64 `json:"minEntropy"`
+	// DenyListPath is a path to breached passwords file, one password per line.
+	DenyListPath string `json:"denyListPath"`
+}
+
+// Lockout configures failed auth attempts throttling. Durations are in seconds.
+type Lockout struct {
+	LoginMaxAttempts int `json:"loginMaxAttempts"`
+	IPMaxAttempts    int `json:"ipMaxAttempts"`
+	BackoffBase      int `json:"backoffBase"`
+	LockoutDuration  int `json:"lockoutDuration"`
+	// Window is a period after last failure to reset failures counter.
+	Window int `json:"window"`
 }
 
 type Database struct {
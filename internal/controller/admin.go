@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+type adminUsecase interface {
+	CreateInviteCode(ctx context.Context, principal user.Principal, request dto.CreateInviteCodeRequest) (dto.APIResponse[*dto.CreateInviteCodeResponse, any], int)
+	GetInviteCodes(ctx context.Context, principal user.Principal) (dto.APIResponse[*dto.GetInviteCodesResponse, any], int)
+	RevokeInviteCode(ctx context.Context, principal user.Principal, id string) (dto.APIResponse[dto.RevokeInviteCodeResponse, any], int)
+	ResetUserPassword(ctx context.Context, principal user.Principal, login string) (dto.APIResponse[*dto.ResetUserPasswordResponse, any], int)
+	ResetUserTwoFactor(ctx context.Context, principal user.Principal, login string) (dto.APIResponse[dto.ResetUserTwoFactorResponse, any], int)
+	DeleteUser(ctx context.Context, principal user.Principal, login string, request dto.DeleteUserRequest) (dto.APIResponse[*dto.DeleteUserResponse, any], int)
+	GetUsers(ctx context.Context, principal user.Principal, request dto.GetUsersRequest) (dto.APIResponse[*dto.GetUsersResponse, any], int)
+	UpdateUser(ctx context.Context, principal user.Principal, login string, request dto.UpdateUserRequest) (dto.APIResponse[*dto.UpdateUserResponse, any], int)
+	GetRoles(ctx context.Context, principal user.Principal) (dto.APIResponse[*dto.GetRolesResponse, any], int)
+	LogoutUser(ctx context.Context, principal user.Principal, login string) (dto.APIResponse[*dto.LogoutUserResponse, any], int)
+	GetLockouts(ctx context.Context, principal user.Principal) (dto.APIResponse[*dto.GetLockoutsResponse, any], int)
+	ClearLockout(ctx context.Context, principal user.Principal, kind, key string) (dto.APIResponse[dto.ClearLockoutResponse, any], int)
+}
+
+func (c controller) CreateInviteCode(fc *fiber.Ctx) error {
+	var request dto.CreateInviteCodeRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.adminUsecase.CreateInviteCode(fc.Context(), principal(fc), request)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) GetInviteCodes(fc *fiber.Ctx) error {
+	result, status := c.adminUsecase.GetInviteCodes(fc.Context(), principal(fc))
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) RevokeInviteCode(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+
+	result, status := c.adminUsecase.RevokeInviteCode(fc.Context(), principal(fc), id)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) ResetUserPassword(fc *fiber.Ctx) error {
+	login := fc.Params("login")
+
+	result, status := c.adminUsecase.ResetUserPassword(fc.Context(), principal(fc), login)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) ResetUserTwoFactor(fc *fiber.Ctx) error {
+	login := fc.Params("login")
+
+	result, status := c.adminUsecase.ResetUserTwoFactor(fc.Context(), principal(fc), login)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) DeleteUser(fc *fiber.Ctx) error {
+	login := fc.Params("login")
+
+	request, err := parseDeleteUserRequest(fc)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.adminUsecase.DeleteUser(fc.Context(), principal(fc), login, request)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) GetUsers(fc *fiber.Ctx) error {
+	var request dto.GetUsersRequest
+	err := fc.QueryParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.adminUsecase.GetUsers(fc.Context(), principal(fc), request)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) UpdateUser(fc *fiber.Ctx) error {
+	login := fc.Params("login")
+
+	var request dto.UpdateUserRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.adminUsecase.UpdateUser(fc.Context(), principal(fc), login, request)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) LogoutUser(fc *fiber.Ctx) error {
+	login := fc.Params("login")
+
+	result, status := c.adminUsecase.LogoutUser(fc.Context(), principal(fc), login)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) GetRoles(fc *fiber.Ctx) error {
+	result, status := c.adminUsecase.GetRoles(fc.Context(), principal(fc))
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) GetLockouts(fc *fiber.Ctx) error {
+	result, status := c.adminUsecase.GetLockouts(fc.Context(), principal(fc))
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) ClearLockout(fc *fiber.Ctx) error {
+	var request dto.ClearLockoutRequest
+	err := fc.QueryParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.adminUsecase.ClearLockout(fc.Context(), principal(fc), request.Kind, request.Key)
+
+	return fc.Status(status).JSON(result)
+}
@@ -3,19 +3,45 @@ package controller
 import (
 	"context"
 	"log/slog"
+	"net/http"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/srgklmv/astral/internal/domain/user"
 	"github.com/srgklmv/astral/internal/models/apperrors"
 	"github.com/srgklmv/astral/internal/models/dto"
 	"github.com/srgklmv/astral/pkg/logger"
 )
 
 type authUsecase interface {
-	Register(ctx context.Context, token, login, password string) (dto.APIResponse[*dto.RegisterResponse, any], int)
-	Auth(ctx context.Context, login, password string) (dto.APIResponse[*dto.AuthResponse, any], int)
+	Register(ctx context.Context, inviteCode, login, password string) (dto.APIResponse[*dto.RegisterResponse, any], int)
+	Auth(ctx context.Context, login, password, device, userAgent, ip string) (dto.APIResponse[*dto.AuthResponse, any], int)
 	Logout(ctx context.Context, token string) (dto.APIResponse[*dto.LogoutResponse, any], int)
+	Refresh(ctx context.Context, refreshToken string) (dto.APIResponse[*dto.AuthResponse, any], int)
+	ChangePassword(ctx context.Context, principal user.Principal, password, newPassword, ip string) (dto.APIResponse[*dto.ChangePasswordResponse, any], int)
+	DeleteAccount(ctx context.Context, principal user.Principal, request dto.DeleteUserRequest) (dto.APIResponse[*dto.DeleteUserResponse, any], int)
+	GetSessions(ctx context.Context, principal user.Principal) (dto.APIResponse[*dto.GetSessionsResponse, any], int)
+	RevokeSession(ctx context.Context, principal user.Principal, id string) (dto.APIResponse[dto.RevokeSessionResponse, any], int)
+	RevokeOtherSessions(ctx context.Context, principal user.Principal) (dto.APIResponse[*dto.RevokeOtherSessionsResponse, any], int)
+	VerifyTwoFactor(ctx context.Context, challenge, code, userAgent, ip string) (dto.APIResponse[*dto.AuthResponse, any], int)
+	EnrollTOTP(ctx context.Context, principal user.Principal) (dto.APIResponse[*dto.EnrollTOTPResponse, any], int)
+	ConfirmTOTP(ctx context.Context, principal user.Principal, code, ip string) (dto.APIResponse[*dto.ConfirmTOTPResponse, any], int)
+	DisableTOTP(ctx context.Context, principal user.Principal, password, ip string) (dto.APIResponse[dto.DisableTOTPResponse, any], int)
+	CreateAPIKey(ctx context.Context, principal user.Principal, request dto.CreateAPIKeyRequest) (dto.APIResponse[*dto.CreateAPIKeyResponse, any], int)
+	GetAPIKeys(ctx context.Context, principal user.Principal) (dto.APIResponse[*dto.GetAPIKeysResponse, any], int)
+	RevokeAPIKey(ctx context.Context, principal user.Principal, id string) (dto.APIResponse[dto.RevokeAPIKeyResponse, any], int)
+	OIDCLogin(ctx context.Context, device string) (dto.APIResponse[*dto.OIDCLoginResponse, any], int)
+	OIDCCallback(ctx context.Context, state, code, userAgent, ip string) (dto.APIResponse[*dto.AuthResponse, any], int)
+	BeginPasskeyRegistration(ctx context.Context, principal user.Principal) (dto.APIResponse[*dto.BeginPasskeyResponse, any], int)
+	FinishPasskeyRegistration(ctx context.Context, principal user.Principal, request dto.FinishPasskeyRegistrationRequest) (dto.APIResponse[*dto.Passkey, any], int)
+	GetPasskeys(ctx context.Context, principal user.Principal) (dto.APIResponse[*dto.GetPasskeysResponse, any], int)
+	RemovePasskey(ctx context.Context, principal user.Principal, id string) (dto.APIResponse[dto.RemovePasskeyResponse, any], int)
+	BeginPasskeyLogin(ctx context.Context, device string) (dto.APIResponse[*dto.BeginPasskeyResponse, any], int)
+	FinishPasskeyLogin(ctx context.Context, request dto.FinishPasskeyLoginRequest, userAgent, ip string) (dto.APIResponse[*dto.AuthResponse, any], int)
 }
 
+// oidcStateCookieName binds OIDC login state to browser which started it.
+const oidcStateCookieName = "oidc_state"
+
 func (c controller) Register(fc *fiber.Ctx) error {
 	var request dto.RegisterRequest
 	err := fc.BodyParser(&request)
@@ -27,7 +53,7 @@ func (c controller) Register(fc *fiber.Ctx) error {
 		}, nil, nil))
 	}
 
-	result, status := c.authUsecase.Register(fc.Context(), request.Token, request.Login, request.Password)
+	result, status := c.authUsecase.Register(fc.Context(), request.InviteCode, request.Login, request.Password)
 
 	return fc.Status(status).JSON(result)
 }
@@ -43,7 +69,14 @@ func (c controller) Auth(fc *fiber.Ctx) error {
 		}, nil, nil))
 	}
 
-	result, status := c.authUsecase.Auth(fc.Context(), request.Login, request.Password)
+	result, status := c.authUsecase.Auth(
+		fc.Context(),
+		request.Login,
+		request.Password,
+		request.Device,
+		fc.Get(fiber.HeaderUserAgent),
+		fc.IP(),
+	)
 
 	return fc.Status(status).JSON(result)
 }
@@ -63,3 +96,301 @@ func (c controller) Logout(fc *fiber.Ctx) error {
 
 	return fc.Status(status).JSON(result)
 }
+
+func (c controller) Refresh(fc *fiber.Ctx) error {
+	var request dto.RefreshRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.authUsecase.Refresh(fc.Context(), request.RefreshToken)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) ChangePassword(fc *fiber.Ctx) error {
+	var request dto.ChangePasswordRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.authUsecase.ChangePassword(fc.Context(), principal(fc), request.Password, request.NewPassword, fc.IP())
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) DeleteAccount(fc *fiber.Ctx) error {
+	request, err := parseDeleteUserRequest(fc)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.authUsecase.DeleteAccount(fc.Context(), principal(fc), request)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) GetSessions(fc *fiber.Ctx) error {
+	result, status := c.authUsecase.GetSessions(fc.Context(), principal(fc))
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) RevokeSession(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+
+	result, status := c.authUsecase.RevokeSession(fc.Context(), principal(fc), id)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) RevokeOtherSessions(fc *fiber.Ctx) error {
+	result, status := c.authUsecase.RevokeOtherSessions(fc.Context(), principal(fc))
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) VerifyTwoFactor(fc *fiber.Ctx) error {
+	var request dto.VerifyTwoFactorRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.authUsecase.VerifyTwoFactor(
+		fc.Context(),
+		request.Challenge,
+		request.Code,
+		fc.Get(fiber.HeaderUserAgent),
+		fc.IP(),
+	)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) EnrollTOTP(fc *fiber.Ctx) error {
+	result, status := c.authUsecase.EnrollTOTP(fc.Context(), principal(fc))
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) ConfirmTOTP(fc *fiber.Ctx) error {
+	var request dto.ConfirmTOTPRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.authUsecase.ConfirmTOTP(fc.Context(), principal(fc), request.Code, fc.IP())
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) DisableTOTP(fc *fiber.Ctx) error {
+	var request dto.DisableTOTPRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.authUsecase.DisableTOTP(fc.Context(), principal(fc), request.Password, fc.IP())
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) CreateAPIKey(fc *fiber.Ctx) error {
+	var request dto.CreateAPIKeyRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.authUsecase.CreateAPIKey(fc.Context(), principal(fc), request)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) GetAPIKeys(fc *fiber.Ctx) error {
+	result, status := c.authUsecase.GetAPIKeys(fc.Context(), principal(fc))
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) RevokeAPIKey(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+
+	result, status := c.authUsecase.RevokeAPIKey(fc.Context(), principal(fc), id)
+
+	return fc.Status(status).JSON(result)
+}
+
+// OIDCLogin redirects user to identity provider. Optional device query parameter labels session.
+func (c controller) OIDCLogin(fc *fiber.Ctx) error {
+	result, status := c.authUsecase.OIDCLogin(fc.Context(), fc.Query("device"))
+	if result.Error != nil {
+		return fc.Status(status).JSON(result)
+	}
+
+	fc.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    result.Response.State,
+		Path:     "/api/auth/oidc",
+		Secure:   fc.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return fc.Redirect(result.Response.URL, http.StatusFound)
+}
+
+func (c controller) OIDCCallback(fc *fiber.Ctx) error {
+	state := fc.Query("state")
+	fc.ClearCookie(oidcStateCookieName)
+
+	if state == "" || fc.Cookies(oidcStateCookieName) != state {
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.OIDCStateInvalidErrorText,
+		}, nil, nil))
+	}
+
+	if fc.Query("error") != "" {
+		logger.Error("identity provider error", slog.String("error", fc.Query("error")), slog.String("description", fc.Query("error_description")))
+		return fc.Status(http.StatusUnauthorized).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.IdentityProviderErrorCode,
+			Text: apperrors.OIDCLoginFailedErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.authUsecase.OIDCCallback(
+		fc.Context(),
+		state,
+		fc.Query("code"),
+		fc.Get(fiber.HeaderUserAgent),
+		fc.IP(),
+	)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) BeginPasskeyRegistration(fc *fiber.Ctx) error {
+	result, status := c.authUsecase.BeginPasskeyRegistration(fc.Context(), principal(fc))
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) FinishPasskeyRegistration(fc *fiber.Ctx) error {
+	var request dto.FinishPasskeyRegistrationRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.authUsecase.FinishPasskeyRegistration(fc.Context(), principal(fc), request)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) GetPasskeys(fc *fiber.Ctx) error {
+	result, status := c.authUsecase.GetPasskeys(fc.Context(), principal(fc))
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) RemovePasskey(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+
+	result, status := c.authUsecase.RemovePasskey(fc.Context(), principal(fc), id)
+
+	return fc.Status(status).JSON(result)
+}
+
+// BeginPasskeyLogin accepts empty body.
+func (c controller) BeginPasskeyLogin(fc *fiber.Ctx) error {
+	var request dto.BeginPasskeyLoginRequest
+	if len(fc.Body()) != 0 {
+		err := fc.BodyParser(&request)
+		if err != nil {
+			logger.Error("request parsing error", slog.String("error", err.Error()))
+			return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+				Code: apperrors.BodyParsingErrorCode,
+				Text: apperrors.BodyParsingErrorText,
+			}, nil, nil))
+		}
+	}
+
+	result, status := c.authUsecase.BeginPasskeyLogin(fc.Context(), request.Device)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) FinishPasskeyLogin(fc *fiber.Ctx) error {
+	var request dto.FinishPasskeyLoginRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.authUsecase.FinishPasskeyLogin(
+		fc.Context(),
+		request,
+		fc.Get(fiber.HeaderUserAgent),
+		fc.IP(),
+	)
+
+	return fc.Status(status).JSON(result)
+}
+
+// parseDeleteUserRequest reads query parameters, then body if present.
+func parseDeleteUserRequest(fc *fiber.Ctx) (dto.DeleteUserRequest, error) {
+	var request dto.DeleteUserRequest
+
+	err := fc.QueryParser(&request)
+	if err != nil {
+		return request, err
+	}
+
+	if len(fc.Body()) != 0 {
+		err = fc.BodyParser(&request)
+	}
+
+	return request, err
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+// fakeAuthUsecase records OIDC callbacks which reached usecase.
+type fakeAuthUsecase struct {
+	authUsecase
+
+	callbacks int
+}
+
+func (u *fakeAuthUsecase) OIDCCallback(context.Context, string, string, string, string) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	u.callbacks++
+	return dto.NewAPIResponse[*dto.AuthResponse, any](nil, &dto.AuthResponse{Token: "token"}, nil), http.StatusCreated
+}
+
+func TestOIDCCallbackStateCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string
+		status int
+	}{
+		{name: "matching cookie", cookie: "state", status: http.StatusCreated},
+		{name: "missing cookie", status: http.StatusBadRequest},
+		{name: "mismatched cookie", cookie: "other-state", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usecase := &fakeAuthUsecase{}
+			app := fiber.New()
+			app.Get("/api/auth/oidc/callback", controller{authUsecase: usecase}.OIDCCallback)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?state=state&code=code", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: oidcStateCookieName, Value: tt.cookie})
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusCreated && usecase.callbacks != 0 {
+				t.Fatal("callback with invalid state cookie reached usecase")
+			}
+		})
+	}
+}
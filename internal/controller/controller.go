@@ -1,18 +1,35 @@
 package controller
 
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/srgklmv/astral/internal/domain/user"
+)
+
 type controller struct {
 	documentsUsecase documentsUsecase
 	authUsecase      authUsecase
+	adminUsecase     adminUsecase
+	groupsUsecase    groupsUsecase
 }
 
 type usecase interface {
 	authUsecase
 	documentsUsecase
+	adminUsecase
+	groupsUsecase
 }
 
 func New(usecase usecase) *controller {
 	return &controller{
 		documentsUsecase: usecase,
 		authUsecase:      usecase,
+		adminUsecase:     usecase,
+		groupsUsecase:    usecase,
 	}
 }
+
+// principal returns user authorized by auth middleware.
+func principal(fc *fiber.Ctx) user.Principal {
+	p, _ := user.PrincipalFromContext(fc.UserContext())
+	return p
+}
@@ -6,20 +6,35 @@ import (
 	"encoding/json"
 	"io"
 	"log/slog"
-	"mime/multipart"
 	"net/http"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/srgklmv/astral/internal/domain/user"
 	"github.com/srgklmv/astral/internal/models/apperrors"
 	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/jsonpatch"
 	"github.com/srgklmv/astral/pkg/logger"
 )
 
+// linkPasswordHeader carries share link password, so it does not get into URLs and access logs.
+const linkPasswordHeader = "X-Link-Password"
+
 type documentsUsecase interface {
-	UploadDocument(ctx context.Context, token string, meta dto.UploadDocumentRequestMetadata, json dto.UploadDocumentRequestJSON, file *bytes.Buffer) (dto.APIResponse[any, *dto.UploadFileResponse], int)
-	GetDocuments(ctx context.Context, request dto.GetDocumentsRequest) (dto.APIResponse[any, *dto.GetDocumentsResponse], int)
-	GetDocument(ctx context.Context, token, id string) (dto.APIResponse[any, any], []byte, string, int)
-	DeleteDocument(ctx context.Context, token, id string) (dto.APIResponse[any, *dto.DeleteDocumentResponse], int)
+	UploadDocument(ctx context.Context, principal user.Principal, meta dto.UploadDocumentRequestMetadata, json dto.UploadDocumentRequestJSON, file *bytes.Buffer) (dto.APIResponse[any, *dto.UploadFileResponse], int)
+	GetDocuments(ctx context.Context, principal user.Principal, request dto.GetDocumentsRequest) (dto.APIResponse[any, *dto.GetDocumentsResponse], int)
+	GetDocument(ctx context.Context, principal user.Principal, id, version string) (dto.APIResponse[any, any], []byte, string, int)
+	UpdateDocument(ctx context.Context, principal user.Principal, id string, meta dto.DocumentMetadata, json dto.UploadDocumentRequestJSON, file *bytes.Buffer) (dto.APIResponse[any, *dto.UploadFileResponse], int)
+	PatchDocument(ctx context.Context, principal user.Principal, id, contentType string, patch []byte) (dto.APIResponse[any, *dto.UploadFileResponse], int)
+	DeleteDocument(ctx context.Context, principal user.Principal, id string) (dto.APIResponse[any, *dto.DeleteDocumentResponse], int)
+	GetDocumentVersions(ctx context.Context, principal user.Principal, id string) (dto.APIResponse[any, *dto.GetDocumentVersionsResponse], int)
+	RestoreDocumentVersion(ctx context.Context, principal user.Principal, id, version string) (dto.APIResponse[any, *dto.DocumentVersion], int)
+	GetDocumentGrants(ctx context.Context, principal user.Principal, id string) (dto.APIResponse[any, *dto.GetDocumentGrantsResponse], int)
+	AddDocumentGrants(ctx context.Context, principal user.Principal, id string, request dto.AddDocumentGrantsRequest) (dto.APIResponse[any, *dto.GetDocumentGrantsResponse], int)
+	RevokeDocumentGrants(ctx context.Context, principal user.Principal, id string, request dto.RevokeDocumentGrantsRequest) (dto.APIResponse[any, *dto.GetDocumentGrantsResponse], int)
+	CreateShareLink(ctx context.Context, principal user.Principal, id string, request dto.CreateShareLinkRequest) (dto.APIResponse[any, *dto.CreateShareLinkResponse], int)
+	GetShareLinks(ctx context.Context, principal user.Principal, id string) (dto.APIResponse[any, *dto.GetShareLinksResponse], int)
+	RevokeShareLink(ctx context.Context, principal user.Principal, id, linkID string) (dto.APIResponse[any, *dto.RevokeShareLinkResponse], int)
+	OpenShareLink(ctx context.Context, token, password string) (dto.APIResponse[any, any], []byte, string, bool, int)
 }
 
 func (c controller) UploadDocument(fc *fiber.Ctx) error {
@@ -34,51 +49,47 @@ func (c controller) UploadDocument(fc *fiber.Ctx) error {
 		}, nil, nil))
 	}
 
-	var jsonData dto.UploadDocumentRequestJSON
-	jsonString := fc.FormValue("json")
-	_ = json.Unmarshal([]byte(jsonString), &jsonData)
+	jsonData, buf, status, parseErr := parseDocumentContent(fc)
+	if parseErr != nil {
+		return fc.Status(status).JSON(dto.NewAPIResponse[any, any](parseErr, nil, nil))
+	}
 
-	fileHeader, _ := fc.FormFile("file")
+	result, status := c.documentsUsecase.UploadDocument(fc.Context(), principal(fc), meta, jsonData, buf)
 
-	var file multipart.File
-	if fileHeader != nil {
-		file, err = fileHeader.Open()
-		if err != nil {
-			logger.Error("file parsing error", slog.String("error", err.Error()))
-			return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
-				Code: apperrors.BodyParsingErrorCode,
-				Text: apperrors.BodyParsingErrorText,
-			}, nil, nil))
-		}
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) UpdateDocument(fc *fiber.Ctx) error {
+	id := fc.Params("id")
 
-		defer func() {
-			if err := file.Close(); err != nil {
-				logger.Error("file closing error", slog.String("error", err.Error()))
-			}
-		}()
+	var meta dto.DocumentMetadata
+	err := json.Unmarshal([]byte(fc.FormValue("meta")), &meta)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
 	}
 
-	buf := bytes.NewBuffer(nil)
-	if file != nil {
-		n, err := io.Copy(buf, file)
-		if err != nil {
-			logger.Error("file parsing error", slog.String("error", err.Error()))
-			return fc.Status(http.StatusInternalServerError).JSON(dto.NewAPIResponse[any, any](&dto.Error{
-				Code: apperrors.DocumentUploadingErrorCode,
-				Text: apperrors.InternalErrorText,
-			}, nil, nil))
-		}
-		if n == 0 {
-			return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
-				Code: apperrors.BodyParsingErrorCode,
-				Text: apperrors.BodyParsingErrorText,
-			}, nil, nil))
-		}
+	jsonData, buf, status, parseErr := parseDocumentContent(fc)
+	if parseErr != nil {
+		return fc.Status(status).JSON(dto.NewAPIResponse[any, any](parseErr, nil, nil))
 	}
 
-	token := meta.Token
+	result, status := c.documentsUsecase.UpdateDocument(fc.Context(), principal(fc), id, meta, jsonData, buf)
 
-	result, status := c.documentsUsecase.UploadDocument(fc.Context(), token, meta, jsonData, buf)
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) PatchDocument(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+
+	result, status := c.documentsUsecase.PatchDocument(fc.Context(), principal(fc), id, fc.Get(fiber.HeaderContentType), fc.Body())
+
+	if status == http.StatusUnsupportedMediaType {
+		fc.Set("Accept-Patch", jsonpatch.MergePatch+", "+jsonpatch.JSONPatch)
+	}
 
 	return fc.Status(status).JSON(result)
 }
@@ -86,7 +97,7 @@ func (c controller) UploadDocument(fc *fiber.Ctx) error {
 func (c controller) GetDocuments(fc *fiber.Ctx) error {
 	var request dto.GetDocumentsRequest
 
-	err := fc.BodyParser(&request)
+	err := fc.QueryParser(&request)
 	if err != nil {
 		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, *dto.GetDocumentsResponse](&dto.Error{
 			Code: apperrors.BodyParsingErrorCode,
@@ -94,18 +105,28 @@ func (c controller) GetDocuments(fc *fiber.Ctx) error {
 		}, nil, nil))
 	}
 
-	result, status := c.documentsUsecase.GetDocuments(fc.Context(), request)
+	// Body filters are kept for backward compatibility.
+	if len(fc.Body()) != 0 {
+		err = fc.BodyParser(&request)
+	}
+	if err != nil {
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, *dto.GetDocumentsResponse](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.documentsUsecase.GetDocuments(fc.Context(), principal(fc), request)
 
 	return fc.Status(status).JSON(result)
 }
 
 func (c controller) GetDocument(fc *fiber.Ctx) error {
-	var req dto.GetDocumentRequest
-	_ = fc.BodyParser(&req)
-
 	id := fc.Params("id")
+	// Version is optional, current content is returned without it.
+	version := fc.Query("version")
 
-	response, file, filename, status := c.documentsUsecase.GetDocument(fc.Context(), req.Token, id)
+	response, file, filename, status := c.documentsUsecase.GetDocument(fc.Context(), principal(fc), id, version)
 
 	if len(file) == 0 {
 		return fc.Status(status).JSON(response)
@@ -117,19 +138,186 @@ func (c controller) GetDocument(fc *fiber.Ctx) error {
 	return fc.Status(status).Send(file)
 }
 
-func (c controller) DeleteDocument(fc *fiber.Ctx) error {
-	documentID := fc.Params("id")
+func (c controller) GetDocumentVersions(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+
+	response, status := c.documentsUsecase.GetDocumentVersions(fc.Context(), principal(fc), id)
+
+	return fc.Status(status).JSON(response)
+}
+
+func (c controller) RestoreDocumentVersion(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+	version := fc.Params("version")
+
+	response, status := c.documentsUsecase.RestoreDocumentVersion(fc.Context(), principal(fc), id, version)
+
+	return fc.Status(status).JSON(response)
+}
+
+func (c controller) GetDocumentGrants(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+
+	response, status := c.documentsUsecase.GetDocumentGrants(fc.Context(), principal(fc), id)
+
+	return fc.Status(status).JSON(response)
+}
+
+func (c controller) AddDocumentGrants(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+
+	var request dto.AddDocumentGrantsRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	response, status := c.documentsUsecase.AddDocumentGrants(fc.Context(), principal(fc), id, request)
+
+	return fc.Status(status).JSON(response)
+}
+
+func (c controller) RevokeDocumentGrants(fc *fiber.Ctx) error {
+	id := fc.Params("id")
 
-	var request dto.DeleteDocumentRequest
+	var request dto.RevokeDocumentGrantsRequest
 	err := fc.BodyParser(&request)
 	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
 		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
-			Code: apperrors.BadRequestErrorCode,
+			Code: apperrors.BodyParsingErrorCode,
 			Text: apperrors.BodyParsingErrorText,
 		}, nil, nil))
 	}
 
-	response, status := c.documentsUsecase.DeleteDocument(fc.Context(), request.Token, documentID)
+	response, status := c.documentsUsecase.RevokeDocumentGrants(fc.Context(), principal(fc), id, request)
+
+	return fc.Status(status).JSON(response)
+}
+
+func (c controller) CreateShareLink(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+
+	var request dto.CreateShareLinkRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	response, status := c.documentsUsecase.CreateShareLink(fc.Context(), principal(fc), id, request)
+
+	return fc.Status(status).JSON(response)
+}
+
+func (c controller) GetShareLinks(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+
+	response, status := c.documentsUsecase.GetShareLinks(fc.Context(), principal(fc), id)
 
 	return fc.Status(status).JSON(response)
 }
+
+func (c controller) RevokeShareLink(fc *fiber.Ctx) error {
+	id := fc.Params("id")
+	linkID := fc.Params("link")
+
+	response, status := c.documentsUsecase.RevokeShareLink(fc.Context(), principal(fc), id, linkID)
+
+	return fc.Status(status).JSON(response)
+}
+
+// OpenShareLink serves shared document without session. Password is taken from
+// X-Link-Password header or, for POST form sent by browser, from password field.
+func (c controller) OpenShareLink(fc *fiber.Ctx) error {
+	token := fc.Params("token")
+	password := fc.Get(linkPasswordHeader)
+
+	if password == "" && fc.Method() == fiber.MethodPost {
+		var request dto.OpenShareLinkRequest
+		err := fc.BodyParser(&request)
+		if err != nil {
+			logger.Error("request parsing error", slog.String("error", err.Error()))
+			return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+				Code: apperrors.BodyParsingErrorCode,
+				Text: apperrors.BodyParsingErrorText,
+			}, nil, nil))
+		}
+		password = request.Password
+	}
+
+	response, file, filename, isFile, status := c.documentsUsecase.OpenShareLink(fc.Context(), token, password)
+
+	// Shared content must not stay in browser or proxy caches after link is revoked.
+	fc.Set(fiber.HeaderCacheControl, "no-store")
+	fc.Set("Referrer-Policy", "no-referrer")
+
+	if !isFile {
+		return fc.Status(status).JSON(response)
+	}
+
+	fc.Attachment(filename)
+
+	return fc.Status(status).Send(file)
+}
+
+func (c controller) DeleteDocument(fc *fiber.Ctx) error {
+	documentID := fc.Params("id")
+
+	response, status := c.documentsUsecase.DeleteDocument(fc.Context(), principal(fc), documentID)
+
+	return fc.Status(status).JSON(response)
+}
+
+// parseDocumentContent reads json and file fields of upload and update form.
+func parseDocumentContent(fc *fiber.Ctx) (dto.UploadDocumentRequestJSON, *bytes.Buffer, int, *dto.Error) {
+	var jsonData dto.UploadDocumentRequestJSON
+	jsonString := fc.FormValue("json")
+	_ = json.Unmarshal([]byte(jsonString), &jsonData)
+
+	fileHeader, _ := fc.FormFile("file")
+
+	buf := bytes.NewBuffer(nil)
+	if fileHeader == nil {
+		return jsonData, buf, http.StatusOK, nil
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		logger.Error("file parsing error", slog.String("error", err.Error()))
+		return nil, nil, http.StatusBadRequest, &dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			logger.Error("file closing error", slog.String("error", err.Error()))
+		}
+	}()
+
+	n, err := io.Copy(buf, file)
+	if err != nil {
+		logger.Error("file parsing error", slog.String("error", err.Error()))
+		return nil, nil, http.StatusInternalServerError, &dto.Error{
+			Code: apperrors.DocumentUploadingErrorCode,
+			Text: apperrors.InternalErrorText,
+		}
+	}
+	if n == 0 {
+		return nil, nil, http.StatusBadRequest, &dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}
+	}
+
+	return jsonData, buf, http.StatusOK, nil
+}
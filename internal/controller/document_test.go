@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/srgklmv/astral/internal/models/dto"
+)
+
+// fakeDocumentsUsecase serves share link with given content.
+type fakeDocumentsUsecase struct {
+	documentsUsecase
+
+	file   []byte
+	json   map[string]any
+	isFile bool
+}
+
+func (u *fakeDocumentsUsecase) OpenShareLink(context.Context, string, string) (dto.APIResponse[any, any], []byte, string, bool, int) {
+	if u.isFile {
+		return dto.APIResponse[any, any]{}, u.file, "shared.txt", true, http.StatusOK
+	}
+
+	return dto.NewAPIResponse[any, any](nil, nil, &u.json), nil, "", false, http.StatusOK
+}
+
+func TestOpenShareLinkContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		usecase     *fakeDocumentsUsecase
+		body        string
+		disposition string
+	}{
+		{name: "file", usecase: &fakeDocumentsUsecase{file: []byte("content"), isFile: true}, body: "content", disposition: `attachment; filename="shared.txt"`},
+		{name: "empty file", usecase: &fakeDocumentsUsecase{isFile: true}, body: "", disposition: `attachment; filename="shared.txt"`},
+		{name: "json", usecase: &fakeDocumentsUsecase{json: map[string]any{"a": 1}}, body: `{"data":{"a":1}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/api/links/:token", controller{documentsUsecase: tt.usecase}.OpenShareLink)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/links/token", nil))
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+			if string(body) != tt.body {
+				t.Fatalf("body = %q, want %q", body, tt.body)
+			}
+			if disposition := resp.Header.Get(fiber.HeaderContentDisposition); disposition != tt.disposition {
+				t.Fatalf("Content-Disposition = %q, want %q", disposition, tt.disposition)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+type groupsUsecase interface {
+	CreateGroup(ctx context.Context, principal user.Principal, request dto.CreateGroupRequest) (dto.APIResponse[*dto.Group, any], int)
+	GetGroups(ctx context.Context, principal user.Principal) (dto.APIResponse[*dto.GetGroupsResponse, any], int)
+	GetGroup(ctx context.Context, principal user.Principal, name string) (dto.APIResponse[*dto.Group, any], int)
+	DeleteGroup(ctx context.Context, principal user.Principal, name string) (dto.APIResponse[dto.DeleteGroupResponse, any], int)
+	SetGroupMember(ctx context.Context, principal user.Principal, name, login string, request dto.SetGroupMemberRequest) (dto.APIResponse[*dto.Group, any], int)
+	RemoveGroupMember(ctx context.Context, principal user.Principal, name, login string) (dto.APIResponse[dto.RemoveGroupMemberResponse, any], int)
+}
+
+func (c controller) CreateGroup(fc *fiber.Ctx) error {
+	var request dto.CreateGroupRequest
+	err := fc.BodyParser(&request)
+	if err != nil {
+		logger.Error("request parsing error", slog.String("error", err.Error()))
+		return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.BodyParsingErrorCode,
+			Text: apperrors.BodyParsingErrorText,
+		}, nil, nil))
+	}
+
+	result, status := c.groupsUsecase.CreateGroup(fc.Context(), principal(fc), request)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) GetGroups(fc *fiber.Ctx) error {
+	result, status := c.groupsUsecase.GetGroups(fc.Context(), principal(fc))
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) GetGroup(fc *fiber.Ctx) error {
+	name := fc.Params("name")
+
+	result, status := c.groupsUsecase.GetGroup(fc.Context(), principal(fc), name)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) DeleteGroup(fc *fiber.Ctx) error {
+	name := fc.Params("name")
+
+	result, status := c.groupsUsecase.DeleteGroup(fc.Context(), principal(fc), name)
+
+	return fc.Status(status).JSON(result)
+}
+
+// SetGroupMember accepts empty body to add regular member.
+func (c controller) SetGroupMember(fc *fiber.Ctx) error {
+	name := fc.Params("name")
+	login := fc.Params("login")
+
+	var request dto.SetGroupMemberRequest
+	if len(fc.Body()) != 0 {
+		err := fc.BodyParser(&request)
+		if err != nil {
+			logger.Error("request parsing error", slog.String("error", err.Error()))
+			return fc.Status(http.StatusBadRequest).JSON(dto.NewAPIResponse[any, any](&dto.Error{
+				Code: apperrors.BodyParsingErrorCode,
+				Text: apperrors.BodyParsingErrorText,
+			}, nil, nil))
+		}
+	}
+
+	result, status := c.groupsUsecase.SetGroupMember(fc.Context(), principal(fc), name, login, request)
+
+	return fc.Status(status).JSON(result)
+}
+
+func (c controller) RemoveGroupMember(fc *fiber.Ctx) error {
+	name := fc.Params("name")
+	login := fc.Params("login")
+
+	result, status := c.groupsUsecase.RemoveGroupMember(fc.Context(), principal(fc), name, login)
+
+	return fc.Status(status).JSON(result)
+}
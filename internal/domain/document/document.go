@@ -1,15 +1,27 @@
 package document
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Access sources of listed document for user listing it.
+const (
+	AccessOwner  = "owner"
+	AccessDirect = "direct"
+	AccessGroup  = "group"
+	AccessPublic = "public"
+)
+
 type Document struct {
 	Data
 	JSON map[string]any
 	File []byte
+	// Grants are direct and group grants with their levels.
+	Grants []Grant
 }
 
 type Data struct {
@@ -19,8 +31,58 @@ type Data struct {
 	IsFile    bool
 	Mimetype  string
 	GrantedTo []string
-	CreatedAt time.Time
-	Owner     string
+	// GrantedToGroups are names of groups document is granted to.
+	GrantedToGroups []string
+	Tags            []string
+	CreatedAt       time.Time
+	Owner           string
+	// Version is a number of current content version.
+	Version int
+	// Access tells how listing user got document, AccessGroups are groups it came from.
+	Access       string
+	AccessGroups []string
 }
 
 type DocumentsData []Data
+
+// Version is an immutable snapshot of document content. Size and Checksum are counted over
+// file bytes or JSON text, Checksum is hex encoded SHA-256.
+type Version struct {
+	DocumentID uuid.UUID
+	Number     int
+	Filename   string
+	IsFile     bool
+	Mimetype   string
+	JSON       map[string]any
+	File       []byte
+	Size       int64
+	Checksum   string
+	Author     string
+	CreatedAt  time.Time
+}
+
+// Filter limits documents to ones with listed IDs or tags. Empty filter matches any document.
+type Filter struct {
+	IDs  []string
+	Tags []string
+}
+
+func (f Filter) IsEmpty() bool {
+	return len(f.IDs) == 0 && len(f.Tags) == 0
+}
+
+// NormalizeTags trims tags and drops empty and duplicate ones.
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || slices.Contains(normalized, tag) {
+			continue
+		}
+
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
@@ -0,0 +1,44 @@
+package document
+
+import (
+	"slices"
+)
+
+// Grant levels from the weakest one. Each level allows actions of previous ones.
+const (
+	LevelRead    = "read"
+	LevelComment = "comment"
+	LevelWrite   = "write"
+	LevelDelete  = "delete"
+	LevelShare   = "share"
+)
+
+var Levels = []string{LevelRead, LevelComment, LevelWrite, LevelDelete, LevelShare}
+
+// Grant gives document access to user or group, one of Login and Group is set.
+type Grant struct {
+	Login string
+	Group string
+	Level string
+}
+
+func IsValidLevel(level string) bool {
+	return slices.Contains(Levels, level)
+}
+
+// LevelAllows reports if level allows action of required level. Empty level allows nothing.
+func LevelAllows(level, required string) bool {
+	return level != "" && slices.Index(Levels, level) >= slices.Index(Levels, required)
+}
+
+// MaxLevel returns the strongest of levels.
+func MaxLevel(levels ...string) string {
+	strongest := ""
+	for _, level := range levels {
+		if slices.Index(Levels, level) > slices.Index(Levels, strongest) {
+			strongest = level
+		}
+	}
+
+	return strongest
+}
@@ -0,0 +1,53 @@
+package document
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LinkMaxPasswordFailures is a number of wrong passwords after which share link stops working.
+const LinkMaxPasswordFailures = 10
+
+// Link is a share link giving anyone with its token access to document content
+// without account. Only token hash and password hash are stored.
+type Link struct {
+	ID               string
+	DocumentID       uuid.UUID
+	CreatedBy        string
+	TokenHash        string
+	PasswordHash     *string
+	MaxDownloads     *int
+	Downloads        int
+	PasswordFailures int
+	CreatedAt        time.Time
+	ExpiresAt        time.Time
+	LastUsedAt       *time.Time
+	RevokedAt        *time.Time
+}
+
+// GenerateLinkToken returns random URL-safe share link token.
+func GenerateLinkToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)), nil
+}
+
+func (l Link) HasPassword() bool {
+	return l.PasswordHash != nil
+}
+
+// IsActive reports if link is not revoked, expired, used up or locked by wrong passwords.
+func (l Link) IsActive(now time.Time) bool {
+	return l.RevokedAt == nil &&
+		now.Before(l.ExpiresAt) &&
+		(l.MaxDownloads == nil || l.Downloads < *l.MaxDownloads) &&
+		l.PasswordFailures < LinkMaxPasswordFailures
+}
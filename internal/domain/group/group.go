@@ -0,0 +1,80 @@
+package group
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrLastAdmin means change would leave group without admin to manage its members.
+var ErrLastAdmin = errors.New("group must have an admin")
+
+// GrantPrefix marks document grant target as group name, like @finance.
+const GrantPrefix = "@"
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// Group is a named set of users documents may be granted to.
+// Group admins manage its members.
+type Group struct {
+	Name      string
+	CreatedBy *string
+	CreatedAt time.Time
+	Members   []Member
+}
+
+type Member struct {
+	Login   string
+	IsAdmin bool
+}
+
+func IsValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// SplitGrants separates grant targets into user logins and group names without prefix.
+// Repeated targets are returned once.
+func SplitGrants(targets []string) (logins, groups []string) {
+	for _, target := range targets {
+		name, isGroup := strings.CutPrefix(target, GrantPrefix)
+		if isGroup {
+			if !slices.Contains(groups, name) {
+				groups = append(groups, name)
+			}
+			continue
+		}
+
+		if !slices.Contains(logins, target) {
+			logins = append(logins, target)
+		}
+	}
+
+	return logins, groups
+}
+
+// GrantTargets returns group names as grant targets.
+func GrantTargets(groups []string) []string {
+	targets := make([]string, 0, len(groups))
+	for _, name := range groups {
+		targets = append(targets, GrantPrefix+name)
+	}
+
+	return targets
+}
+
+func (g Group) Member(login string) (Member, bool) {
+	for _, member := range g.Members {
+		if member.Login == login {
+			return member, true
+		}
+	}
+
+	return Member{}, false
+}
+
+func (g Group) IsAdmin(login string) bool {
+	member, ok := g.Member(login)
+	return ok && member.IsAdmin
+}
@@ -0,0 +1,44 @@
+package invite
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCode = errors.New("invite code is invalid, expired, revoked or used up")
+
+type Code struct {
+	ID        string
+	Role      string
+	MaxUses   int
+	Uses      int
+	CreatedBy *string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+// GenerateCode returns random human-readable invite code.
+func GenerateCode() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// HashCode returns invite code digest to be stored at rest.
+func HashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c Code) IsActive(now time.Time) bool {
+	return c.RevokedAt == nil && c.Uses < c.MaxUses && now.Before(c.ExpiresAt)
+}
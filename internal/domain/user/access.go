@@ -0,0 +1,191 @@
+package user
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RefreshTokenPrefix tells refresh tokens from session tokens, so refresh token
+// can't be used to authorize requests.
+const RefreshTokenPrefix = "rt_"
+
+var ErrInvalidAccessToken = errors.New("invalid access token")
+
+// accessTokenHeader is a fixed JWT header. Tokens with other headers are rejected.
+var accessTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"EdDSA","typ":"JWT"}`))
+
+// AccessClaims are claims of stateless access token. Token is bound to session
+// which holds refresh token.
+type AccessClaims struct {
+	Subject            string `json:"sub"`
+	SessionID          string `json:"sid"`
+	Role               string `json:"rol"`
+	MustChangePassword bool   `json:"mcp,omitempty"`
+	// IssuedAt has millisecond precision to order tokens with session revocations.
+	IssuedAt  float64 `json:"iat"`
+	ExpiresAt int64   `json:"exp"`
+}
+
+func NewAccessClaims(user User, sessionID string, now time.Time, ttl time.Duration) AccessClaims {
+	return AccessClaims{
+		Subject:            user.Login,
+		SessionID:          sessionID,
+		Role:               user.Role,
+		MustChangePassword: user.MustChangePassword,
+		IssuedAt:           float64(now.UnixMilli()) / 1000,
+		ExpiresAt:          now.Add(ttl).Unix(),
+	}
+}
+
+func (c AccessClaims) IssuedTime() time.Time {
+	return time.UnixMilli(int64(math.Round(c.IssuedAt * 1000)))
+}
+
+func (c AccessClaims) IsExpired(now time.Time) bool {
+	return now.Unix() >= c.ExpiresAt
+}
+
+// Principal returns principal authorized by access token.
+func (c AccessClaims) Principal() Principal {
+	return Principal{
+		User: User{
+			Login:              c.Subject,
+			Role:               c.Role,
+			MustChangePassword: c.MustChangePassword,
+		},
+		Session: AuthToken{
+			ID:        c.SessionID,
+			ExpiresAt: time.Unix(c.ExpiresAt, 0),
+		},
+	}
+}
+
+// IsAccessToken reports if token looks like signed access token rather than session token.
+func IsAccessToken(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func IsRefreshToken(token string) bool {
+	return strings.HasPrefix(token, RefreshTokenPrefix)
+}
+
+func GenerateRefreshToken() string {
+	return RefreshTokenPrefix + GenerateAuthToken()
+}
+
+// AccessTokenSigner signs and verifies access tokens as EdDSA JWT.
+type AccessTokenSigner struct {
+	key ed25519.PrivateKey
+}
+
+// NewAccessTokenSigner parses base64 encoded Ed25519 private key or its 32-byte seed.
+func NewAccessTokenSigner(encodedKey string) (AccessTokenSigner, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return AccessTokenSigner{}, fmt.Errorf("signing key decoding: %w", err)
+	}
+
+	switch len(key) {
+	case ed25519.SeedSize:
+		return AccessTokenSigner{key: ed25519.NewKeyFromSeed(key)}, nil
+	case ed25519.PrivateKeySize:
+		return AccessTokenSigner{key: ed25519.PrivateKey(key)}, nil
+	default:
+		return AccessTokenSigner{}, fmt.Errorf("signing key must be %d or %d bytes long", ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+}
+
+func (s AccessTokenSigner) Sign(claims AccessClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := accessTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := ed25519.Sign(s.key, []byte(signingInput))
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks token signature and expiration and returns its claims.
+func (s AccessTokenSigner) Verify(token string, now time.Time) (AccessClaims, error) {
+	var claims AccessClaims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != accessTokenHeader {
+		return claims, ErrInvalidAccessToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, ErrInvalidAccessToken
+	}
+
+	publicKey := s.key.Public().(ed25519.PublicKey)
+	if !ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature) {
+		return claims, ErrInvalidAccessToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, ErrInvalidAccessToken
+	}
+
+	err = json.Unmarshal(payload, &claims)
+	if err != nil || claims.Subject == "" || claims.SessionID == "" {
+		return claims, ErrInvalidAccessToken
+	}
+
+	if claims.IsExpired(now) {
+		return claims, ErrAuthTokenExpired
+	}
+
+	return claims, nil
+}
+
+// denylistSkewMargin covers database round trip while clock offset is measured.
+// Tokens issued that close after revocation are rejected too, refresh issues new ones.
+const denylistSkewMargin = time.Second
+
+// Denylist is an in-memory set of revoked sessions. Access tokens issued
+// for session before its revocation are rejected.
+type Denylist struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+	// clockOffset is database clock minus app clock. Revocation times come from
+	// database while tokens are issued with app clock.
+	clockOffset time.Duration
+}
+
+func NewDenylist() *Denylist {
+	return &Denylist{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Replace sets revoked sessions with their revocation times and current offset
+// of database clock from app clock.
+func (d *Denylist) Replace(revoked map[string]time.Time, clockOffset time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.revoked = revoked
+	d.clockOffset = clockOffset
+}
+
+func (d *Denylist) IsRevoked(claims AccessClaims) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	revokedAt, ok := d.revoked[claims.SessionID]
+	issuedAt := claims.IssuedTime().Add(d.clockOffset)
+
+	return ok && !issuedAt.After(revokedAt.Add(denylistSkewMargin))
+}
@@ -0,0 +1,89 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"slices"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix tells API keys from session tokens.
+const APIKeyPrefix = "ak_"
+
+const (
+	ScopeDocsRead   = "docs:read"
+	ScopeDocsWrite  = "docs:write"
+	ScopeDocsDelete = "docs:delete"
+	ScopeDocsShare  = "docs:share"
+)
+
+// APIKey is a long-lived scoped credential for automation.
+// Key may be limited to documents with listed IDs or tags.
+type APIKey struct {
+	ID          string
+	KeyHash     string
+	Name        string
+	Scopes      []string
+	DocumentIDs []string
+	Tags        []string
+	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+	LastUsedAt  *time.Time
+}
+
+func IsValidScope(scope string) bool {
+	switch scope {
+	case ScopeDocsRead, ScopeDocsWrite, ScopeDocsDelete, ScopeDocsShare:
+		return true
+	default:
+		return false
+	}
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// GenerateAPIKey returns random API key. Only its hash is stored.
+func GenerateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return APIKeyPrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)), nil
+}
+
+func (k APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// IsRestricted reports if key is limited to some documents.
+func (k APIKey) IsRestricted() bool {
+	return len(k.DocumentIDs) != 0 || len(k.Tags) != 0
+}
+
+// AllowsDocument reports if document with given ID and tags is within key restrictions.
+func (k APIKey) AllowsDocument(id string, tags []string) bool {
+	if !k.IsRestricted() {
+		return true
+	}
+
+	if id != "" && slices.Contains(k.DocumentIDs, id) {
+		return true
+	}
+
+	for _, tag := range tags {
+		if slices.Contains(k.Tags, tag) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,20 @@
+package user
+
+const (
+	DeletionModeTransfer = "transfer"
+	DeletionModeArchive  = "archive"
+	DeletionModePurge    = "purge"
+)
+
+// DeletionReport describes what account deletion affects.
+type DeletionReport struct {
+	OwnedDocuments int
+	// SharedDocuments are owned documents granted to other users.
+	SharedDocuments int
+	ReceivedGrants  int
+	Sessions        int
+}
+
+func IsValidDeletionMode(mode string) bool {
+	return mode == DeletionModeTransfer || mode == DeletionModeArchive || mode == DeletionModePurge
+}
@@ -0,0 +1,100 @@
+package user
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	IdentityProviderLocal = "local"
+	IdentityProviderLDAP  = "ldap"
+)
+
+var (
+	ErrIdentityClaimMissing = errors.New("identity claim missing")
+	ErrInvalidCredentials   = errors.New("invalid credentials")
+)
+
+// Identity is a user account at identity provider linked to astral user.
+// Local accounts have IdentityProviderLocal provider and login as subject.
+type Identity struct {
+	Provider string
+	Subject  string
+	Login    string
+	Groups   []string
+}
+
+// OIDCState is a pending OpenID Connect login started by redirect to provider.
+type OIDCState struct {
+	StateHash    string
+	CodeVerifier string
+	Nonce        string
+	Device       string
+	ExpiresAt    time.Time
+}
+
+func (s OIDCState) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// IdentityFromClaims maps ID token claims to identity. Login is taken from loginClaim,
+// groups from groupsClaim which may hold a list or a single string.
+func IdentityFromClaims(provider string, claims map[string]any, loginClaim, groupsClaim string) (Identity, error) {
+	subject, _ := claims["sub"].(string)
+	login, _ := claims[loginClaim].(string)
+	login = strings.TrimSpace(login)
+	if subject == "" || login == "" {
+		return Identity{}, ErrIdentityClaimMissing
+	}
+
+	var groups []string
+	switch value := claims[groupsClaim].(type) {
+	case string:
+		groups = []string{value}
+	case []any:
+		for _, group := range value {
+			if group, ok := group.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	}
+
+	return Identity{
+		Provider: provider,
+		Subject:  subject,
+		Login:    login,
+		Groups:   groups,
+	}, nil
+}
+
+// SyncRole returns role of user with current role by identity groups. groupRoles maps
+// group to role, the most privileged role of identity groups wins. Users in none
+// of the groups lose roles given by groups and get defaultRole, other roles are kept
+// as they were assigned by admin. New users with empty current role get defaultRole.
+func (i Identity) SyncRole(current, defaultRole string, groupRoles map[string]string) string {
+	var granted []string
+	for _, group := range i.Groups {
+		if role, ok := groupRoles[group]; ok {
+			granted = append(granted, role)
+		}
+	}
+
+	for _, role := range Roles {
+		if slices.Contains(granted, role) {
+			return role
+		}
+	}
+
+	for _, role := range groupRoles {
+		if role == current {
+			return defaultRole
+		}
+	}
+	if current == "" {
+		return defaultRole
+	}
+
+	return current
+}
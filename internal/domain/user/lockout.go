@@ -0,0 +1,48 @@
+package user
+
+import (
+	"time"
+)
+
+const (
+	LockoutKindLogin = "login"
+	LockoutKindIP    = "ip"
+)
+
+// Lockout is a failed auth attempts counter for login or client IP.
+type Lockout struct {
+	Kind          string
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+type LockoutPolicy struct {
+	// MaxAttempts is a number of failures after which key is locked for LockoutDuration.
+	MaxAttempts     int
+	BackoffBase     time.Duration
+	LockoutDuration time.Duration
+}
+
+func IsValidLockoutKind(kind string) bool {
+	return kind == LockoutKindLogin || kind == LockoutKindIP
+}
+
+// LockDuration returns how long key is locked after given number of failures.
+// Before MaxAttempts is reached delay grows exponentially from BackoffBase.
+func (p LockoutPolicy) LockDuration(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	if failures >= p.MaxAttempts {
+		return p.LockoutDuration
+	}
+
+	delay := p.BackoffBase << (failures - 1)
+	if delay <= 0 || delay > p.LockoutDuration {
+		return p.LockoutDuration
+	}
+
+	return delay
+}
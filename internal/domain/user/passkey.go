@@ -0,0 +1,53 @@
+package user
+
+import (
+	"crypto/rand"
+	"time"
+)
+
+const (
+	PasskeySessionRegistration = "registration"
+	PasskeySessionLogin        = "login"
+)
+
+// Passkey is a WebAuthn credential of user.
+type Passkey struct {
+	ID              string
+	CredentialID    []byte
+	Name            string
+	PublicKey       []byte
+	AttestationType string
+	Transports      []string
+	AAGUID          []byte
+	SignCount       uint32
+	BackupEligible  bool
+	BackupState     bool
+	CreatedAt       time.Time
+	LastUsedAt      *time.Time
+}
+
+// PasskeySession is a pending WebAuthn ceremony. Login is empty for discoverable login.
+type PasskeySession struct {
+	SessionHash string
+	Kind        string
+	Login       string
+	Data        []byte
+	Device      string
+	ExpiresAt   time.Time
+}
+
+func (s PasskeySession) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// GeneratePasskeyHandle returns random WebAuthn user handle. It is opaque to authenticator
+// and carries no personal data or user ID.
+func GeneratePasskeyHandle() ([]byte, error) {
+	handle := make([]byte, 32)
+	_, err := rand.Read(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	return handle, nil
+}
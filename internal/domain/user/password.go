@@ -1,66 +1,235 @@
 package user
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"log/slog"
-	"regexp"
+	"math/big"
+	"strings"
 
 	"github.com/srgklmv/astral/pkg/logger"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	passwordRegex             = "^[a-zA-Z0-9!&*.,#@$]{8,20}$"
-	passwordUppercaseRegex    = "^.*[A-Z]{1,}.*$"
-	passwordLowercaseRegex    = "^.*[a-z]{1,}.*$"
-	passwordSpecialCharsRegex = "^.*[!&*.,#@$]{1,}.*$"
-	passwordDigitRegex        = "^.*[0-9]{1,}.*$"
+	temporaryPasswordAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	temporaryPasswordLength   = 16
 )
 
-// Validate checks if given password meets secure requirements.
-func ValidatePassword(password string) (bool, error) {
-	matched1, err := regexp.MatchString(passwordRegex, password)
-	if err != nil {
-		logger.Error("regex compilation error", slog.String("error", err.Error()))
-		return false, err
+const (
+	HashAlgorithmArgon2id = "argon2id"
+	HashAlgorithmBcrypt   = "bcrypt"
+)
+
+var ErrUnknownHashAlgorithm = errors.New("unknown password hash algorithm")
+
+// HashParams are password hashing parameters. Argon2 memory is in KiB.
+type HashParams struct {
+	Algorithm         string
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	Argon2SaltLength  uint32
+	Argon2KeyLength   uint32
+	BcryptCost        int
+}
+
+func DefaultHashParams() HashParams {
+	return HashParams{
+		Algorithm:         HashAlgorithmArgon2id,
+		Argon2Memory:      64 * 1024,
+		Argon2Iterations:  3,
+		Argon2Parallelism: 2,
+		Argon2SaltLength:  16,
+		Argon2KeyLength:   32,
+		BcryptCost:        bcrypt.DefaultCost,
 	}
+}
 
-	matched2, err := regexp.MatchString(passwordUppercaseRegex, password)
-	if err != nil {
-		logger.Error("regex compilation error", slog.String("error", err.Error()))
-		return false, err
+func (p HashParams) Validate() error {
+	switch p.Algorithm {
+	case HashAlgorithmArgon2id:
+		if p.Argon2Memory == 0 || p.Argon2Iterations == 0 || p.Argon2Parallelism == 0 ||
+			p.Argon2SaltLength == 0 || p.Argon2KeyLength == 0 {
+			return errors.New("argon2id parameters must be positive")
+		}
+	case HashAlgorithmBcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return ErrUnknownHashAlgorithm
 	}
 
-	matched3, err := regexp.MatchString(passwordLowercaseRegex, password)
-	if err != nil {
-		logger.Error("regex compilation error", slog.String("error", err.Error()))
-		return false, err
+	return nil
+}
+
+// HashPassword hashes password with configured algorithm.
+// Argon2id hashes are stored in PHC string format:
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>.
+// Bcrypt hashes keep their own modular crypt format ($2a$<cost>$...).
+func (p HashParams) HashPassword(password string) (string, error) {
+	switch p.Algorithm {
+	case HashAlgorithmArgon2id:
+		salt := make([]byte, p.Argon2SaltLength)
+		_, err := rand.Read(salt)
+		if err != nil {
+			logger.Error("random generation error", slog.String("error", err.Error()))
+			return "", err
+		}
+
+		key := argon2.IDKey([]byte(password), salt, p.Argon2Iterations, p.Argon2Memory, p.Argon2Parallelism, p.Argon2KeyLength)
+
+		return argon2Hash{
+			memory:      p.Argon2Memory,
+			iterations:  p.Argon2Iterations,
+			parallelism: p.Argon2Parallelism,
+			salt:        salt,
+			key:         key,
+		}.String(), nil
+	case HashAlgorithmBcrypt:
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+		if err != nil {
+			logger.Error("password hashing error", slog.String("error", err.Error()))
+			return "", err
+		}
+
+		return string(hashed), nil
+	default:
+		return "", ErrUnknownHashAlgorithm
+	}
+}
+
+// NeedsRehash reports if hashed password was made with other algorithm or parameters
+// than configured ones. Unknown formats never need rehash as they can't be verified.
+func (p HashParams) NeedsRehash(hashedPassword string) bool {
+	switch {
+	case strings.HasPrefix(hashedPassword, "$argon2id$"):
+		h, err := parseArgon2Hash(hashedPassword)
+		if err != nil {
+			return false
+		}
+
+		return p.Algorithm != HashAlgorithmArgon2id ||
+			h.memory != p.Argon2Memory ||
+			h.iterations != p.Argon2Iterations ||
+			h.parallelism != p.Argon2Parallelism ||
+			uint32(len(h.salt)) != p.Argon2SaltLength ||
+			uint32(len(h.key)) != p.Argon2KeyLength
+	case isBcryptHash(hashedPassword):
+		cost, err := bcrypt.Cost([]byte(hashedPassword))
+		if err != nil {
+			return false
+		}
+
+		return p.Algorithm != HashAlgorithmBcrypt || cost != p.BcryptCost
+	default:
+		return false
+	}
+}
+
+// IsValidPassword checks password against hash of any supported format.
+func IsValidPassword(password, hashedPassword string) bool {
+	switch {
+	case strings.HasPrefix(hashedPassword, "$argon2id$"):
+		h, err := parseArgon2Hash(hashedPassword)
+		if err != nil {
+			logger.Error("argon2id hash parsing error", slog.String("error", err.Error()))
+			return false
+		}
+
+		key := argon2.IDKey([]byte(password), h.salt, h.iterations, h.memory, h.parallelism, uint32(len(h.key)))
+
+		return subtle.ConstantTimeCompare(key, h.key) == 1
+	case isBcryptHash(hashedPassword):
+		return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) == nil
+	default:
+		return false
 	}
+}
+
+func isBcryptHash(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$2a$") ||
+		strings.HasPrefix(hashedPassword, "$2b$") ||
+		strings.HasPrefix(hashedPassword, "$2y$")
+}
 
-	matched4, err := regexp.MatchString(passwordSpecialCharsRegex, password)
+type argon2Hash struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h argon2Hash) String() string {
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.memory,
+		h.iterations,
+		h.parallelism,
+		base64.RawStdEncoding.EncodeToString(h.salt),
+		base64.RawStdEncoding.EncodeToString(h.key),
+	)
+}
+
+func parseArgon2Hash(hashedPassword string) (argon2Hash, error) {
+	var h argon2Hash
+
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 {
+		return h, errors.New("malformed argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
 	if err != nil {
-		logger.Error("regex compilation error", slog.String("error", err.Error()))
-		return false, err
+		return h, err
+	}
+	if version != argon2.Version {
+		return h, fmt.Errorf("unsupported argon2 version %d", version)
 	}
 
-	matched5, err := regexp.MatchString(passwordDigitRegex, password)
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.iterations, &h.parallelism)
 	if err != nil {
-		logger.Error("regex compilation error", slog.String("error", err.Error()))
-		return false, err
+		return h, err
 	}
 
-	return matched1 && matched2 && matched3 && matched4 && matched5, nil
-}
+	h.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return h, err
+	}
 
-func HashPassword(password string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	h.key, err = base64.RawStdEncoding.DecodeString(parts[5])
 	if err != nil {
-		logger.Error("password hashing error", slog.String("error", err.Error()))
-		return "", err
+		return h, err
+	}
+	if len(h.key) == 0 {
+		return h, errors.New("empty argon2id key")
 	}
 
-	return string(hashed), nil
+	return h, nil
 }
 
-func IsValidPassword(password, hashedPassword string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) == nil
+// GenerateTemporaryPassword returns random password to be changed at next login.
+func GenerateTemporaryPassword() (string, error) {
+	password := make([]byte, temporaryPasswordLength)
+	max := big.NewInt(int64(len(temporaryPasswordAlphabet)))
+
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			logger.Error("random generation error", slog.String("error", err.Error()))
+			return "", err
+		}
+
+		password[i] = temporaryPasswordAlphabet[n.Int64()]
+	}
+
+	return string(password), nil
 }
@@ -0,0 +1,285 @@
+package user
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+const defaultSpecialChars = "!&*.,#@$"
+
+// Character set sizes used for password entropy estimation.
+const (
+	lowerCharsetSize   = 26
+	upperCharsetSize   = 26
+	digitCharsetSize   = 10
+	specialCharsetSize = 32
+	spaceCharsetSize   = 1
+	unicodeCharsetSize = 100
+)
+
+type LoginPolicy struct {
+	MinLength    int
+	MaxLength    int
+	AllowUnicode bool
+	// SpecialChars are allowed in login along with letters and digits.
+	SpecialChars string
+}
+
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// MaxBytes limits password length in bytes, zero means no limit.
+	// Bcrypt can not hash passwords longer than 72 bytes.
+	MaxBytes       int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	// SpecialChars are allowed special symbols. Any punctuation or symbol is allowed if empty.
+	SpecialChars string
+	AllowUnicode bool
+	AllowSpaces  bool
+	// PassphraseMinLength enables passphrase mode: passwords of at least this length
+	// are not checked for required character classes.
+	PassphraseMinLength int
+	// MinEntropy is a minimal estimated password entropy in bits.
+	MinEntropy float64
+	DenyList   map[string]struct{}
+}
+
+func DefaultLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		MinLength: 8,
+		MaxLength: 20,
+	}
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:      8,
+		MaxLength:      20,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSpecial: true,
+		SpecialChars:   defaultSpecialChars,
+	}
+}
+
+// LoadDenyList reads breached passwords file, one password per line.
+func LoadDenyList(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		logger.Error("open deny list file error", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer file.Close()
+
+	denyList := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			denyList[line] = struct{}{}
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		logger.Error("read deny list file error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return denyList, nil
+}
+
+// Validate checks if login meets policy.
+func (p LoginPolicy) Validate(login string) bool {
+	length := utf8.RuneCountInString(login)
+	if length < p.MinLength || length > p.MaxLength {
+		return false
+	}
+
+	// Letters of one script only, so lookalikes like Cyrillic "а" in "аdmin" are rejected.
+	var script string
+	for _, r := range login {
+		switch {
+		case r < utf8.RuneSelf && (isASCIILetter(r) || unicode.IsDigit(r)):
+		case p.AllowUnicode && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+		case strings.ContainsRune(p.SpecialChars, r):
+		default:
+			return false
+		}
+
+		if runeScript := scriptOf(r); runeScript != "" {
+			if script != "" && script != runeScript {
+				return false
+			}
+			script = runeScript
+		}
+	}
+
+	return true
+}
+
+// scriptOf returns Unicode script of rune. Characters shared by scripts, like digits
+// and punctuation, have no script.
+func scriptOf(r rune) string {
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) || r < utf8.RuneSelf && unicode.IsDigit(r) {
+		return ""
+	}
+
+	for name, table := range unicode.Scripts {
+		if name != "Common" && name != "Inherited" && unicode.Is(table, r) {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// Description returns human-readable policy requirements.
+func (p LoginPolicy) Description() string {
+	letters := "latin letters"
+	if p.AllowUnicode {
+		letters = "letters of one script"
+	}
+
+	if p.SpecialChars == "" {
+		return fmt.Sprintf("Login length must be between %d and %d and contain only %s and digits.", p.MinLength, p.MaxLength, letters)
+	}
+
+	return fmt.Sprintf("Login length must be between %d and %d and contain only %s, digits and symbols (%s).", p.MinLength, p.MaxLength, letters, p.SpecialChars)
+}
+
+// Validate checks if password meets policy. Reason of rejection is returned.
+func (p PasswordPolicy) Validate(password string) (bool, string) {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength || length > p.MaxLength || p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		return false, p.Description()
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial, hasSpace, hasUnicode bool
+	for _, r := range password {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			hasUpper = true
+		case r >= 'a' && r <= 'z':
+			hasLower = true
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r == ' ':
+			if !p.AllowSpaces {
+				return false, p.Description()
+			}
+			hasSpace = true
+		case p.isSpecial(r):
+			hasSpecial = true
+		case p.AllowUnicode && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			hasUnicode = true
+			hasUpper = hasUpper || unicode.IsUpper(r)
+			hasLower = hasLower || unicode.IsLower(r)
+			hasDigit = hasDigit || unicode.IsDigit(r)
+		default:
+			return false, p.Description()
+		}
+	}
+
+	isPassphrase := p.PassphraseMinLength > 0 && length >= p.PassphraseMinLength
+	if !isPassphrase {
+		switch {
+		case p.RequireUpper && !hasUpper,
+			p.RequireLower && !hasLower,
+			p.RequireDigit && !hasDigit,
+			p.RequireSpecial && !hasSpecial:
+			return false, p.Description()
+		}
+	}
+
+	if p.MinEntropy > 0 {
+		var charsetSize int
+		for _, class := range []struct {
+			present bool
+			size    int
+		}{
+			{hasLower, lowerCharsetSize},
+			{hasUpper, upperCharsetSize},
+			{hasDigit, digitCharsetSize},
+			{hasSpecial, specialCharsetSize},
+			{hasSpace, spaceCharsetSize},
+			{hasUnicode, unicodeCharsetSize},
+		} {
+			if class.present {
+				charsetSize += class.size
+			}
+		}
+
+		if float64(length)*math.Log2(float64(charsetSize)) < p.MinEntropy {
+			return false, "Password is too weak."
+		}
+	}
+
+	if _, ok := p.DenyList[password]; ok {
+		return false, "Password is too common."
+	}
+
+	return true, ""
+}
+
+// Description returns human-readable policy requirements.
+func (p PasswordPolicy) Description() string {
+	var required []string
+	if p.RequireUpper {
+		required = append(required, "one upper case letter")
+	}
+	if p.RequireLower {
+		required = append(required, "one lower case letter")
+	}
+	if p.RequireDigit {
+		required = append(required, "one digit")
+	}
+	if p.RequireSpecial {
+		special := "one special symbol"
+		if p.SpecialChars != "" {
+			special += fmt.Sprintf(" (%s)", p.SpecialChars)
+		}
+		required = append(required, special)
+	}
+
+	description := fmt.Sprintf("Password length must be between %d and %d", p.MinLength, p.MaxLength)
+	if p.MaxBytes > 0 {
+		description += fmt.Sprintf(" and not exceed %d bytes", p.MaxBytes)
+	}
+	if len(required) != 0 {
+		description += ", contains at least " + strings.Join(required, ", ")
+		if p.PassphraseMinLength > 0 {
+			description += fmt.Sprintf(" unless it is a passphrase of at least %d characters", p.PassphraseMinLength)
+		}
+	}
+	if !p.AllowSpaces {
+		description += ", without spaces"
+	}
+
+	return description + "."
+}
+
+func (p PasswordPolicy) isSpecial(r rune) bool {
+	if p.SpecialChars == "" {
+		return r < utf8.RuneSelf && (unicode.IsPunct(r) || unicode.IsSymbol(r))
+	}
+
+	return strings.ContainsRune(p.SpecialChars, r)
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
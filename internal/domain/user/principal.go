@@ -0,0 +1,34 @@
+package user
+
+import (
+	"context"
+)
+
+type principalContextKey struct{}
+
+// Principal is an authorized user along with session or API key it was authorized by.
+type Principal struct {
+	User
+	Session AuthToken
+	APIKey  *APIKey
+}
+
+// HasScope reports if principal may perform actions of given scope.
+// Sessions are not limited by scopes.
+func (p Principal) HasScope(scope string) bool {
+	return p.APIKey == nil || p.APIKey.HasScope(scope)
+}
+
+// AllowsDocument reports if document is within principal API key restrictions.
+func (p Principal) AllowsDocument(id string, tags []string) bool {
+	return p.APIKey == nil || p.APIKey.AllowsDocument(id, tags)
+}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
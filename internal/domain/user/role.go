@@ -0,0 +1,64 @@
+package user
+
+import (
+	"slices"
+)
+
+const (
+	RoleAdmin    = "admin"
+	RoleEditor   = "editor"
+	RoleViewer   = "viewer"
+	RoleAuditor  = "auditor"
+	RoleUploader = "uploader"
+)
+
+// Permissions on own, granted and public documents are suffixed with :any
+// if they extend to every document.
+const (
+	PermissionDocsRead      = "docs:read"
+	PermissionDocsWrite     = "docs:write"
+	PermissionDocsDelete    = "docs:delete"
+	PermissionDocsShare     = "docs:share"
+	PermissionDocsReadAny   = "docs:read:any"
+	PermissionDocsDeleteAny = "docs:delete:any"
+	PermissionDocsShareAny  = "docs:share:any"
+	PermissionUsersManage   = "users:manage"
+)
+
+// Roles lists roles in order from the most privileged one.
+var Roles = []string{RoleAdmin, RoleEditor, RoleAuditor, RoleViewer, RoleUploader}
+
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionDocsRead,
+		PermissionDocsWrite,
+		PermissionDocsDelete,
+		PermissionDocsShare,
+		PermissionDocsReadAny,
+		PermissionDocsDeleteAny,
+		PermissionDocsShareAny,
+		PermissionUsersManage,
+	},
+	RoleEditor:   {PermissionDocsRead, PermissionDocsWrite, PermissionDocsDelete, PermissionDocsShare},
+	RoleAuditor:  {PermissionDocsRead, PermissionDocsReadAny},
+	RoleViewer:   {PermissionDocsRead},
+	RoleUploader: {PermissionDocsWrite},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RolePermissions returns permissions granted by role. Unknown role grants nothing.
+func RolePermissions(role string) []string {
+	return slices.Clone(rolePermissions[role])
+}
+
+func (u User) HasPermission(permission string) bool {
+	return slices.Contains(rolePermissions[u.Role], permission)
+}
+
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
@@ -1,9 +1,51 @@
 package user
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
 	"github.com/google/uuid"
 )
 
+var ErrAuthTokenExpired = errors.New("auth token expired")
+
+// AuthToken is a user session.
+type AuthToken struct {
+	ID         string
+	TokenHash  string
+	Device     string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt *time.Time
+}
+
 func GenerateAuthToken() string {
 	return uuid.New().String()
 }
+
+// HashAuthToken returns auth token digest to be stored at rest.
+// HMAC-SHA256 is used when pepper is set, plain SHA-256 otherwise.
+func HashAuthToken(token, pepper string) string {
+	if pepper == "" {
+		sum := sha256.Sum256([]byte(token))
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, []byte(pepper))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (t AuthToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// ShouldRenew reports if token is used within renewal window before its expiration.
+func (t AuthToken) ShouldRenew(now time.Time, renewalWindow time.Duration) bool {
+	return renewalWindow > 0 && t.ExpiresAt.Sub(now) <= renewalWindow
+}
@@ -0,0 +1,164 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew is a number of periods before and after current one accepted to tolerate clock drift.
+	totpSkew = 1
+
+	recoveryCodeLength = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP is a RFC 6238 second factor. It is enabled after enrollment is confirmed with a code.
+type TOTP struct {
+	Secret      string
+	CreatedAt   time.Time
+	ConfirmedAt *time.Time
+	// LastUsedStep is a time step of last accepted code. Codes of this and previous steps are rejected.
+	LastUsedStep int64
+}
+
+// AuthChallenge is a short-lived proof of passed password check waiting for second factor.
+type AuthChallenge struct {
+	ChallengeHash string
+	Login         string
+	Device        string
+	Attempts      int
+	ExpiresAt     time.Time
+}
+
+func (t TOTP) IsEnrolled() bool {
+	return t.Secret != ""
+}
+
+func (t TOTP) IsEnabled() bool {
+	return t.Secret != "" && t.ConfirmedAt != nil
+}
+
+// GenerateTOTPSecret returns random base32 encoded 160-bit secret.
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPURI returns otpauth URI to be shown as QR code by authenticator apps.
+func TOTPURI(issuer, login, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + login,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+// Validate checks code against current time step with allowed skew.
+// It returns matched time step to be stored as LastUsedStep.
+func (t TOTP) Validate(code string, now time.Time) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	key, err := totpEncoding.DecodeString(strings.ToUpper(t.Secret))
+	if err != nil {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= t.LastUsedStep {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// hotp returns RFC 4226 one-time password for counter.
+func hotp(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// IsTOTPCode reports if code looks like TOTP code rather than recovery code.
+func IsTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GenerateRecoveryCodes returns n random one-time recovery codes formatted as XXXXX-XXXXX.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+
+	for i := range codes {
+		b := make([]byte, recoveryCodeLength*5/8)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+
+		code := totpEncoding.EncodeToString(b)
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+	}
+
+	return codes, nil
+}
+
+// HashRecoveryCode returns recovery code digest to be stored at rest.
+// Case, spaces and dashes are ignored.
+func HashRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,7 +1,30 @@
 package user
 
+import (
+	"errors"
+	"time"
+)
+
+var ErrUserDisabled = errors.New("user disabled")
+
+// ErrArchiveLoginTaken means archive login belongs to regular account, which must not
+// receive documents of deleted users.
+var ErrArchiveLoginTaken = errors.New("archive login is taken by regular account")
+
+// ErrLastAdmin means change would leave no enabled admin to manage users.
+var ErrLastAdmin = errors.New("no enabled admin left")
+
 type User struct {
-	ID      int
-	Login   string
-	IsAdmin bool
+	ID                 int
+	Login              string
+	Role               string
+	IsDisabled         bool
+	MustChangePassword bool
+}
+
+// Summary is user data for administration.
+type Summary struct {
+	User
+	DocumentsCount int
+	LastLoginAt    *time.Time
 }
@@ -21,12 +21,43 @@ const (
 
 // Auth blocks.
 const (
-	RegisterBadLoginErrorText     ErrorText = "Login length must be between 8 and 20."
-	RegisterLoginTakenErrorText   ErrorText = "Login already taken."
-	RegisterBadPasswordErrorText  ErrorText = "Password length must be between 8 and 20, contains at least one upper and one lower case letter, one digit and one special symbol (!&*.,#@$)."
-	AuthWrongCredentialsErrorText ErrorText = "Wrong credentials."
-	UnauthorizedErrorText         ErrorText = "Unauthorized."
-	ForbiddenErrorText            ErrorText = "Access forbidden."
+	RegisterLoginTakenErrorText        ErrorText = "Login already taken."
+	AuthWrongCredentialsErrorText      ErrorText = "Wrong credentials."
+	UnauthorizedErrorText              ErrorText = "Unauthorized."
+	ForbiddenErrorText                 ErrorText = "Access forbidden."
+	AuthTokenExpiredErrorText          ErrorText = "Auth token expired."
+	SessionIDNotProvidedErrorText      ErrorText = "No session ID provided."
+	SessionNotFoundErrorText           ErrorText = "Session not found."
+	InviteCodeInvalidErrorText         ErrorText = "Invalid invite code."
+	WrongCurrentPasswordErrorText      ErrorText = "Wrong current password."
+	PasswordChangeRequiredErrorText    ErrorText = "Password change required."
+	ExternalPasswordErrorText          ErrorText = "Account is authenticated by external provider, its password can't be changed here."
+	UserDisabledErrorText              ErrorText = "Account disabled."
+	AuthLockedErrorText                ErrorText = "Too many failed attempts. Try again later."
+	TwoFactorCodeInvalidErrorText      ErrorText = "Invalid two-factor code."
+	TwoFactorChallengeInvalidErrorText ErrorText = "Two-factor challenge is invalid or expired. Log in again."
+	TwoFactorAlreadyEnabledErrorText   ErrorText = "Two-factor authentication is already enabled."
+	TwoFactorNotEnrolledErrorText      ErrorText = "Two-factor authentication enrollment not started."
+	TwoFactorNotEnabledErrorText       ErrorText = "Two-factor authentication is not enabled."
+	APIKeyNameRequiredErrorText        ErrorText = "API key name required."
+	BadAPIKeyScopeErrorText            ErrorText = "API key scopes must be any of: docs:read, docs:write, docs:delete, docs:share."
+	BadAPIKeyTTLErrorText              ErrorText = "API key TTL must not be negative."
+	BadAPIKeyDocumentErrorText         ErrorText = "API key documents must be valid document IDs."
+	APIKeyNotFoundErrorText            ErrorText = "API key not found."
+	APIKeyScopeErrorText               ErrorText = "API key scope does not allow this action."
+	APIKeyNotAllowedErrorText          ErrorText = "Not available with API key. Log in with password."
+	AccessTokensDisabledErrorText      ErrorText = "Access tokens are disabled."
+	OIDCDisabledErrorText              ErrorText = "OpenID Connect login is disabled."
+	OIDCStateInvalidErrorText          ErrorText = "Login state is invalid or expired. Start login again."
+	OIDCLoginFailedErrorText           ErrorText = "Identity provider login failed."
+	IdentityInvalidErrorText           ErrorText = "Identity provider returned no valid login."
+	IdentityLoginTakenErrorText        ErrorText = "Login belongs to local account not linked to identity provider."
+	IdentityNotProvisionedErrorText    ErrorText = "Account is not provisioned. Chat support, please."
+	PasskeysDisabledErrorText          ErrorText = "Passkeys are disabled."
+	PasskeySessionInvalidErrorText     ErrorText = "Passkey ceremony is invalid or expired. Start again."
+	PasskeyInvalidErrorText            ErrorText = "Passkey verification failed."
+	PasskeyNameRequiredErrorText       ErrorText = "Passkey name required."
+	PasskeyNotFoundErrorText           ErrorText = "Passkey not found."
 )
 
 const (
@@ -36,6 +67,11 @@ const (
 	UnauthorizedErrorCode
 	ForbiddenErrorCode
 	AuthInternalErrorCode
+	AuthTokenExpiredErrorCode
+	PasswordChangeRequiredErrorCode
+	UserDisabledErrorCode
+	AuthLockedErrorCode
+	IdentityProviderErrorCode
 )
 
 // Document blocks.
@@ -48,8 +84,53 @@ const (
 	BadIDProvidedErrorText         ErrorText = "Invalid document ID."
 	FileNotProvidedErrorText       ErrorText = "File not provided."
 	JSONNotProvidedErrorText       ErrorText = "JSON not provided."
+	DocumentNotJSONErrorText       ErrorText = "Only JSON documents can be patched."
+	PatchMediaTypeErrorText        ErrorText = "Patch media type must be application/merge-patch+json or application/json-patch+json."
+	InvalidPatchErrorText          ErrorText = "Invalid patch."
+	PatchFailedErrorText           ErrorText = "Patch can not be applied to document."
+	PatchedNotObjectErrorText      ErrorText = "Patched document must be a JSON object."
+	BadVersionProvidedErrorText    ErrorText = "Invalid document version."
+	VersionNotFoundErrorText       ErrorText = "Document version not found."
+	GrantTargetsInvalidErrorText   ErrorText = "Some grant targets are invalid."
+	GrantsNotProvidedErrorText     ErrorText = "No grant targets provided."
+	BadGrantLevelErrorText         ErrorText = "Grant level must be one of read, comment, write, delete and share."
+	BadShareLinkTTLErrorText       ErrorText = "Share link TTL must be positive and not exceed configured maximum."
+	BadShareLinkDownloadsErrorText ErrorText = "Share link download limit must not be negative."
+	BadShareLinkPasswordErrorText  ErrorText = "Share link password must not exceed 72 bytes."
+	ShareLinkNotFoundErrorText     ErrorText = "Share link not found, expired, revoked or used up."
+	ShareLinkPasswordErrorText     ErrorText = "Share link password required or wrong."
 )
 
 const (
 	DocumentUploadingErrorCode ErrorCode = 300 + iota
 )
+
+// Admin blocks.
+const (
+	InviteCodeNotFoundErrorText ErrorText = "Invite code not found."
+	BadRoleErrorText            ErrorText = "Unknown role."
+	BadInviteUsesErrorText      ErrorText = "Invite code uses must be positive."
+	BadInviteTTLErrorText       ErrorText = "Invite code TTL must be positive."
+	UserNotFoundErrorText       ErrorText = "User not found."
+	BadDeletionModeErrorText    ErrorText = "Deletion mode must be one of: transfer, archive, purge."
+	BadTransferTargetErrorText  ErrorText = "Documents must be transferred to another existing user."
+	LastAdminDeletionErrorText  ErrorText = "The last admin can not be deleted."
+	LastAdminDemotionErrorText  ErrorText = "The last admin can not be demoted or disabled."
+	EmptyUserUpdateErrorText    ErrorText = "Nothing to update, set role or disabled."
+	AdminFlagConflictErrorText  ErrorText = "Admin flag conflicts with role."
+	LockoutNotFoundErrorText    ErrorText = "Lockout not found."
+	BadLockoutKindErrorText     ErrorText = "Lockout kind must be one of: login, ip."
+)
+
+const (
+	InviteCodeGenerationErrorCode ErrorCode = 400 + iota
+)
+
+// Group blocks.
+const (
+	GroupNameInvalidErrorText    ErrorText = "Group name must be 1-64 lowercase letters, digits, dots, dashes or underscores."
+	GroupExistsErrorText         ErrorText = "Group already exists."
+	GroupNotFoundErrorText       ErrorText = "Group not found."
+	GroupMemberNotFoundErrorText ErrorText = "Group member not found."
+	LastGroupAdminErrorText      ErrorText = "The last group admin can not be removed or demoted."
+)
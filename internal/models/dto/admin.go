@@ -0,0 +1,215 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/srgklmv/astral/internal/domain/invite"
+	"github.com/srgklmv/astral/internal/domain/user"
+)
+
+type (
+	CreateInviteCodeRequest struct {
+		Role string `json:"role"`
+		Uses int    `json:"uses"`
+		// TTL is invite code lifetime in seconds.
+		TTL int `json:"ttl"`
+	}
+	CreateInviteCodeResponse struct {
+		InviteCode
+		Code string `json:"code"`
+	}
+)
+
+type GetInviteCodesResponse struct {
+	InviteCodes []InviteCode `json:"invites"`
+}
+
+func NewGetInviteCodesResponse() GetInviteCodesResponse {
+	return GetInviteCodesResponse{
+		InviteCodes: make([]InviteCode, 0),
+	}
+}
+
+func (r GetInviteCodesResponse) FromDomain(codes []invite.Code) GetInviteCodesResponse {
+	for _, v := range codes {
+		r.InviteCodes = append(r.InviteCodes, NewInviteCode(v))
+	}
+
+	return r
+}
+
+type InviteCode struct {
+	ID        string `json:"id"`
+	Role      string `json:"role"`
+	MaxUses   int    `json:"maxUses"`
+	Uses      int    `json:"uses"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	CreatedAt string `json:"created"`
+	ExpiresAt string `json:"expires"`
+	Active    bool   `json:"active"`
+}
+
+func NewInviteCode(code invite.Code) InviteCode {
+	dto := InviteCode{
+		ID:        code.ID,
+		Role:      code.Role,
+		MaxUses:   code.MaxUses,
+		Uses:      code.Uses,
+		CreatedAt: code.CreatedAt.Format(time.DateTime),
+		ExpiresAt: code.ExpiresAt.Format(time.DateTime),
+		Active:    code.IsActive(time.Now()),
+	}
+	if code.CreatedBy != nil {
+		dto.CreatedBy = *code.CreatedBy
+	}
+
+	return dto
+}
+
+type RevokeInviteCodeResponse map[string]bool
+
+type ResetUserPasswordResponse struct {
+	Login    string `json:"login"`
+	Password string `json:"pswd"`
+}
+
+type ResetUserTwoFactorResponse map[string]bool
+
+type (
+	GetUsersRequest struct {
+		Search string `query:"search"`
+		Limit  int    `query:"limit"`
+		Offset int    `query:"offset"`
+	}
+	GetUsersResponse struct {
+		Users []UserData `json:"users"`
+		Total int        `json:"total"`
+	}
+)
+
+func NewGetUsersResponse() GetUsersResponse {
+	return GetUsersResponse{
+		Users: make([]UserData, 0),
+	}
+}
+
+func (r GetUsersResponse) FromDomain(users []user.Summary, total int) GetUsersResponse {
+	for _, v := range users {
+		data := UserData{
+			Login:              v.Login,
+			Role:               v.Role,
+			IsAdmin:            v.IsAdmin(),
+			IsDisabled:         v.IsDisabled,
+			MustChangePassword: v.MustChangePassword,
+			DocumentsCount:     v.DocumentsCount,
+		}
+		if v.LastLoginAt != nil {
+			data.LastLoginAt = v.LastLoginAt.Format(time.DateTime)
+		}
+
+		r.Users = append(r.Users, data)
+	}
+	r.Total = total
+
+	return r
+}
+
+type UserData struct {
+	Login              string `json:"login"`
+	Role               string `json:"role"`
+	IsAdmin            bool   `json:"admin"`
+	IsDisabled         bool   `json:"disabled"`
+	MustChangePassword bool   `json:"mustChangePassword"`
+	DocumentsCount     int    `json:"docs"`
+	LastLoginAt        string `json:"lastLogin,omitempty"`
+}
+
+type (
+	UpdateUserRequest struct {
+		Role *string `json:"role"`
+		// IsAdmin is deprecated in favor of Role. True assigns admin role,
+		// false replaces admin role with the default one.
+		IsAdmin    *bool `json:"admin"`
+		IsDisabled *bool `json:"disabled"`
+	}
+	UpdateUserResponse struct {
+		Login      string `json:"login"`
+		Role       string `json:"role"`
+		IsAdmin    bool   `json:"admin"`
+		IsDisabled bool   `json:"disabled"`
+	}
+)
+
+type GetRolesResponse struct {
+	Roles []Role `json:"roles"`
+}
+
+func NewGetRolesResponse() GetRolesResponse {
+	return GetRolesResponse{
+		Roles: make([]Role, 0),
+	}
+}
+
+func (r GetRolesResponse) FromDomain(roles []string) GetRolesResponse {
+	for _, v := range roles {
+		r.Roles = append(r.Roles, Role{
+			Name:        v,
+			Permissions: user.RolePermissions(v),
+		})
+	}
+
+	return r
+}
+
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type LogoutUserResponse struct {
+	RevokedSessions int `json:"revokedSessions"`
+}
+
+type GetLockoutsResponse struct {
+	Lockouts []Lockout `json:"lockouts"`
+}
+
+func NewGetLockoutsResponse() GetLockoutsResponse {
+	return GetLockoutsResponse{
+		Lockouts: make([]Lockout, 0),
+	}
+}
+
+func (r GetLockoutsResponse) FromDomain(lockouts []user.Lockout) GetLockoutsResponse {
+	for _, v := range lockouts {
+		lockout := Lockout{
+			Kind:          v.Kind,
+			Key:           v.Key,
+			Failures:      v.Failures,
+			LastFailureAt: v.LastFailureAt.Format(time.DateTime),
+		}
+		if v.LockedUntil != nil {
+			lockout.LockedUntil = v.LockedUntil.Format(time.DateTime)
+		}
+
+		r.Lockouts = append(r.Lockouts, lockout)
+	}
+
+	return r
+}
+
+type Lockout struct {
+	Kind          string `json:"kind"`
+	Key           string `json:"key"`
+	Failures      int    `json:"failures"`
+	LastFailureAt string `json:"lastFailure"`
+	LockedUntil   string `json:"lockedUntil,omitempty"`
+}
+
+type (
+	ClearLockoutRequest struct {
+		Kind string `query:"kind"`
+		Key  string `query:"key"`
+	}
+	ClearLockoutResponse map[string]bool
+)
@@ -1,10 +1,17 @@
 package dto
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/srgklmv/astral/internal/domain/user"
+)
+
 type (
 	RegisterRequest struct {
-		Token    string `json:"token"`
-		Login    string `json:"login"`
-		Password string `json:"pswd"`
+		InviteCode string `json:"invite"`
+		Login      string `json:"login"`
+		Password   string `json:"pswd"`
 	}
 	RegisterResponse struct {
 		Login string `json:"login"`
@@ -15,10 +22,75 @@ type (
 	AuthRequest struct {
 		Login    string `json:"login"`
 		Password string `json:"pswd"`
+		Device   string `json:"device"`
 	}
 	AuthResponse struct {
-		Token string `json:"token"`
+		Token              string `json:"token,omitempty"`
+		MustChangePassword bool   `json:"mustChangePassword,omitempty"`
+		// RefreshToken and ExpiresIn are returned along with access token in stateless mode.
+		RefreshToken string `json:"refreshToken,omitempty"`
+		ExpiresIn    int    `json:"expiresIn,omitempty"`
+		// Challenge is returned instead of token if second factor is required.
+		Challenge         string `json:"challenge,omitempty"`
+		TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+	}
+)
+
+type OIDCLoginResponse struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+}
+
+type (
+	BeginPasskeyLoginRequest struct {
+		Device string `json:"device"`
+	}
+	// BeginPasskeyResponse holds options for navigator.credentials.create or get
+	// and session to be sent back with authenticator response.
+	BeginPasskeyResponse struct {
+		Session string `json:"session"`
+		Options any    `json:"options"`
+	}
+	FinishPasskeyRegistrationRequest struct {
+		Session    string          `json:"session"`
+		Name       string          `json:"name"`
+		Credential json.RawMessage `json:"credential"`
+	}
+	FinishPasskeyLoginRequest struct {
+		Session    string          `json:"session"`
+		Credential json.RawMessage `json:"credential"`
+	}
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type VerifyTwoFactorRequest struct {
+	Challenge string `json:"challenge"`
+	// Code is either TOTP code or recovery code.
+	Code string `json:"code"`
+}
+
+type EnrollTOTPResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type (
+	ConfirmTOTPRequest struct {
+		Code string `json:"code"`
+	}
+	ConfirmTOTPResponse struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+)
+
+type (
+	DisableTOTPRequest struct {
+		Password string `json:"pswd"`
 	}
+	DisableTOTPResponse map[string]bool
 )
 
 type (
@@ -27,3 +99,191 @@ type (
 	}
 	LogoutResponse map[string]bool
 )
+
+type GetSessionsResponse struct {
+	Sessions []Session `json:"sessions"`
+}
+
+func NewGetSessionsResponse() GetSessionsResponse {
+	return GetSessionsResponse{
+		Sessions: make([]Session, 0),
+	}
+}
+
+func (r GetSessionsResponse) FromDomain(tokens []user.AuthToken, currentSessionID string) GetSessionsResponse {
+	for _, v := range tokens {
+		session := Session{
+			ID:        v.ID,
+			Device:    v.Device,
+			UserAgent: v.UserAgent,
+			IP:        v.IP,
+			CreatedAt: v.CreatedAt.Format(time.DateTime),
+			ExpiresAt: v.ExpiresAt.Format(time.DateTime),
+			Current:   v.ID == currentSessionID,
+		}
+		if v.LastUsedAt != nil {
+			session.LastUsedAt = v.LastUsedAt.Format(time.DateTime)
+		}
+
+		r.Sessions = append(r.Sessions, session)
+	}
+
+	return r
+}
+
+type Session struct {
+	ID         string `json:"id"`
+	Device     string `json:"device,omitempty"`
+	UserAgent  string `json:"userAgent,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	CreatedAt  string `json:"created"`
+	LastUsedAt string `json:"lastUsed,omitempty"`
+	ExpiresAt  string `json:"expires"`
+	Current    bool   `json:"current"`
+}
+
+type RevokeSessionResponse map[string]bool
+
+type RevokeOtherSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+type (
+	ChangePasswordRequest struct {
+		Password    string `json:"pswd"`
+		NewPassword string `json:"newPswd"`
+	}
+	ChangePasswordResponse struct {
+		RevokedSessions int `json:"revokedSessions"`
+	}
+)
+
+type (
+	DeleteUserRequest struct {
+		Mode       string `json:"mode" query:"mode"`
+		TransferTo string `json:"to" query:"to"`
+		DryRun     bool   `json:"dryRun" query:"dryRun"`
+	}
+	DeleteUserResponse struct {
+		Login      string `json:"login"`
+		Mode       string `json:"mode"`
+		TransferTo string `json:"to,omitempty"`
+		DryRun     bool   `json:"dryRun"`
+		// OwnedDocuments are transferred or purged.
+		OwnedDocuments int `json:"ownedDocuments"`
+		// SharedDocuments are owned documents other users have access to.
+		SharedDocuments int `json:"sharedDocuments"`
+		ReceivedGrants  int `json:"receivedGrants"`
+		Sessions        int `json:"sessions"`
+	}
+)
+
+type (
+	CreateAPIKeyRequest struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+		// Documents and Tags limit key to listed documents or documents with listed tags.
+		Documents []string `json:"docs"`
+		Tags      []string `json:"tags"`
+		// TTL is API key lifetime in seconds. Zero means key never expires.
+		TTL int `json:"ttl"`
+	}
+	CreateAPIKeyResponse struct {
+		APIKey
+		Key string `json:"key"`
+	}
+)
+
+type GetAPIKeysResponse struct {
+	APIKeys []APIKey `json:"keys"`
+}
+
+func NewGetAPIKeysResponse() GetAPIKeysResponse {
+	return GetAPIKeysResponse{
+		APIKeys: make([]APIKey, 0),
+	}
+}
+
+func (r GetAPIKeysResponse) FromDomain(apiKeys []user.APIKey) GetAPIKeysResponse {
+	for _, v := range apiKeys {
+		r.APIKeys = append(r.APIKeys, NewAPIKey(v))
+	}
+
+	return r
+}
+
+type APIKey struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	Documents  []string `json:"docs,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	CreatedAt  string   `json:"created"`
+	ExpiresAt  string   `json:"expires,omitempty"`
+	LastUsedAt string   `json:"lastUsed,omitempty"`
+}
+
+func NewAPIKey(apiKey user.APIKey) APIKey {
+	dto := APIKey{
+		ID:        apiKey.ID,
+		Name:      apiKey.Name,
+		Scopes:    apiKey.Scopes,
+		Documents: apiKey.DocumentIDs,
+		Tags:      apiKey.Tags,
+		CreatedAt: apiKey.CreatedAt.Format(time.DateTime),
+	}
+	if apiKey.ExpiresAt != nil {
+		dto.ExpiresAt = apiKey.ExpiresAt.Format(time.DateTime)
+	}
+	if apiKey.LastUsedAt != nil {
+		dto.LastUsedAt = apiKey.LastUsedAt.Format(time.DateTime)
+	}
+
+	return dto
+}
+
+type RevokeAPIKeyResponse map[string]bool
+
+type GetPasskeysResponse struct {
+	Passkeys []Passkey `json:"passkeys"`
+}
+
+func NewGetPasskeysResponse() GetPasskeysResponse {
+	return GetPasskeysResponse{
+		Passkeys: make([]Passkey, 0),
+	}
+}
+
+func (r GetPasskeysResponse) FromDomain(passkeys []user.Passkey) GetPasskeysResponse {
+	for _, v := range passkeys {
+		r.Passkeys = append(r.Passkeys, NewPasskey(v))
+	}
+
+	return r
+}
+
+type Passkey struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Transports []string `json:"transports,omitempty"`
+	Synced     bool     `json:"synced"`
+	CreatedAt  string   `json:"created"`
+	LastUsedAt string   `json:"lastUsed,omitempty"`
+}
+
+func NewPasskey(passkey user.Passkey) Passkey {
+	dto := Passkey{
+		ID:         passkey.ID,
+		Name:       passkey.Name,
+		Transports: passkey.Transports,
+		Synced:     passkey.BackupState,
+		CreatedAt:  passkey.CreatedAt.Format(time.DateTime),
+	}
+	if passkey.LastUsedAt != nil {
+		dto.LastUsedAt = passkey.LastUsedAt.Format(time.DateTime)
+	}
+
+	return dto
+}
+
+type RemovePasskeyResponse map[string]bool
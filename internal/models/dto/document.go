@@ -1,9 +1,12 @@
 package dto
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/srgklmv/astral/internal/domain/document"
+	"github.com/srgklmv/astral/internal/domain/group"
+	"github.com/srgklmv/astral/internal/models/apperrors"
 )
 
 type (
@@ -13,18 +16,24 @@ type (
 		File     UploadDocumentRequestFile `form:"file"`
 	}
 	UploadDocumentRequestMetadata struct {
-		Name     string `json:"name"`
-		IsFile   bool   `json:"file"`
-		IsPublic bool   `json:"public"`
-		// Token is for what?
-		Token     string   `json:"token"`
-		Mimetype  string   `json:"mime"`
-		GrantedTo []string `json:"grant"`
+		DocumentMetadata
+		// GrantedTo are grants given on upload. Bare target may be used instead of grant object.
+		GrantedTo []Grant  `json:"grant"`
+		Tags      []string `json:"tags"`
 	}
 	UploadDocumentRequestJSON map[string]any
 	UploadDocumentRequestFile []byte
 )
 
+// DocumentMetadata describes document content. It is sent in meta form field of upload and
+// update requests along with json or file field.
+type DocumentMetadata struct {
+	Name     string `json:"name"`
+	IsFile   bool   `json:"file"`
+	IsPublic bool   `json:"public"`
+	Mimetype string `json:"mime"`
+}
+
 type UploadFileResponse struct {
 	JSON     map[string]any `json:"json,omitempty"`
 	Filename string         `json:"file"`
@@ -32,11 +41,10 @@ type UploadFileResponse struct {
 
 type (
 	GetDocumentsRequest struct {
-		Token string `json:"token"`
-		Login string `json:"login"`
-		Key   string `json:"key"`
-		Value string `json:"value"`
-		Limit int    `json:"limit"`
+		Login string `json:"login" query:"login"`
+		Key   string `json:"key" query:"key"`
+		Value string `json:"value" query:"value"`
+		Limit int    `json:"limit" query:"limit"`
 	}
 	GetDocumentsResponse struct {
 		DocumentsData []DocumentData `json:"docs"`
@@ -52,13 +60,16 @@ func NewGetDocumentsResponse() GetDocumentsResponse {
 func (r GetDocumentsResponse) FromDomain(data document.DocumentsData) GetDocumentsResponse {
 	for _, v := range data {
 		r.DocumentsData = append(r.DocumentsData, DocumentData{
-			ID:        v.ID.String(),
-			Name:      v.Filename,
-			IsFile:    v.IsFile,
-			IsPublic:  v.IsPublic,
-			Mimetype:  v.Mimetype,
-			CreatedAt: v.CreatedAt.Format(time.DateTime),
-			GrantedTo: v.GrantedTo,
+			ID:           v.ID.String(),
+			Name:         v.Filename,
+			IsFile:       v.IsFile,
+			IsPublic:     v.IsPublic,
+			Mimetype:     v.Mimetype,
+			CreatedAt:    v.CreatedAt.Format(time.DateTime),
+			GrantedTo:    append(v.GrantedTo, group.GrantTargets(v.GrantedToGroups)...),
+			Tags:         v.Tags,
+			Access:       v.Access,
+			AccessGroups: v.AccessGroups,
 		})
 	}
 
@@ -73,18 +84,185 @@ type DocumentData struct {
 	Mimetype  string   `json:"mime,omitempty"`
 	CreatedAt string   `json:"created"`
 	GrantedTo []string `json:"grant,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	// Access is one of owner, direct, group, public; AccessGroups are groups granting it.
+	Access       string   `json:"access,omitempty"`
+	AccessGroups []string `json:"accessGroups,omitempty"`
+}
+
+type GetDocumentResponse any
+
+type DeleteDocumentResponse map[string]bool
+
+type GetDocumentVersionsResponse struct {
+	Versions []DocumentVersion `json:"versions"`
+}
+
+func NewGetDocumentVersionsResponse() GetDocumentVersionsResponse {
+	return GetDocumentVersionsResponse{
+		Versions: make([]DocumentVersion, 0),
+	}
+}
+
+func (r GetDocumentVersionsResponse) FromDomain(versions []document.Version, current int) GetDocumentVersionsResponse {
+	for _, v := range versions {
+		r.Versions = append(r.Versions, NewDocumentVersion(v, current))
+	}
+
+	return r
 }
 
+type DocumentVersion struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	IsFile    bool   `json:"file"`
+	Mimetype  string `json:"mime,omitempty"`
+	Size      int64  `json:"size"`
+	Checksum  string `json:"sha256"`
+	Author    string `json:"author"`
+	CreatedAt string `json:"created"`
+	IsCurrent bool   `json:"current"`
+}
+
+func NewDocumentVersion(v document.Version, current int) DocumentVersion {
+	return DocumentVersion{
+		Version:   v.Number,
+		Name:      v.Filename,
+		IsFile:    v.IsFile,
+		Mimetype:  v.Mimetype,
+		Size:      v.Size,
+		Checksum:  v.Checksum,
+		Author:    v.Author,
+		CreatedAt: v.CreatedAt.Format(time.DateTime),
+		IsCurrent: v.Number == current,
+	}
+}
+
+// GrantErrors are validation errors of grant targets.
+type GrantErrors map[string]apperrors.ErrorText
+
 type (
-	GetDocumentRequest struct {
-		Token string `json:"token"`
+	AddDocumentGrantsRequest struct {
+		Grants []Grant `json:"grants"`
+	}
+	RevokeDocumentGrantsRequest struct {
+		// Targets are user logins and group names prefixed with @.
+		Targets []string `json:"targets"`
+	}
+	GetDocumentGrantsResponse struct {
+		Grants []Grant `json:"grants"`
 	}
-	GetDocumentResponse any
 )
 
+func NewGetDocumentGrantsResponse() GetDocumentGrantsResponse {
+	return GetDocumentGrantsResponse{
+		Grants: make([]Grant, 0),
+	}
+}
+
+func (r GetDocumentGrantsResponse) FromDomain(doc document.Document) GetDocumentGrantsResponse {
+	for _, grant := range doc.Grants {
+		target := grant.Login
+		if grant.Group != "" {
+			target = group.GrantPrefix + grant.Group
+		}
+
+		r.Grants = append(r.Grants, Grant{Target: target, Level: grant.Level})
+	}
+
+	return r
+}
+
+type Grant struct {
+	// Target is user login or group name prefixed with @.
+	Target string `json:"target"`
+	// Level is one of read, comment, write, delete and share. Read is used if it is empty.
+	Level string `json:"level"`
+}
+
+// UnmarshalJSON accepts grant object or bare target, which is granted with read level.
+func (g *Grant) UnmarshalJSON(data []byte) error {
+	var target string
+	if json.Unmarshal(data, &target) == nil {
+		*g = Grant{Target: target}
+		return nil
+	}
+
+	type grant Grant
+	return json.Unmarshal(data, (*grant)(g))
+}
+
 type (
-	DeleteDocumentRequest struct {
+	CreateShareLinkRequest struct {
+		// TTL is link lifetime in seconds. Configured default is used if it is zero.
+		TTL int `json:"ttl"`
+		// MaxDownloads limits link uses. Zero means no limit.
+		MaxDownloads int    `json:"maxDownloads"`
+		Password     string `json:"password"`
+	}
+	CreateShareLinkResponse struct {
+		ShareLink
+		// Token is returned only once, link is opened with /api/links/:token.
 		Token string `json:"token"`
 	}
-	DeleteDocumentResponse map[string]bool
+	OpenShareLinkRequest struct {
+		Password string `json:"password" form:"password"`
+	}
 )
+
+type GetShareLinksResponse struct {
+	Links []ShareLink `json:"links"`
+}
+
+func NewGetShareLinksResponse() GetShareLinksResponse {
+	return GetShareLinksResponse{
+		Links: make([]ShareLink, 0),
+	}
+}
+
+func (r GetShareLinksResponse) FromDomain(links []document.Link) GetShareLinksResponse {
+	now := time.Now()
+	for _, v := range links {
+		r.Links = append(r.Links, NewShareLink(v, now))
+	}
+
+	return r
+}
+
+type ShareLink struct {
+	ID           string `json:"id"`
+	CreatedBy    string `json:"createdBy"`
+	HasPassword  bool   `json:"password"`
+	MaxDownloads int    `json:"maxDownloads,omitempty"`
+	Downloads    int    `json:"downloads"`
+	IsActive     bool   `json:"active"`
+	CreatedAt    string `json:"created"`
+	ExpiresAt    string `json:"expires"`
+	LastUsedAt   string `json:"lastUsed,omitempty"`
+	RevokedAt    string `json:"revoked,omitempty"`
+}
+
+func NewShareLink(link document.Link, now time.Time) ShareLink {
+	dto := ShareLink{
+		ID:          link.ID,
+		CreatedBy:   link.CreatedBy,
+		HasPassword: link.HasPassword(),
+		Downloads:   link.Downloads,
+		IsActive:    link.IsActive(now),
+		CreatedAt:   link.CreatedAt.Format(time.DateTime),
+		ExpiresAt:   link.ExpiresAt.Format(time.DateTime),
+	}
+	if link.MaxDownloads != nil {
+		dto.MaxDownloads = *link.MaxDownloads
+	}
+	if link.LastUsedAt != nil {
+		dto.LastUsedAt = link.LastUsedAt.Format(time.DateTime)
+	}
+	if link.RevokedAt != nil {
+		dto.RevokedAt = link.RevokedAt.Format(time.DateTime)
+	}
+
+	return dto
+}
+
+type RevokeShareLinkResponse map[string]bool
@@ -0,0 +1,69 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/srgklmv/astral/internal/domain/group"
+)
+
+type CreateGroupRequest struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+type GetGroupsResponse struct {
+	Groups []Group `json:"groups"`
+}
+
+func NewGetGroupsResponse() GetGroupsResponse {
+	return GetGroupsResponse{
+		Groups: make([]Group, 0),
+	}
+}
+
+func (r GetGroupsResponse) FromDomain(groups []group.Group) GetGroupsResponse {
+	for _, v := range groups {
+		r.Groups = append(r.Groups, NewGroup(v))
+	}
+
+	return r
+}
+
+type Group struct {
+	Name      string        `json:"name"`
+	CreatedBy string        `json:"createdBy,omitempty"`
+	CreatedAt string        `json:"created"`
+	Members   []GroupMember `json:"members"`
+}
+
+type GroupMember struct {
+	Login   string `json:"login"`
+	IsAdmin bool   `json:"admin"`
+}
+
+func NewGroup(g group.Group) Group {
+	dto := Group{
+		Name:      g.Name,
+		CreatedAt: g.CreatedAt.Format(time.DateTime),
+		Members:   make([]GroupMember, 0, len(g.Members)),
+	}
+	if g.CreatedBy != nil {
+		dto.CreatedBy = *g.CreatedBy
+	}
+	for _, member := range g.Members {
+		dto.Members = append(dto.Members, GroupMember{
+			Login:   member.Login,
+			IsAdmin: member.IsAdmin,
+		})
+	}
+
+	return dto
+}
+
+type DeleteGroupResponse map[string]bool
+
+type SetGroupMemberRequest struct {
+	IsAdmin bool `json:"admin"`
+}
+
+type RemoveGroupMemberResponse map[string]bool
@@ -3,18 +3,93 @@ package repository
 import (
 	"context"
 	"log/slog"
+	"time"
 
+	"github.com/srgklmv/astral/internal/domain/invite"
 	"github.com/srgklmv/astral/pkg/logger"
 )
 
-func (r repository) IsAdminTokenValid(ctx context.Context, token string) (bool, error) {
-	var t string
+func (r repository) CreateInviteCode(ctx context.Context, codeHash, role string, maxUses int, createdBy *string, expiresAt time.Time) (invite.Code, error) {
+	var code invite.Code
 
-	err := r.conn.QueryRowContext(ctx, `select value from secrets where name = 'admin_token';`).Scan(&t)
+	err := r.conn.QueryRowContext(
+		ctx,
+		`insert into invite_code(code_hash, role, max_uses, created_by, expires_at) values ($1, $2, $3, $4, $5)
+		returning id, role, max_uses, uses, created_by, created_at, expires_at, revoked_at;`,
+		codeHash,
+		role,
+		maxUses,
+		createdBy,
+		expiresAt,
+	).Scan(&code.ID, &code.Role, &code.MaxUses, &code.Uses, &code.CreatedBy, &code.CreatedAt, &code.ExpiresAt, &code.RevokedAt)
 	if err != nil {
-		logger.Error("QueryRowContext", slog.String("error", err.Error()))
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return code, err
+	}
+
+	return code, nil
+}
+
+func (r repository) GetInviteCodes(ctx context.Context) ([]invite.Code, error) {
+	var codes []invite.Code
+
+	rows, err := r.conn.QueryContext(
+		ctx,
+		`select id, role, max_uses, uses, created_by, created_at, expires_at, revoked_at
+		from invite_code
+		order by created_at desc;`,
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return codes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code invite.Code
+
+		err = rows.Scan(&code.ID, &code.Role, &code.MaxUses, &code.Uses, &code.CreatedBy, &code.CreatedAt, &code.ExpiresAt, &code.RevokedAt)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return codes, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+func (r repository) RevokeInviteCode(ctx context.Context, id string) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`update invite_code set revoked_at = now() where id = $1 and revoked_at is null;`,
+		id,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
 		return false, err
 	}
 
-	return t == token, nil
+	return n > 0, nil
+}
+
+// RevokeBootstrapInviteCodes revokes active invite codes not created by any user.
+func (r repository) RevokeBootstrapInviteCodes(ctx context.Context) error {
+	err := r.conn.QueryRowContext(
+		ctx,
+		`update invite_code set revoked_at = now() where created_by is null and revoked_at is null;`,
+	).Err()
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
 }
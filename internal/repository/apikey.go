@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/lib/pq"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+func (r repository) CreateAPIKey(ctx context.Context, login string, apiKey userDomain.APIKey) (userDomain.APIKey, error) {
+	err := r.conn.QueryRowContext(
+		ctx,
+		`insert into api_key(key_hash, user_login, name, scopes, document_ids, tags, expires_at) values ($1, $2, $3, $4, $5, $6, $7)
+		returning id, created_at;`,
+		apiKey.KeyHash,
+		login,
+		apiKey.Name,
+		pq.Array(apiKey.Scopes),
+		pq.Array(apiKey.DocumentIDs),
+		pq.Array(apiKey.Tags),
+		apiKey.ExpiresAt,
+	).Scan(&apiKey.ID, &apiKey.CreatedAt)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return apiKey, err
+	}
+
+	return apiKey, nil
+}
+
+func (r repository) GetUserAPIKeys(ctx context.Context, login string) ([]userDomain.APIKey, error) {
+	var apiKeys []userDomain.APIKey
+
+	rows, err := r.conn.QueryContext(
+		ctx,
+		`select id, name, scopes, document_ids, tags, created_at, expires_at, last_used_at
+		from api_key
+		where user_login = $1
+		order by created_at desc;`,
+		login,
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return apiKeys, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var k userDomain.APIKey
+
+		err = rows.Scan(
+			&k.ID,
+			&k.Name,
+			pq.Array(&k.Scopes),
+			pq.Array(&k.DocumentIDs),
+			pq.Array(&k.Tags),
+			&k.CreatedAt,
+			&k.ExpiresAt,
+			&k.LastUsedAt,
+		)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return apiKeys, err
+		}
+
+		apiKeys = append(apiKeys, k)
+	}
+
+	return apiKeys, rows.Err()
+}
+
+func (r repository) DeleteUserAPIKey(ctx context.Context, login, id string) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`delete from api_key where user_login = $1 and id = $2;`,
+		login,
+		id,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (r repository) GetUserByAPIKey(ctx context.Context, keyHash string) (userDomain.User, userDomain.APIKey, error) {
+	var user userDomain.User
+	apiKey := userDomain.APIKey{KeyHash: keyHash}
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select u.id, u.login, u.role, u.is_disabled, u.must_change_password,
+			k.id, k.name, k.scopes, k.document_ids, k.tags, k.created_at, k.expires_at, k.last_used_at
+		from api_key k
+		join "user" u on k.user_login = u.login
+		where k.key_hash = $1;`,
+		keyHash,
+	).Scan(
+		&user.ID,
+		&user.Login,
+		&user.Role,
+		&user.IsDisabled,
+		&user.MustChangePassword,
+		&apiKey.ID,
+		&apiKey.Name,
+		pq.Array(&apiKey.Scopes),
+		pq.Array(&apiKey.DocumentIDs),
+		pq.Array(&apiKey.Tags),
+		&apiKey.CreatedAt,
+		&apiKey.ExpiresAt,
+		&apiKey.LastUsedAt,
+	)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return user, apiKey, err
+	}
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return user, apiKey, err
+	}
+
+	return user, apiKey, nil
+}
+
+func (r repository) TouchAPIKey(ctx context.Context, keyHash string) error {
+	_, err := r.conn.ExecContext(
+		ctx,
+		`update api_key set last_used_at = now() where key_hash = $1;`,
+		keyHash,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
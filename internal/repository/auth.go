@@ -3,29 +3,37 @@ package repository
 import (
 	"context"
 	"log/slog"
+	"time"
 
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
 	"github.com/srgklmv/astral/pkg/logger"
 )
 
-func (r repository) DeleteAllUserTokens(ctx context.Context, login string) error {
-	err := r.conn.QueryRowContext(
+func (r repository) DeleteAllUserTokens(ctx context.Context, login string) (int, error) {
+	res, err := r.conn.ExecContext(
 		ctx,
 		`delete from auth_token where user_login = $1;`,
 		login,
-	).Err()
+	)
 	if err != nil {
-		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
-		return err
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return 0, err
 	}
 
-	return nil
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	return int(n), nil
 }
 
-func (r repository) DeleteToken(ctx context.Context, token string) error {
+func (r repository) DeleteToken(ctx context.Context, tokenHash string) error {
 	err := r.conn.QueryRowContext(
 		ctx,
-		`delete from auth_token where token = $1 returning true;`,
-		token,
+		`delete from auth_token where token_hash = $1 returning true;`,
+		tokenHash,
 	).Err()
 	if err != nil {
 		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
@@ -35,12 +43,58 @@ func (r repository) DeleteToken(ctx context.Context, token string) error {
 	return nil
 }
 
-func (r repository) SaveAuthToken(ctx context.Context, login, token string) error {
+// SaveAuthToken stores session and returns its ID.
+func (r repository) SaveAuthToken(ctx context.Context, login string, authToken userDomain.AuthToken) (string, error) {
+	var id string
+
 	err := r.conn.QueryRowContext(
 		ctx,
-		`insert into auth_token(token, user_login) values ($1, $2)`,
-		token,
+		`insert into auth_token(token_hash, user_login, expires_at, device, user_agent, ip) values ($1, $2, $3, $4, $5, $6) returning id;`,
+		authToken.TokenHash,
 		login,
+		authToken.ExpiresAt,
+		authToken.Device,
+		authToken.UserAgent,
+		authToken.IP,
+	).Scan(&id)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return "", err
+	}
+
+	return id, nil
+}
+
+// RotateAuthToken replaces session token keeping session ID. It returns false
+// if token was already rotated or deleted.
+func (r repository) RotateAuthToken(ctx context.Context, tokenHash, newTokenHash string, expiresAt time.Time) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`update auth_token set token_hash = $2, expires_at = $3, last_used_at = now() where token_hash = $1;`,
+		tokenHash,
+		newTokenHash,
+		expiresAt,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (r repository) TouchAuthToken(ctx context.Context, tokenHash string, expiresAt time.Time) error {
+	err := r.conn.QueryRowContext(
+		ctx,
+		`update auth_token set last_used_at = now(), expires_at = $2 where token_hash = $1;`,
+		tokenHash,
+		expiresAt,
 	).Err()
 	if err != nil {
 		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
@@ -49,3 +103,77 @@ func (r repository) SaveAuthToken(ctx context.Context, login, token string) erro
 
 	return nil
 }
+
+func (r repository) GetUserAuthTokens(ctx context.Context, login string) ([]userDomain.AuthToken, error) {
+	var tokens []userDomain.AuthToken
+
+	rows, err := r.conn.QueryContext(
+		ctx,
+		`select id, token_hash, device, user_agent, ip, created_at, expires_at, last_used_at
+		from auth_token
+		where user_login = $1 and expires_at > now()
+		order by created_at desc;`,
+		login,
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return tokens, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t userDomain.AuthToken
+
+		err = rows.Scan(&t.ID, &t.TokenHash, &t.Device, &t.UserAgent, &t.IP, &t.CreatedAt, &t.ExpiresAt, &t.LastUsedAt)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return tokens, err
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r repository) DeleteUserAuthToken(ctx context.Context, login, id string) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`delete from auth_token where user_login = $1 and id = $2;`,
+		login,
+		id,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (r repository) DeleteUserTokensExcept(ctx context.Context, login, id string) (int, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`delete from auth_token where user_login = $1 and id <> $2;`,
+		login,
+		id,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	return int(n), nil
+}
@@ -11,22 +11,30 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/srgklmv/astral/internal/domain/document"
 	"github.com/srgklmv/astral/pkg/cache"
 	"github.com/srgklmv/astral/pkg/logger"
 )
 
+// grantJSON is a document grant aggregated by json_agg.
+type grantJSON struct {
+	Login string `json:"login"`
+	Group string `json:"group"`
+	Level string `json:"level"`
+}
+
 func (r repository) UploadDocument(
 	ctx context.Context,
 	login, filename string,
 	isFile bool,
 	mimetype string,
 	isPublic bool,
-	grantedTo []string,
+	grants []document.Grant,
+	tags []string,
 	jsonM map[string]any,
 	file *bytes.Buffer,
-) (document.Document, error) {
-	var doc document.Document
+) (doc document.Document, err error) {
 	var id uuid.UUID
 
 	jsonb, err := json.Marshal(jsonM)
@@ -46,19 +54,23 @@ func (r repository) UploadDocument(
 			err = tx.Commit()
 			if err != nil {
 				logger.Error("commit error", slog.String("error", err.Error()))
+				return
 			}
+
+			// New document is shown in listings of owner, grantees and readers of any document.
+			cache.Cache.Invalidate("GetDocumentsData")
 			return
 		}
 
-		err = tx.Rollback()
-		if err != nil {
-			logger.Error("rollback error", slog.String("error", err.Error()))
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
 		}
 	}()
 
 	err = tx.QueryRowContext(
 		ctx,
-		`insert into document (name, is_file, is_public, mimetype, json, file, owner_login) values ($1, $2, $3, $4, $5, $6, $7) returning id, json, name;`,
+		`insert into document (name, is_file, is_public, mimetype, json, file, owner_login, tags) values ($1, $2, $3, $4, $5, $6, $7, $8) returning id, json, name;`,
 		filename,
 		isFile,
 		isPublic,
@@ -66,24 +78,21 @@ func (r repository) UploadDocument(
 		jsonb,
 		file.Bytes(),
 		login,
+		pq.Array(tags),
 	).Scan(&id, &jsonb, &doc.Filename)
 	if err != nil {
 		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
 		return doc, err
 	}
 
-	for _, grantedToLogin := range grantedTo {
-		err = tx.QueryRowContext(
-			ctx,
-			`insert into user_document_access (user_login, document_id) values ($1, $2);`,
-			grantedToLogin,
-			id,
-		).Err()
-		if err != nil {
-			// TODO: Add validation for granted to logins.
-			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
-			return doc, err
-		}
+	err = upsertDocumentGrants(ctx, tx, id, grants)
+	if err != nil {
+		return doc, err
+	}
+
+	err = insertDocumentVersion(ctx, tx, id, login, 0)
+	if err != nil {
+		return doc, err
 	}
 
 	err = json.Unmarshal(jsonb, &jsonM)
@@ -94,10 +103,155 @@ func (r repository) UploadDocument(
 
 	doc.JSON = jsonM
 
-	cache.Cache.Invalidate(login)
 	return doc, nil
 }
 
+// UpdateDocument replaces document content keeping its ID, grants and tags.
+// Replaced content is kept as a new version.
+func (r repository) UpdateDocument(
+	ctx context.Context,
+	id uuid.UUID,
+	author, filename string,
+	isFile bool,
+	mimetype string,
+	isPublic bool,
+	jsonM map[string]any,
+	file *bytes.Buffer,
+	keepVersions int,
+) (doc document.Document, err error) {
+	jsonb, err := json.Marshal(jsonM)
+	if err != nil {
+		logger.Error("json.Marshal error", slog.String("error", err.Error()))
+		return doc, err
+	}
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return doc, err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+				return
+			}
+
+			// Name and public flag are shown in listings of every user document is visible to,
+			// so all listings are dropped along with cached document.
+			cache.Cache.Invalidate(id.String())
+			cache.Cache.Invalidate("GetDocumentsData")
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	err = tx.QueryRowContext(
+		ctx,
+		`update document set name = $2, is_file = $3, is_public = $4, mimetype = $5, json = $6, file = $7, version = version + 1
+		where id = $1
+		returning json, name, version;`,
+		id.String(),
+		filename,
+		isFile,
+		isPublic,
+		mimetype,
+		jsonb,
+		file.Bytes(),
+	).Scan(&jsonb, &doc.Filename, &doc.Version)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		}
+		return doc, err
+	}
+
+	err = insertDocumentVersion(ctx, tx, id, author, keepVersions)
+	if err != nil {
+		return doc, err
+	}
+
+	err = json.Unmarshal(jsonb, &doc.JSON)
+	if err != nil {
+		logger.Error("Unmarshal error", slog.String("error", err.Error()))
+		return doc, err
+	}
+
+	return doc, nil
+}
+
+// PatchDocumentJSON applies patch to JSON document content holding row lock,
+// so concurrent patches are not lost. Patch errors are returned as is.
+// Patched content is kept as a new version.
+func (r repository) PatchDocumentJSON(ctx context.Context, id uuid.UUID, author string, keepVersions int, patch func(doc []byte) ([]byte, error)) (jsonM map[string]any, err error) {
+	var jsonb []byte
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return jsonM, err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+				return
+			}
+
+			cache.Cache.Invalidate(id.String())
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	err = tx.QueryRowContext(
+		ctx,
+		`select json from document where id = $1 and not is_file for update;`,
+		id.String(),
+	).Scan(&jsonb)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		}
+		return jsonM, err
+	}
+
+	jsonb, err = patch(jsonb)
+	if err != nil {
+		return jsonM, err
+	}
+
+	err = json.Unmarshal(jsonb, &jsonM)
+	if err != nil {
+		return jsonM, err
+	}
+
+	_, err = tx.ExecContext(ctx, `update document set json = $2, version = version + 1 where id = $1;`, id.String(), jsonb)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return jsonM, err
+	}
+
+	err = insertDocumentVersion(ctx, tx, id, author, keepVersions)
+	if err != nil {
+		return jsonM, err
+	}
+
+	return jsonM, nil
+}
+
 func (r repository) DeleteDocument(ctx context.Context, id uuid.UUID) error {
 	var login string
 
@@ -126,17 +280,20 @@ func (r repository) GetDocument(ctx context.Context, id uuid.UUID) (document.Doc
 
 	var doc document.Document
 	var uid string
-	var jsonb, owners []byte
+	var jsonb, grants []byte
 
 	err := r.conn.QueryRowContext(
 		ctx,
-		`select d.id, d.name, d.is_file, d.is_public, d.mimetype, d.json, d.file, d.created_at, d.owner_login, to_json(array_agg(uda.user_login)) as owners
+		`select d.id, d.name, d.is_file, d.is_public, d.mimetype, d.json, d.file, d.created_at, d.owner_login, d.tags, d.version,
+		coalesce((select json_agg(g) from (
+			select uda.user_login as login, '' as group, uda.level from user_document_access uda where uda.document_id = d.id
+			union all
+			select '', gda.group_name, gda.level from group_document_access gda where gda.document_id = d.id
+		) g), '[]') as grants
 		from document d
-		left join public.user_document_access uda on d.id = uda.document_id
-		where d.id = $1
-		group by d.id;`,
+		where d.id = $1;`,
 		id.String(),
-	).Scan(&uid, &doc.Filename, &doc.IsFile, &doc.IsPublic, &doc.Mimetype, &jsonb, &doc.File, &doc.CreatedAt, &doc.Owner, &owners)
+	).Scan(&uid, &doc.Filename, &doc.IsFile, &doc.IsPublic, &doc.Mimetype, &jsonb, &doc.File, &doc.CreatedAt, &doc.Owner, pq.Array(&doc.Tags), &doc.Version, &grants)
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
 		return doc, err
 	}
@@ -151,12 +308,22 @@ func (r repository) GetDocument(ctx context.Context, id uuid.UUID) (document.Doc
 		return doc, err
 	}
 
-	err = json.Unmarshal(owners, &doc.GrantedTo)
+	var grantsJSON []grantJSON
+	err = json.Unmarshal(grants, &grantsJSON)
 	if err != nil {
 		logger.Error("Unmarshal error", slog.String("error", err.Error()))
 		return doc, err
 	}
 
+	for _, grant := range grantsJSON {
+		doc.Grants = append(doc.Grants, document.Grant{Login: grant.Login, Group: grant.Group, Level: grant.Level})
+		if grant.Group != "" {
+			doc.GrantedToGroups = append(doc.GrantedToGroups, grant.Group)
+			continue
+		}
+		doc.GrantedTo = append(doc.GrantedTo, grant.Login)
+	}
+
 	doc.ID, err = uuid.Parse(uid)
 	if err != nil {
 		logger.Error("uuid.FromBytes error", slog.String("error", err.Error()))
@@ -167,32 +334,36 @@ func (r repository) GetDocument(ctx context.Context, id uuid.UUID) (document.Doc
 	return doc, nil
 }
 
-func (r repository) GetDocumentsData(ctx context.Context, userLogin string, isAdmin bool, login, key, value string, limit int) (document.DocumentsData, error) {
-	cacheKey := fmt.Sprintf("%s%s%t%s%s%s%d", "GetDocumentsData", userLogin, isAdmin, login, key, value, limit)
+func (r repository) GetDocumentsData(ctx context.Context, userLogin string, readAny bool, login, key, value string, limit int, filter document.Filter) (document.DocumentsData, error) {
+	cacheKey := fmt.Sprintf("%s%s%t%s%s%s%d%v", "GetDocumentsData", userLogin, readAny, login, key, value, limit, filter)
 	if k, ok := cache.Cache.Get(cacheKey); ok {
 		value := k.(document.DocumentsData)
 		return value, nil
 	}
 
+	// User login is always the first argument, access source is resolved for it.
 	query := []string{
-		`select d.id, d.name, d.is_file, d.is_public, d.mimetype, d.created_at, to_json(array_agg(uda.user_login)) as owners
-		from document d
-		left join user_document_access uda on d.id = uda.document_id`,
+		`select d.id, d.name, d.is_file, d.is_public, d.mimetype, d.created_at, d.tags, d.owner_login,
+		coalesce((select json_agg(uda.user_login) from user_document_access uda where uda.document_id = d.id), '[]') as owners,
+		coalesce((select json_agg(gda.group_name) from group_document_access gda where gda.document_id = d.id), '[]') as groups,
+		exists(select 1 from user_document_access uda where uda.document_id = d.id and uda.user_login = $1) as is_direct,
+		coalesce((select json_agg(gda.group_name) from group_document_access gda
+			join user_group_member ugm on ugm.group_name = gda.group_name
+			where gda.document_id = d.id and ugm.user_login = $1), '[]') as access_groups
+		from document d`,
 		`where`,
 	}
-	var args []any
+	args := []any{userLogin}
 
 	switch {
 	case login == "":
-		query = append(query, `(d.owner_login = $1 or uda.user_login = $1)`)
-		args = append(args, userLogin)
-	case isAdmin:
-		query = append(query, `(d.owner_login = $1 or uda.user_login = $1)`)
+		query = append(query, accessibleDocumentCondition(1))
+	case readAny:
+		query = append(query, accessibleDocumentCondition(2))
 		args = append(args, login)
 	default:
-		query = append(query, `(d.owner_login = $1 or uda.user_login = $1) and 
-			(d.is_public or d.owner_login = $2 or uda.user_login = $2)`)
-		args = append(args, login, userLogin)
+		query = append(query, accessibleDocumentCondition(2), `and (d.is_public or`, accessibleDocumentCondition(1), `)`)
+		args = append(args, login)
 	}
 
 	if key != "" {
@@ -201,11 +372,12 @@ func (r repository) GetDocumentsData(ctx context.Context, userLogin string, isAd
 		args = append(args, value)
 	}
 
-	query = append(
-		query,
-		`group by d.id, d.name, d.created_at
-		order by d.name ASC, d.created_at ASC`,
-	)
+	if !filter.IsEmpty() {
+		query = append(query, fmt.Sprintf(`and (d.id = any($%d) or d.tags && $%d)`, len(args)+1, len(args)+2))
+		args = append(args, pq.Array(filter.IDs), pq.Array(filter.Tags))
+	}
+
+	query = append(query, `order by d.name ASC, d.created_at ASC`)
 	query = append(query, fmt.Sprintf("limit $%d", len(args)+1))
 	args = append(args, limit)
 
@@ -221,7 +393,8 @@ func (r repository) GetDocumentsData(ctx context.Context, userLogin string, isAd
 
 	for rows.Next() {
 		var doc document.Data
-		var buf []byte
+		var owners, groups, accessGroups []byte
+		var isDirect bool
 
 		err = rows.Scan(
 			&doc.ID,
@@ -230,22 +403,63 @@ func (r repository) GetDocumentsData(ctx context.Context, userLogin string, isAd
 			&doc.IsPublic,
 			&doc.Mimetype,
 			&doc.CreatedAt,
-			&buf,
+			pq.Array(&doc.Tags),
+			&doc.Owner,
+			&owners,
+			&groups,
+			&isDirect,
+			&accessGroups,
 		)
 		if err != nil {
 			logger.Error("QueryContext error", slog.String("error", err.Error()))
 			return docs, err
 		}
 
-		err = json.Unmarshal(buf, &doc.GrantedTo)
+		err = json.Unmarshal(accessGroups, &doc.AccessGroups)
 		if err != nil {
 			logger.Error("Unmarshal error", slog.String("error", err.Error()))
 			return docs, err
 		}
 
+		switch {
+		case doc.Owner == userLogin:
+			doc.Access = document.AccessOwner
+		case isDirect:
+			doc.Access = document.AccessDirect
+		case len(doc.AccessGroups) != 0:
+			doc.Access = document.AccessGroup
+		case doc.IsPublic:
+			doc.Access = document.AccessPublic
+		}
+
+		// Grantees are shown to document owner and users reading any document only.
+		if doc.Owner == userLogin || readAny {
+			err = json.Unmarshal(owners, &doc.GrantedTo)
+			if err != nil {
+				logger.Error("Unmarshal error", slog.String("error", err.Error()))
+				return docs, err
+			}
+
+			err = json.Unmarshal(groups, &doc.GrantedToGroups)
+			if err != nil {
+				logger.Error("Unmarshal error", slog.String("error", err.Error()))
+				return docs, err
+			}
+		}
+
 		docs = append(docs, doc)
 	}
 
 	cache.Cache.Set(cacheKey, docs)
 	return docs, nil
 }
+
+// accessibleDocumentCondition matches documents owned by login in argument with given number
+// or granted to it directly or via group.
+func accessibleDocumentCondition(arg int) string {
+	return fmt.Sprintf(`(d.owner_login = $%[1]d
+		or exists(select 1 from user_document_access uda where uda.document_id = d.id and uda.user_login = $%[1]d)
+		or exists(select 1 from group_document_access gda
+			join user_group_member ugm on ugm.group_name = gda.group_name
+			where gda.document_id = d.id and ugm.user_login = $%[1]d))`, arg)
+}
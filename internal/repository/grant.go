@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/srgklmv/astral/internal/domain/document"
+	"github.com/srgklmv/astral/pkg/cache"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// AddDocumentGrants grants document to users and groups. Levels of existing grants are replaced.
+func (r repository) AddDocumentGrants(ctx context.Context, id uuid.UUID, grants []document.Grant) (err error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+				return
+			}
+
+			invalidateGrants(id)
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	err = upsertDocumentGrants(ctx, tx, id, grants)
+	return err
+}
+
+// RevokeDocumentGrants revokes document grants of users and groups. Missing grants are ignored.
+func (r repository) RevokeDocumentGrants(ctx context.Context, id uuid.UUID, logins, groups []string) (err error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+				return
+			}
+
+			invalidateGrants(id)
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`delete from user_document_access where document_id = $1 and user_login = any($2);`,
+		id.String(),
+		pq.Array(logins),
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`delete from group_document_access where document_id = $1 and group_name = any($2);`,
+		id.String(),
+		pq.Array(groups),
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// invalidateGrants drops cached document and all listings, as grants are shown to owner and
+// readers of any document along with grantees and members of granted groups.
+func invalidateGrants(id uuid.UUID) {
+	cache.Cache.Invalidate(id.String())
+	cache.Cache.Invalidate("GetDocumentsData")
+}
+
+// upsertDocumentGrants saves grants of document replacing levels of existing ones.
+func upsertDocumentGrants(ctx context.Context, tx *sql.Tx, id uuid.UUID, grants []document.Grant) error {
+	var logins, loginLevels, groups, groupLevels []string
+	for _, grant := range grants {
+		if grant.Group != "" {
+			groups = append(groups, grant.Group)
+			groupLevels = append(groupLevels, grant.Level)
+			continue
+		}
+		logins = append(logins, grant.Login)
+		loginLevels = append(loginLevels, grant.Level)
+	}
+
+	_, err := tx.ExecContext(
+		ctx,
+		`insert into user_document_access (user_login, document_id, level)
+		select g.login, $1, g.level from unnest($2::varchar[], $3::varchar[]) as g(login, level)
+		on conflict (user_login, document_id) do update set level = excluded.level;`,
+		id.String(),
+		pq.Array(logins),
+		pq.Array(loginLevels),
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`insert into group_document_access (group_name, document_id, level)
+		select g.name, $1, g.level from unnest($2::varchar[], $3::varchar[]) as g(name, level)
+		on conflict (group_name, document_id) do update set level = excluded.level;`,
+		id.String(),
+		pq.Array(groups),
+		pq.Array(groupLevels),
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
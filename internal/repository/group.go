@@ -0,0 +1,392 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/lib/pq"
+	"github.com/srgklmv/astral/internal/domain/group"
+	"github.com/srgklmv/astral/pkg/cache"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// groupMemberJSON is a group member aggregated by json_agg.
+type groupMemberJSON struct {
+	Login   string `json:"login"`
+	IsAdmin bool   `json:"is_admin"`
+}
+
+const groupSelect = `select g.name, g.created_by, g.created_at,
+	coalesce(json_agg(json_build_object('login', m.user_login, 'is_admin', m.is_admin) order by m.user_login)
+		filter (where m.user_login is not null), '[]')
+	from user_group g
+	left join user_group_member m on m.group_name = g.name`
+
+func (r repository) IsGroupExists(ctx context.Context, name string) (bool, error) {
+	var exists bool
+
+	err := r.conn.QueryRowContext(ctx, `select exists(select 1 from user_group where name = $1);`, name).Scan(&exists)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// CreateGroup creates group with creator as its admin and other members in one transaction.
+func (r repository) CreateGroup(ctx context.Context, name, creator string, members []string) (g group.Group, err error) {
+	g = group.Group{Name: name, CreatedBy: &creator}
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return g, err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+				return
+			}
+
+			for _, member := range g.Members {
+				cache.Cache.Invalidate(member.Login)
+			}
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	err = tx.QueryRowContext(
+		ctx,
+		`insert into user_group (name, created_by) values ($1, $2) returning created_at;`,
+		name,
+		creator,
+	).Scan(&g.CreatedAt)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return g, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`insert into user_group_member (group_name, user_login, is_admin)
+		select $1, login, login = $2 from unnest($3::varchar[]) as login
+		on conflict do nothing;`,
+		name,
+		creator,
+		pq.Array(append([]string{creator}, members...)),
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return g, err
+	}
+
+	g.Members = append(g.Members, group.Member{Login: creator, IsAdmin: true})
+	for _, login := range members {
+		if _, ok := g.Member(login); !ok {
+			g.Members = append(g.Members, group.Member{Login: login})
+		}
+	}
+
+	return g, nil
+}
+
+func (r repository) GetGroup(ctx context.Context, name string) (group.Group, error) {
+	var g group.Group
+	var members []byte
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		groupSelect+` where g.name = $1 group by g.name;`,
+		name,
+	).Scan(&g.Name, &g.CreatedBy, &g.CreatedAt, &members)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		}
+		return g, err
+	}
+
+	g.Members, err = unmarshalGroupMembers(members)
+	if err != nil {
+		logger.Error("Unmarshal error", slog.String("error", err.Error()))
+		return g, err
+	}
+
+	return g, nil
+}
+
+// GetGroups returns groups login is member of, or all groups if login is empty.
+func (r repository) GetGroups(ctx context.Context, login string) ([]group.Group, error) {
+	var groups []group.Group
+
+	rows, err := r.conn.QueryContext(
+		ctx,
+		groupSelect+` where $1 = '' or g.name in (select group_name from user_group_member where user_login = $1)
+		group by g.name
+		order by g.name asc;`,
+		login,
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return groups, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var g group.Group
+		var members []byte
+
+		err = rows.Scan(&g.Name, &g.CreatedBy, &g.CreatedAt, &members)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return groups, err
+		}
+
+		g.Members, err = unmarshalGroupMembers(members)
+		if err != nil {
+			logger.Error("Unmarshal error", slog.String("error", err.Error()))
+			return groups, err
+		}
+
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+// DeleteGroup deletes group along with its document grants.
+func (r repository) DeleteGroup(ctx context.Context, name string) error {
+	rows, err := r.conn.QueryContext(
+		ctx,
+		`with members as (select user_login from user_group_member where group_name = $1),
+		deleted as (delete from user_group where name = $1)
+		select user_login from members;`,
+		name,
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var login string
+
+		err = rows.Scan(&login)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return err
+		}
+
+		cache.Cache.Invalidate(login)
+	}
+
+	return rows.Err()
+}
+
+// SetGroupMember adds user to group or updates its admin flag.
+// ErrLastAdmin is returned if group would be left without admin.
+func (r repository) SetGroupMember(ctx context.Context, name, login string, isAdmin bool) (err error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+				return
+			}
+
+			cache.Cache.Invalidate(login)
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	admins, err := lockGroupAdmins(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`insert into user_group_member (group_name, user_login, is_admin) values ($1, $2, $3)
+		on conflict (group_name, user_login) do update set is_admin = excluded.is_admin;`,
+		name,
+		login,
+		isAdmin,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = checkGroupAdminsLeft(ctx, tx, name, admins)
+	return err
+}
+
+// RemoveGroupMember removes user from group.
+// ErrLastAdmin is returned if group would be left without admin.
+func (r repository) RemoveGroupMember(ctx context.Context, name, login string) (removed bool, err error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+				return
+			}
+
+			cache.Cache.Invalidate(login)
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	admins, err := lockGroupAdmins(ctx, tx, name)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		`delete from user_group_member where group_name = $1 and user_login = $2;`,
+		name,
+		login,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	err = checkGroupAdminsLeft(ctx, tx, name, admins)
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// lockGroupAdmins locks group admins till the end of transaction and returns their count,
+// so concurrent demotions or removals can't leave group without admin.
+func lockGroupAdmins(ctx context.Context, tx *sql.Tx, name string) (int, error) {
+	var count int
+
+	err := tx.QueryRowContext(
+		ctx,
+		`select count(*) from (
+			select 1 from user_group_member where group_name = $1 and is_admin for update
+		) admins;`,
+		name,
+	).Scan(&count)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// checkGroupAdminsLeft returns ErrLastAdmin if transaction took away the last group admin
+// out of locked ones.
+func checkGroupAdminsLeft(ctx context.Context, tx *sql.Tx, name string, locked int) error {
+	var count int
+
+	err := tx.QueryRowContext(
+		ctx,
+		`select count(*) from user_group_member where group_name = $1 and is_admin;`,
+		name,
+	).Scan(&count)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	if locked > 0 && count == 0 {
+		return group.ErrLastAdmin
+	}
+
+	return nil
+}
+
+// GetMemberGroups returns those of given groups user is member of.
+func (r repository) GetMemberGroups(ctx context.Context, login string, groups []string) ([]string, error) {
+	var memberGroups []string
+
+	rows, err := r.conn.QueryContext(
+		ctx,
+		`select group_name from user_group_member where user_login = $1 and group_name = any($2);`,
+		login,
+		pq.Array(groups),
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return memberGroups, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+
+		err = rows.Scan(&name)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return memberGroups, err
+		}
+
+		memberGroups = append(memberGroups, name)
+	}
+
+	return memberGroups, rows.Err()
+}
+
+func unmarshalGroupMembers(data []byte) ([]group.Member, error) {
+	var membersJSON []groupMemberJSON
+
+	err := json.Unmarshal(data, &membersJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]group.Member, 0, len(membersJSON))
+	for _, member := range membersJSON {
+		members = append(members, group.Member{Login: member.Login, IsAdmin: member.IsAdmin})
+	}
+
+	return members, nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// SaveOIDCState stores pending OIDC login and drops expired ones.
+func (r repository) SaveOIDCState(ctx context.Context, state userDomain.OIDCState) error {
+	_, err := r.conn.ExecContext(ctx, `delete from oidc_state where expires_at < now();`)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	_, err = r.conn.ExecContext(
+		ctx,
+		`insert into oidc_state(state_hash, code_verifier, nonce, device, expires_at) values ($1, $2, $3, $4, $5);`,
+		state.StateHash,
+		state.CodeVerifier,
+		state.Nonce,
+		state.Device,
+		state.ExpiresAt,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// ConsumeOIDCState deletes pending OIDC login and returns it, so state can be used once.
+func (r repository) ConsumeOIDCState(ctx context.Context, stateHash string) (userDomain.OIDCState, error) {
+	state := userDomain.OIDCState{StateHash: stateHash}
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`delete from oidc_state where state_hash = $1 returning code_verifier, nonce, device, expires_at;`,
+		stateHash,
+	).Scan(&state.CodeVerifier, &state.Nonce, &state.Device, &state.ExpiresAt)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		}
+		return state, err
+	}
+
+	return state, nil
+}
+
+// GetUserByIdentity returns user linked to external identity.
+func (r repository) GetUserByIdentity(ctx context.Context, provider, subject string) (userDomain.User, error) {
+	var user userDomain.User
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select u.id, u.login, u.role, u.is_disabled, u.must_change_password
+		from user_identity i
+		join "user" u on u.login = i.user_login
+		where i.provider = $1 and i.subject = $2;`,
+		provider,
+		subject,
+	).Scan(&user.ID, &user.Login, &user.Role, &user.IsDisabled, &user.MustChangePassword)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		}
+		return user, err
+	}
+
+	return user, nil
+}
+
+// CreateUserWithIdentity creates user without local password and links external identity to it.
+func (r repository) CreateUserWithIdentity(ctx context.Context, identity userDomain.Identity, role string) (user userDomain.User, err error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return user, err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	err = tx.QueryRowContext(
+		ctx,
+		`insert into "user"(login, password, role) values ($1, '', $2) returning id, login, role;`,
+		identity.Login,
+		role,
+	).Scan(&user.ID, &user.Login, &user.Role)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return user, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`insert into user_identity(provider, subject, user_login) values ($1, $2, $3);`,
+		identity.Provider,
+		identity.Subject,
+		identity.Login,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return user, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/srgklmv/astral/internal/domain/document"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+func (r repository) CreateShareLink(ctx context.Context, link document.Link) (document.Link, error) {
+	err := r.conn.QueryRowContext(
+		ctx,
+		`insert into share_link(token_hash, document_id, created_by, password, max_downloads, expires_at) values ($1, $2, $3, $4, $5, $6)
+		returning id, created_at;`,
+		link.TokenHash,
+		link.DocumentID.String(),
+		link.CreatedBy,
+		link.PasswordHash,
+		link.MaxDownloads,
+		link.ExpiresAt,
+	).Scan(&link.ID, &link.CreatedAt)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return link, err
+	}
+
+	return link, nil
+}
+
+// GetDocumentShareLinks returns document share links from the newest one, revoked included.
+func (r repository) GetDocumentShareLinks(ctx context.Context, id uuid.UUID) ([]document.Link, error) {
+	var links []document.Link
+
+	rows, err := r.conn.QueryContext(
+		ctx,
+		`select id, created_by, password, max_downloads, downloads, password_failures, created_at, expires_at, last_used_at, revoked_at
+		from share_link
+		where document_id = $1
+		order by created_at desc;`,
+		id.String(),
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return links, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		link := document.Link{DocumentID: id}
+
+		err = rows.Scan(
+			&link.ID,
+			&link.CreatedBy,
+			&link.PasswordHash,
+			&link.MaxDownloads,
+			&link.Downloads,
+			&link.PasswordFailures,
+			&link.CreatedAt,
+			&link.ExpiresAt,
+			&link.LastUsedAt,
+			&link.RevokedAt,
+		)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return links, err
+		}
+
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+func (r repository) GetShareLink(ctx context.Context, tokenHash string) (document.Link, error) {
+	link := document.Link{TokenHash: tokenHash}
+	var documentID string
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select id, document_id, created_by, password, max_downloads, downloads, password_failures, created_at, expires_at, last_used_at, revoked_at
+		from share_link
+		where token_hash = $1;`,
+		tokenHash,
+	).Scan(
+		&link.ID,
+		&documentID,
+		&link.CreatedBy,
+		&link.PasswordHash,
+		&link.MaxDownloads,
+		&link.Downloads,
+		&link.PasswordFailures,
+		&link.CreatedAt,
+		&link.ExpiresAt,
+		&link.LastUsedAt,
+		&link.RevokedAt,
+	)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return link, err
+	}
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return link, err
+	}
+
+	link.DocumentID, err = uuid.Parse(documentID)
+	if err != nil {
+		logger.Error("uuid parse error", slog.String("error", err.Error()))
+		return link, err
+	}
+
+	return link, nil
+}
+
+// UseShareLink counts link use. It reports false if link became inactive meanwhile,
+// so download limit holds under concurrent requests.
+func (r repository) UseShareLink(ctx context.Context, id string, maxPasswordFailures int) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`update share_link set downloads = downloads + 1, last_used_at = now()
+		where id = $1 and revoked_at is null and expires_at > now()
+			and (max_downloads is null or downloads < max_downloads) and password_failures < $2;`,
+		id,
+		maxPasswordFailures,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// RegisterShareLinkPasswordFailure counts wrong password. Link already locked by maxFailures
+// wrong passwords is left as is and false is returned.
+func (r repository) RegisterShareLinkPasswordFailure(ctx context.Context, id string, maxFailures int) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`update share_link set password_failures = password_failures + 1 where id = $1 and password_failures < $2;`,
+		id,
+		maxFailures,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (r repository) RevokeShareLink(ctx context.Context, documentID uuid.UUID, id string) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`update share_link set revoked_at = now() where document_id = $1 and id = $2 and revoked_at is null;`,
+		documentID.String(),
+		id,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
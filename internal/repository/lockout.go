@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// ReserveAuthAttempt counts auth attempt as failed before credentials are checked, so
+// concurrent requests can't make more guesses than policy allows. Counter restarts if
+// previous failure is older than window, key is locked for policy lockout duration
+// once counter reaches max attempts. Attempt isn't reserved while key is locked.
+func (r repository) ReserveAuthAttempt(ctx context.Context, kind, key string, window time.Duration, policy userDomain.LockoutPolicy) (int, bool, error) {
+	var failures int
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`insert into auth_failure(kind, key, failures, last_failure_at, locked_until)
+		values ($1, $2, 1, now(), case when $4::int <= 1 then now() + $5 * interval '1 second' end)
+		on conflict (kind, key) do update set
+			failures = case
+				when auth_failure.last_failure_at < now() - $3 * interval '1 second' then 1
+				else auth_failure.failures + 1
+			end,
+			last_failure_at = now(),
+			locked_until = case
+				when auth_failure.last_failure_at >= now() - $3 * interval '1 second'
+					and auth_failure.failures + 1 >= $4::int then now() + $5 * interval '1 second'
+				else auth_failure.locked_until
+			end
+		where auth_failure.locked_until is null or auth_failure.locked_until <= now()
+		returning failures;`,
+		kind,
+		key,
+		window.Seconds(),
+		policy.MaxAttempts,
+		policy.LockoutDuration.Seconds(),
+	).Scan(&failures)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return 0, false, err
+	}
+
+	return failures, true, nil
+}
+
+// ReleaseAuthAttempt takes back reserved attempt which turned out not to be a wrong guess.
+// Lock set by reservation at max attempts is lifted.
+func (r repository) ReleaseAuthAttempt(ctx context.Context, kind, key string, maxAttempts int) error {
+	_, err := r.conn.ExecContext(
+		ctx,
+		`update auth_failure set
+			failures = greatest(failures - 1, 0),
+			locked_until = case when failures >= $3 then null else locked_until end
+		where kind = $1 and key = $2;`,
+		kind,
+		key,
+		maxAttempts,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// LockAuthKey locks key until given time. Longer lock already set is kept.
+func (r repository) LockAuthKey(ctx context.Context, kind, key string, lockedUntil time.Time) error {
+	_, err := r.conn.ExecContext(
+		ctx,
+		`update auth_failure set locked_until = greatest(locked_until, $3) where kind = $1 and key = $2;`,
+		kind,
+		key,
+		lockedUntil,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+func (r repository) DeleteLockout(ctx context.Context, kind, key string) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`delete from auth_failure where kind = $1 and key = $2;`,
+		kind,
+		key,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// GetLockouts returns currently locked logins and IPs.
+func (r repository) GetLockouts(ctx context.Context) ([]userDomain.Lockout, error) {
+	var lockouts []userDomain.Lockout
+
+	rows, err := r.conn.QueryContext(
+		ctx,
+		`select kind, key, failures, last_failure_at, locked_until
+		from auth_failure
+		where locked_until > now()
+		order by locked_until desc;`,
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return lockouts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l userDomain.Lockout
+
+		err = rows.Scan(&l.Kind, &l.Key, &l.Failures, &l.LastFailureAt, &l.LockedUntil)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return lockouts, err
+		}
+
+		lockouts = append(lockouts, l)
+	}
+
+	return lockouts, rows.Err()
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/lib/pq"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+func (r repository) CreatePasskey(ctx context.Context, login string, passkey userDomain.Passkey) (userDomain.Passkey, error) {
+	err := r.conn.QueryRowContext(
+		ctx,
+		`insert into passkey(credential_id, user_login, name, public_key, attestation_type, transports, aaguid, sign_count, backup_eligible, backup_state)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		returning id, created_at;`,
+		passkey.CredentialID,
+		login,
+		passkey.Name,
+		passkey.PublicKey,
+		passkey.AttestationType,
+		pq.Array(passkey.Transports),
+		passkey.AAGUID,
+		int64(passkey.SignCount),
+		passkey.BackupEligible,
+		passkey.BackupState,
+	).Scan(&passkey.ID, &passkey.CreatedAt)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return passkey, err
+	}
+
+	return passkey, nil
+}
+
+func (r repository) GetUserPasskeys(ctx context.Context, login string) ([]userDomain.Passkey, error) {
+	var passkeys []userDomain.Passkey
+
+	rows, err := r.conn.QueryContext(
+		ctx,
+		`select id, credential_id, name, public_key, attestation_type, transports, aaguid, sign_count,
+		backup_eligible, backup_state, created_at, last_used_at
+		from passkey
+		where user_login = $1
+		order by created_at desc;`,
+		login,
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return passkeys, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p userDomain.Passkey
+		var signCount int64
+
+		err = rows.Scan(
+			&p.ID,
+			&p.CredentialID,
+			&p.Name,
+			&p.PublicKey,
+			&p.AttestationType,
+			pq.Array(&p.Transports),
+			&p.AAGUID,
+			&signCount,
+			&p.BackupEligible,
+			&p.BackupState,
+			&p.CreatedAt,
+			&p.LastUsedAt,
+		)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return passkeys, err
+		}
+		p.SignCount = uint32(signCount)
+
+		passkeys = append(passkeys, p)
+	}
+
+	return passkeys, rows.Err()
+}
+
+func (r repository) DeleteUserPasskey(ctx context.Context, login, id string) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`delete from passkey where user_login = $1 and id = $2;`,
+		login,
+		id,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// GetPasskeyHandle returns user WebAuthn handle, nil if user has never registered passkey.
+func (r repository) GetPasskeyHandle(ctx context.Context, login string) ([]byte, error) {
+	var handle []byte
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select passkey_handle from "user" where login = $1;`,
+		login,
+	).Scan(&handle)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		}
+		return nil, err
+	}
+
+	return handle, nil
+}
+
+// SetPasskeyHandle stores user WebAuthn handle unless user already has one and returns
+// the stored handle, so concurrent registrations agree on it.
+func (r repository) SetPasskeyHandle(ctx context.Context, login string, handle []byte) ([]byte, error) {
+	err := r.conn.QueryRowContext(
+		ctx,
+		`update "user" set passkey_handle = coalesce(passkey_handle, $2) where login = $1 returning passkey_handle;`,
+		login,
+		handle,
+	).Scan(&handle)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return handle, nil
+}
+
+func (r repository) GetUserByPasskeyHandle(ctx context.Context, handle []byte) (userDomain.User, error) {
+	var user userDomain.User
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select id, login, role, is_disabled, must_change_password from "user" where passkey_handle = $1;`,
+		handle,
+	).Scan(&user.ID, &user.Login, &user.Role, &user.IsDisabled, &user.MustChangePassword)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		}
+		return user, err
+	}
+
+	return user, nil
+}
+
+// UpdatePasskeyUsage stores new sign counter of used passkey. It returns false if stored counter
+// is not lower, so concurrent use of the same assertion or cloned key is detected.
+// Authenticators without counter always report zero.
+func (r repository) UpdatePasskeyUsage(ctx context.Context, credentialID []byte, signCount uint32, backupState bool) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`update passkey set sign_count = $2, backup_state = $3, last_used_at = now()
+		where credential_id = $1 and (sign_count < $2 or (sign_count = 0 and $2 = 0));`,
+		credentialID,
+		int64(signCount),
+		backupState,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// SavePasskeySession stores pending WebAuthn ceremony and drops expired ones.
+func (r repository) SavePasskeySession(ctx context.Context, session userDomain.PasskeySession) error {
+	_, err := r.conn.ExecContext(ctx, `delete from passkey_session where expires_at < now();`)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	_, err = r.conn.ExecContext(
+		ctx,
+		`insert into passkey_session(session_hash, kind, user_login, data, device, expires_at) values ($1, $2, $3, $4, $5, $6);`,
+		session.SessionHash,
+		session.Kind,
+		sql.NullString{String: session.Login, Valid: session.Login != ""},
+		session.Data,
+		session.Device,
+		session.ExpiresAt,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// ConsumePasskeySession deletes pending WebAuthn ceremony and returns it, so it can be finished once.
+func (r repository) ConsumePasskeySession(ctx context.Context, sessionHash string) (userDomain.PasskeySession, error) {
+	session := userDomain.PasskeySession{SessionHash: sessionHash}
+	var login sql.NullString
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`delete from passkey_session where session_hash = $1 returning kind, user_login, data, device, expires_at;`,
+		sessionHash,
+	).Scan(&session.Kind, &login, &session.Data, &session.Device, &session.ExpiresAt)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		}
+		return session, err
+	}
+	session.Login = login.String
+
+	return session, nil
+}
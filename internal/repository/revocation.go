@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// GetDatabaseTime returns database clock time, revocation times are taken from it.
+func (r repository) GetDatabaseTime(ctx context.Context) (time.Time, error) {
+	var now time.Time
+
+	err := r.conn.QueryRowContext(ctx, `select clock_timestamp();`).Scan(&now)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return now, err
+	}
+
+	return now, nil
+}
+
+// GetSessionRevocations returns IDs of sessions revoked after since with revocation times.
+func (r repository) GetSessionRevocations(ctx context.Context, since time.Time) (map[string]time.Time, error) {
+	revocations := make(map[string]time.Time)
+
+	rows, err := r.conn.QueryContext(
+		ctx,
+		`select session_id, revoked_at from session_revocation where revoked_at > $1;`,
+		since,
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return revocations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var revokedAt time.Time
+
+		err = rows.Scan(&id, &revokedAt)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return revocations, err
+		}
+
+		revocations[id] = revokedAt
+	}
+
+	return revocations, rows.Err()
+}
+
+func (r repository) DeleteSessionRevocations(ctx context.Context, before time.Time) error {
+	_, err := r.conn.ExecContext(
+		ctx,
+		`delete from session_revocation where revoked_at <= $1;`,
+		before,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// RevokeUserSessions revokes access tokens issued for user sessions so far
+// without deleting sessions. Sessions may get new access tokens with refresh.
+func (r repository) RevokeUserSessions(ctx context.Context, login string) error {
+	_, err := r.conn.ExecContext(
+		ctx,
+		`insert into session_revocation(session_id, revoked_at)
+		select id, clock_timestamp() from auth_token where user_login = $1
+		on conflict (session_id) do update set revoked_at = excluded.revoked_at;`,
+		login,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// GetTOTP returns user TOTP. Zero value is returned if user is not enrolled.
+func (r repository) GetTOTP(ctx context.Context, login string) (userDomain.TOTP, error) {
+	var totp userDomain.TOTP
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select secret, created_at, confirmed_at, last_used_step from user_totp where user_login = $1;`,
+		login,
+	).Scan(&totp.Secret, &totp.CreatedAt, &totp.ConfirmedAt, &totp.LastUsedStep)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return totp, nil
+	}
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return totp, err
+	}
+
+	return totp, nil
+}
+
+// SaveTOTPSecret starts enrollment or replaces secret of unconfirmed one.
+// Confirmed secret is never replaced.
+func (r repository) SaveTOTPSecret(ctx context.Context, login, secret string) error {
+	err := r.conn.QueryRowContext(
+		ctx,
+		`insert into user_totp(user_login, secret) values ($1, $2)
+		on conflict (user_login) do update set secret = excluded.secret, created_at = now()
+		where user_totp.confirmed_at is null;`,
+		login,
+		secret,
+	).Err()
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// ConfirmTOTP enables TOTP and replaces recovery codes.
+func (r repository) ConfirmTOTP(ctx context.Context, login string, step int64, recoveryCodeHashes []string) (err error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update user_totp set confirmed_at = now(), last_used_step = $2 where user_login = $1;`,
+		login,
+		step,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from user_recovery_code where user_login = $1;`, login)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		_, err = tx.ExecContext(
+			ctx,
+			`insert into user_recovery_code(user_login, code_hash) values ($1, $2);`,
+			login,
+			codeHash,
+		)
+		if err != nil {
+			logger.Error("ExecContext error", slog.String("error", err.Error()))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseTOTPStep marks time step as used. It returns false if step or later one was already used.
+func (r repository) UseTOTPStep(ctx context.Context, login string, step int64) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`update user_totp set last_used_step = $2 where user_login = $1 and last_used_step < $2;`,
+		login,
+		step,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// UseRecoveryCode marks recovery code as used. It returns false if code is unknown or used.
+func (r repository) UseRecoveryCode(ctx context.Context, login, codeHash string) (bool, error) {
+	res, err := r.conn.ExecContext(
+		ctx,
+		`update user_recovery_code set used_at = now() where user_login = $1 and code_hash = $2 and used_at is null;`,
+		login,
+		codeHash,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// DeleteTOTP disables TOTP with its recovery codes and pending auth challenges.
+func (r repository) DeleteTOTP(ctx context.Context, login string) (deleted bool, err error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `delete from user_totp where user_login = $1;`, login)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("RowsAffected error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from auth_challenge where user_login = $1;`, login)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (r repository) CreateAuthChallenge(ctx context.Context, challenge userDomain.AuthChallenge) error {
+	err := r.conn.QueryRowContext(
+		ctx,
+		`insert into auth_challenge(challenge_hash, user_login, device, expires_at) values ($1, $2, $3, $4);`,
+		challenge.ChallengeHash,
+		challenge.Login,
+		challenge.Device,
+		challenge.ExpiresAt,
+	).Err()
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+func (r repository) GetAuthChallenge(ctx context.Context, challengeHash string) (userDomain.AuthChallenge, error) {
+	challenge := userDomain.AuthChallenge{ChallengeHash: challengeHash}
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select user_login, device, attempts, expires_at from auth_challenge where challenge_hash = $1;`,
+		challengeHash,
+	).Scan(&challenge.Login, &challenge.Device, &challenge.Attempts, &challenge.ExpiresAt)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return challenge, err
+	}
+
+	return challenge, nil
+}
+
+func (r repository) RegisterAuthChallengeAttempt(ctx context.Context, challengeHash string) (int, error) {
+	var attempts int
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`update auth_challenge set attempts = attempts + 1 where challenge_hash = $1 returning attempts;`,
+		challengeHash,
+	).Scan(&attempts)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+// DeleteAuthChallenge deletes challenge along with expired ones.
+func (r repository) DeleteAuthChallenge(ctx context.Context, challengeHash string) error {
+	_, err := r.conn.ExecContext(
+		ctx,
+		`delete from auth_challenge where challenge_hash = $1 or expires_at < now();`,
+		challengeHash,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"log/slog"
 
+	"github.com/srgklmv/astral/internal/domain/invite"
 	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/pkg/cache"
 	"github.com/srgklmv/astral/pkg/logger"
 )
 
@@ -22,16 +24,16 @@ func (r repository) IsLoginExists(ctx context.Context, login string) (bool, erro
 	return exists, nil
 }
 
-func (r repository) CreateUser(ctx context.Context, login, hashedPassword string, isAdmin bool) (userDomain.User, error) {
+func (r repository) CreateUser(ctx context.Context, login, hashedPassword, role string) (userDomain.User, error) {
 	var user userDomain.User
 
 	err := r.conn.QueryRowContext(
 		ctx,
-		`insert into "user"(login, password, is_admin) values ($1, $2, $3) returning id, login, is_admin;`,
+		`insert into "user"(login, password, role) values ($1, $2, $3) returning id, login, role;`,
 		login,
 		hashedPassword,
-		isAdmin,
-	).Scan(&user.ID, &user.Login, &user.IsAdmin)
+		role,
+	).Scan(&user.ID, &user.Login, &user.Role)
 	if err != nil {
 		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
 		return user, err
@@ -40,25 +42,107 @@ func (r repository) CreateUser(ctx context.Context, login, hashedPassword string
 	return user, nil
 }
 
-func (r repository) GetUserByAuthToken(ctx context.Context, token string) (userDomain.User, error) {
+// CreateUserWithInviteCode consumes invite code and creates user with its role in one transaction.
+func (r repository) CreateUserWithInviteCode(ctx context.Context, codeHash, login, hashedPassword string) (user userDomain.User, err error) {
+	var role string
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return user, err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	err = tx.QueryRowContext(
+		ctx,
+		`update invite_code set uses = uses + 1
+		where code_hash = $1 and revoked_at is null and expires_at > now() and uses < max_uses
+		returning role;`,
+		codeHash,
+	).Scan(&role)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		err = invite.ErrInvalidCode
+		return user, err
+	}
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return user, err
+	}
+
+	err = tx.QueryRowContext(
+		ctx,
+		`insert into "user"(login, password, role) values ($1, $2, $3) returning id, login, role;`,
+		login,
+		hashedPassword,
+		role,
+	).Scan(&user.ID, &user.Login, &user.Role)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return user, err
+	}
+
+	return user, nil
+}
+
+func (r repository) IsAdminExists(ctx context.Context) (bool, error) {
+	var exists bool
+
+	err := r.conn.QueryRowContext(ctx, `select exists(select 1 from "user" where role = $1);`, userDomain.RoleAdmin).Scan(&exists)
+	if err != nil {
+		logger.Error("QueryRowContext", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (r repository) GetUserByAuthToken(ctx context.Context, tokenHash string) (userDomain.User, userDomain.AuthToken, error) {
 	var user userDomain.User
+	authToken := userDomain.AuthToken{TokenHash: tokenHash}
 
 	err := r.conn.QueryRowContext(
 		ctx,
-		`select u.id, u.login, u.is_admin 
+		`select u.id, u.login, u.role, u.is_disabled, u.must_change_password, at.id, at.device, at.user_agent, at.ip, at.created_at, at.expires_at, at.last_used_at
 		from auth_token at
 		left join "user" u on at.user_login = u.login		    
-		where at.token = $1;`,
-		token,
-	).Scan(&user.ID, &user.Login, &user.IsAdmin)
+		where at.token_hash = $1;`,
+		tokenHash,
+	).Scan(
+		&user.ID,
+		&user.Login,
+		&user.Role,
+		&user.IsDisabled,
+		&user.MustChangePassword,
+		&authToken.ID,
+		&authToken.Device,
+		&authToken.UserAgent,
+		&authToken.IP,
+		&authToken.CreatedAt,
+		&authToken.ExpiresAt,
+		&authToken.LastUsedAt,
+	)
 	if err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
 			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
 		}
-		return user, err
+		return user, authToken, err
 	}
 
-	return user, nil
+	return user, authToken, nil
 }
 
 func (r repository) GetUserHashedPassword(ctx context.Context, login string) (string, error) {
@@ -76,3 +160,355 @@ func (r repository) GetUserHashedPassword(ctx context.Context, login string) (st
 
 	return password, nil
 }
+
+func (r repository) GetUserByLogin(ctx context.Context, login string) (userDomain.User, error) {
+	var user userDomain.User
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select id, login, role, is_disabled, must_change_password from "user" where login = $1;`,
+		login,
+	).Scan(&user.ID, &user.Login, &user.Role, &user.IsDisabled, &user.MustChangePassword)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		}
+		return user, err
+	}
+
+	return user, nil
+}
+
+func (r repository) UpdateUserPassword(ctx context.Context, login, hashedPassword string, mustChangePassword bool) error {
+	err := r.conn.QueryRowContext(
+		ctx,
+		`update "user" set password = $2, must_change_password = $3 where login = $1;`,
+		login,
+		hashedPassword,
+		mustChangePassword,
+	).Err()
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// UpdateUserPasswordHash replaces password hash keeping must change flag.
+// Hash is not replaced if password was changed concurrently.
+func (r repository) UpdateUserPasswordHash(ctx context.Context, login, oldHashedPassword, hashedPassword string) error {
+	_, err := r.conn.ExecContext(
+		ctx,
+		`update "user" set password = $3 where login = $1 and password = $2;`,
+		login,
+		oldHashedPassword,
+		hashedPassword,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+func (r repository) GetUserDeletionReport(ctx context.Context, login string) (userDomain.DeletionReport, error) {
+	var report userDomain.DeletionReport
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select
+			(select count(*) from document where owner_login = $1),
+			(select count(distinct d.id) from document d join user_document_access uda on d.id = uda.document_id where d.owner_login = $1),
+			(select count(*) from user_document_access where user_login = $1),
+			(select count(*) from auth_token where user_login = $1 and expires_at > now());`,
+		login,
+	).Scan(&report.OwnedDocuments, &report.SharedDocuments, &report.ReceivedGrants, &report.Sessions)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return report, err
+	}
+
+	return report, nil
+}
+
+// CountAdmins counts enabled admins, who are able to manage users.
+func (r repository) CountAdmins(ctx context.Context) (int, error) {
+	var count int
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select count(*) from "user" where role = $1 and not is_disabled;`,
+		userDomain.RoleAdmin,
+	).Scan(&count)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// lockAdmins locks enabled admins till the end of transaction and returns their count,
+// so concurrent demotions or deletions can't leave no admin at all.
+func lockAdmins(ctx context.Context, tx *sql.Tx) (int, error) {
+	var count int
+
+	err := tx.QueryRowContext(
+		ctx,
+		`select count(*) from (
+			select 1 from "user" where role = $1 and not is_disabled for update
+		) admins;`,
+		userDomain.RoleAdmin,
+	).Scan(&count)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// checkAdminsLeft returns ErrLastAdmin if transaction took away the last enabled admin
+// out of locked ones.
+func checkAdminsLeft(ctx context.Context, tx *sql.Tx, locked int) error {
+	var count int
+
+	err := tx.QueryRowContext(
+		ctx,
+		`select count(*) from "user" where role = $1 and not is_disabled;`,
+		userDomain.RoleAdmin,
+	).Scan(&count)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	if locked > 0 && count == 0 {
+		return userDomain.ErrLastAdmin
+	}
+
+	return nil
+}
+
+// EnsureArchiveUser creates system archive account for documents of deleted users.
+// Existing regular account with the same login is never adopted.
+func (r repository) EnsureArchiveUser(ctx context.Context, login string) error {
+	var isSystem bool
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`with inserted as (
+			insert into "user"(login, password, role, is_system) values ($1, '!', $2, true)
+			on conflict (login) do nothing
+			returning is_system
+		)
+		select is_system from inserted
+		union all
+		select is_system from "user" where login = $1
+		limit 1;`,
+		login,
+		userDomain.RoleViewer,
+	).Scan(&isSystem)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	if !isSystem {
+		return userDomain.ErrArchiveLoginTaken
+	}
+
+	return nil
+}
+
+// DeleteUser deletes user. Owned documents are transferred to transferTo login
+// if provided, otherwise they are deleted along with the user.
+// ErrLastAdmin is returned if the user is the last enabled admin.
+func (r repository) DeleteUser(ctx context.Context, login, transferTo string) (err error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+				return
+			}
+
+			// Documents and listings of any user may contain deleted or transferred documents,
+			// so all of them are invalidated.
+			cache.Cache.Invalidate("GetDocument")
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	admins, err := lockAdmins(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if transferTo != "" {
+		// New owner does not need grants to own documents.
+		_, err = tx.ExecContext(
+			ctx,
+			`delete from user_document_access
+			where user_login = $2 and document_id in (select id from document where owner_login = $1);`,
+			login,
+			transferTo,
+		)
+		if err != nil {
+			logger.Error("ExecContext error", slog.String("error", err.Error()))
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`update document set owner_login = $2 where owner_login = $1;`,
+			login,
+			transferTo,
+		)
+		if err != nil {
+			logger.Error("ExecContext error", slog.String("error", err.Error()))
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from "user" where login = $1;`, login)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = checkAdminsLeft(ctx, tx, admins)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r repository) GetUsers(ctx context.Context, search string, limit, offset int) ([]userDomain.Summary, int, error) {
+	var users []userDomain.Summary
+	var total int
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select count(*) from "user" where $1 = '' or strpos(lower(login), lower($1)) > 0;`,
+		search,
+	).Scan(&total)
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return users, 0, err
+	}
+
+	rows, err := r.conn.QueryContext(
+		ctx,
+		`select u.id, u.login, u.role, u.is_disabled, u.must_change_password, u.last_login_at, count(d.id)
+		from "user" u
+		left join document d on d.owner_login = u.login
+		where $1 = '' or strpos(lower(u.login), lower($1)) > 0
+		group by u.id
+		order by u.login asc
+		limit $2 offset $3;`,
+		search,
+		limit,
+		offset,
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return users, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user userDomain.Summary
+
+		err = rows.Scan(
+			&user.ID,
+			&user.Login,
+			&user.Role,
+			&user.IsDisabled,
+			&user.MustChangePassword,
+			&user.LastLoginAt,
+			&user.DocumentsCount,
+		)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return users, 0, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, total, rows.Err()
+}
+
+// UpdateUserAccess sets user role and disabled flag, nil values are left as is.
+// ErrLastAdmin is returned if no enabled admin would be left.
+func (r repository) UpdateUserAccess(ctx context.Context, login string, role *string, isDisabled *bool) (err error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	admins, err := lockAdmins(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update "user" set role = coalesce($2, role), is_disabled = coalesce($3, is_disabled) where login = $1;`,
+		login,
+		role,
+		isDisabled,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = checkAdminsLeft(ctx, tx, admins)
+	return err
+}
+
+func (r repository) UpdateLastLogin(ctx context.Context, login string) error {
+	err := r.conn.QueryRowContext(
+		ctx,
+		`update "user" set last_login_at = now() where login = $1;`,
+		login,
+	).Err()
+	if err != nil {
+		logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/srgklmv/astral/internal/domain/document"
+	"github.com/srgklmv/astral/pkg/cache"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// GetDocumentVersions returns versions metadata from the newest one.
+func (r repository) GetDocumentVersions(ctx context.Context, id uuid.UUID) ([]document.Version, error) {
+	var versions []document.Version
+
+	rows, err := r.conn.QueryContext(
+		ctx,
+		`select version, name, is_file, mimetype, size, checksum, author_login, created_at
+		from document_version
+		where document_id = $1
+		order by version desc;`,
+		id.String(),
+	)
+	if err != nil {
+		logger.Error("QueryContext error", slog.String("error", err.Error()))
+		return versions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		v := document.Version{DocumentID: id}
+		var mimetype sql.NullString
+
+		err = rows.Scan(&v.Number, &v.Filename, &v.IsFile, &mimetype, &v.Size, &v.Checksum, &v.Author, &v.CreatedAt)
+		if err != nil {
+			logger.Error("Scan error", slog.String("error", err.Error()))
+			return versions, err
+		}
+		v.Mimetype = mimetype.String
+
+		versions = append(versions, v)
+	}
+
+	return versions, rows.Err()
+}
+
+// GetDocumentVersion returns version with its content.
+func (r repository) GetDocumentVersion(ctx context.Context, id uuid.UUID, number int) (document.Version, error) {
+	cacheKey := fmt.Sprintf("%s%s:%d", "GetDocumentVersion", id.String(), number)
+	if k, ok := cache.Cache.Get(cacheKey); ok {
+		value := k.(document.Version)
+		return value, nil
+	}
+
+	v := document.Version{DocumentID: id, Number: number}
+	var mimetype sql.NullString
+	var jsonb []byte
+
+	err := r.conn.QueryRowContext(
+		ctx,
+		`select name, is_file, mimetype, json, file, size, checksum, author_login, created_at
+		from document_version
+		where document_id = $1 and version = $2;`,
+		id.String(),
+		number,
+	).Scan(&v.Filename, &v.IsFile, &mimetype, &jsonb, &v.File, &v.Size, &v.Checksum, &v.Author, &v.CreatedAt)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		}
+		return v, err
+	}
+	v.Mimetype = mimetype.String
+
+	if jsonb != nil {
+		err = json.Unmarshal(jsonb, &v.JSON)
+		if err != nil {
+			logger.Error("Unmarshal error", slog.String("error", err.Error()))
+			return v, err
+		}
+	}
+
+	cache.Cache.Set(cacheKey, v)
+	return v, nil
+}
+
+// RestoreDocumentVersion makes content of old version current. Restored content is kept
+// as a new version, so history is not rewritten. It returns number of the new version.
+func (r repository) RestoreDocumentVersion(ctx context.Context, id uuid.UUID, number int, author string, keepVersions int) (version int, err error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("database transaction error", slog.String("error", err.Error()))
+		return version, err
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				logger.Error("commit error", slog.String("error", err.Error()))
+				return
+			}
+
+			// Restored name is shown in listings.
+			cache.Cache.Invalidate(id.String())
+			cache.Cache.Invalidate("GetDocumentsData")
+			return
+		}
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("rollback error", slog.String("error", rollbackErr.Error()))
+		}
+	}()
+
+	err = tx.QueryRowContext(
+		ctx,
+		`update document d
+		set name = v.name, is_file = v.is_file, mimetype = v.mimetype, json = v.json, file = v.file, version = d.version + 1
+		from document_version v
+		where d.id = $1 and v.document_id = d.id and v.version = $2
+		returning d.version;`,
+		id.String(),
+		number,
+	).Scan(&version)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("QueryRowContext error", slog.String("error", err.Error()))
+		}
+		return version, err
+	}
+
+	err = insertDocumentVersion(ctx, tx, id, author, keepVersions)
+	if err != nil {
+		return version, err
+	}
+
+	return version, nil
+}
+
+// insertDocumentVersion stores current document content as a version and drops versions
+// beyond keepVersions newest ones. Zero keepVersions keeps all of them.
+func insertDocumentVersion(ctx context.Context, tx *sql.Tx, id uuid.UUID, author string, keepVersions int) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`insert into document_version (document_id, version, name, is_file, mimetype, json, file, size, checksum, author_login)
+		select d.id, d.version, d.name, d.is_file, d.mimetype, d.json, d.file,
+			octet_length(c.content), encode(sha256(c.content), 'hex'), $2
+		from document d,
+			lateral (select case when d.is_file then coalesce(d.file, ''::bytea) else convert_to(coalesce(d.json::text, ''), 'UTF8') end as content) c
+		where d.id = $1;`,
+		id.String(),
+		author,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	if keepVersions <= 0 {
+		return nil
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`delete from document_version
+		where document_id = $1 and version <= (select version from document where id = $1) - $2;`,
+		id.String(),
+		keepVersions,
+	)
+	if err != nil {
+		logger.Error("ExecContext error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// Refresh rotates refresh token and issues new access token for its session.
+// Rotated refresh token can't be used again.
+func (u usecase) Refresh(ctx context.Context, refreshToken string) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	if u.accessTokenSigner == nil {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.AccessTokensDisabledErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	if !userDomain.IsRefreshToken(refreshToken) {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.UnauthorizedErrorCode,
+			Text: apperrors.UnauthorizedErrorText,
+		}, nil, nil), http.StatusUnauthorized
+	}
+
+	tokenHash := userDomain.HashAuthToken(refreshToken, u.tokenPepper)
+
+	user, authToken, err := u.userRepository.GetUserByAuthToken(ctx, tokenHash)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.UnauthorizedErrorCode,
+			Text: apperrors.UnauthorizedErrorText,
+		}, nil, nil), http.StatusUnauthorized
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	if user.IsDisabled {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.UserDisabledErrorCode,
+			Text: apperrors.UserDisabledErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	now := time.Now()
+	if authToken.IsExpired(now) {
+		err = u.userRepository.DeleteToken(ctx, tokenHash)
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.AuthTokenExpiredErrorCode,
+			Text: apperrors.AuthTokenExpiredErrorText,
+		}, nil, nil), http.StatusUnauthorized
+	}
+
+	newRefreshToken := userDomain.GenerateRefreshToken()
+
+	rotated, err := u.userRepository.RotateAuthToken(
+		ctx,
+		tokenHash,
+		userDomain.HashAuthToken(newRefreshToken, u.tokenPepper),
+		now.Add(u.refreshTokenTTL),
+	)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	// Concurrent refresh has rotated token first.
+	if !rotated {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.UnauthorizedErrorCode,
+			Text: apperrors.UnauthorizedErrorText,
+		}, nil, nil), http.StatusUnauthorized
+	}
+
+	return u.accessTokenResponse(user, authToken.ID, newRefreshToken, http.StatusOK)
+}
+
+// StartDenylistSync loads revoked sessions and keeps them in sync in background until ctx is done.
+// It does nothing unless stateless access tokens are enabled.
+func (u usecase) StartDenylistSync(ctx context.Context) error {
+	if u.accessTokenSigner == nil {
+		return nil
+	}
+
+	err := u.syncDenylist(ctx)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(u.denylistSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := u.syncDenylist(ctx)
+				if err != nil {
+					logger.Error("denylist sync error", slog.String("error", err.Error()))
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// syncDenylist replaces in-memory denylist with revocations which may still affect
+// unexpired access tokens and purges older ones.
+// Both revocation and access token issue times are compared in database clock.
+func (u usecase) syncDenylist(ctx context.Context) error {
+	requestedAt := time.Now()
+	dbNow, err := u.revocationRepository.GetDatabaseTime(ctx)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return err
+	}
+	// Database time is taken somewhere during round trip, its middle is the best guess.
+	clockOffset := dbNow.Sub(requestedAt.Add(time.Since(requestedAt) / 2))
+
+	since := dbNow.Add(-u.accessTokenTTL - u.denylistSyncInterval)
+
+	revocations, err := u.revocationRepository.GetSessionRevocations(ctx, since)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return err
+	}
+
+	u.denylist.Replace(revocations, clockOffset)
+
+	err = u.revocationRepository.DeleteSessionRevocations(ctx, since)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// authorizeUserByAccessToken resolves principal by signed access token without database lookup.
+func (u usecase) authorizeUserByAccessToken(token string) (bool, userDomain.Principal, error) {
+	var principal userDomain.Principal
+
+	if u.accessTokenSigner == nil {
+		return false, principal, nil
+	}
+
+	claims, err := u.accessTokenSigner.Verify(token, time.Now())
+	if errors.Is(err, userDomain.ErrAuthTokenExpired) {
+		return false, principal, err
+	}
+	if err != nil {
+		return false, principal, nil
+	}
+
+	if u.denylist.IsRevoked(claims) {
+		return false, principal, nil
+	}
+
+	return true, claims.Principal(), nil
+}
+
+func (u usecase) accessTokenResponse(user userDomain.User, sessionID, refreshToken string, status int) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	accessToken, err := u.accessTokenSigner.Sign(userDomain.NewAccessClaims(user, sessionID, time.Now(), u.accessTokenTTL))
+	if err != nil {
+		logger.Error("access token signing error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.AuthTokenGenerationErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.AuthResponse, any](
+		nil,
+		&dto.AuthResponse{
+			Token:              accessToken,
+			MustChangePassword: user.MustChangePassword,
+			RefreshToken:       refreshToken,
+			ExpiresIn:          int(u.accessTokenTTL.Seconds()),
+		},
+		nil,
+	), status
+}
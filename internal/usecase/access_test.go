@@ -0,0 +1,164 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/dto"
+)
+
+// newAccessTokensTestUsecase returns usecase in stateless access tokens mode.
+func newAccessTokensTestUsecase(t *testing.T, repo *fakeRepository) usecase {
+	t.Helper()
+
+	signer, err := userDomain.NewAccessTokenSigner(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatalf("NewAccessTokenSigner: %v", err)
+	}
+
+	u := newTestUsecase(repo)
+	u.accessTokenSigner = &signer
+	u.accessTokenTTL = 15 * time.Minute
+	u.refreshTokenTTL = time.Hour
+	u.denylist = userDomain.NewDenylist()
+	u.denylistSyncInterval = 10 * time.Millisecond
+
+	return u
+}
+
+// signInWithRefresh authenticates user and returns issued access and refresh tokens.
+func signInWithRefresh(t *testing.T, u usecase, login, password string) dto.AuthResponse {
+	t.Helper()
+
+	result, status := u.Auth(context.Background(), login, password, "device", "agent", "127.0.0.1")
+	if status != http.StatusCreated {
+		t.Fatalf("Auth status = %d, want %d", status, http.StatusCreated)
+	}
+	if result.Response.Token == "" || result.Response.RefreshToken == "" {
+		t.Fatalf("Auth = %+v, want access and refresh tokens", result.Response)
+	}
+
+	return *result.Response
+}
+
+func TestRefreshRotation(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUserWithPassword(userDomain.User{Login: "aliceuser", Role: userDomain.RoleEditor}, "Password1!")
+	u := newAccessTokensTestUsecase(t, repo)
+
+	tokens := signInWithRefresh(t, u, "aliceuser", "Password1!")
+	session := principalByToken(t, u, tokens.Token).Session.ID
+
+	// Refresh token doesn't authorize requests.
+	if ok, _, _ := u.AuthorizeUserByToken(context.Background(), tokens.RefreshToken); ok {
+		t.Fatal("refresh token authorizes")
+	}
+	_, status := u.Refresh(context.Background(), tokens.Token)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("Refresh with access token status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	refreshed, status := u.Refresh(context.Background(), tokens.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("Refresh status = %d, want %d", status, http.StatusOK)
+	}
+	if refreshed.Response.RefreshToken == tokens.RefreshToken {
+		t.Fatal("refresh token isn't rotated")
+	}
+	if got := principalByToken(t, u, refreshed.Response.Token).Session.ID; got != session {
+		t.Fatalf("refreshed session = %q, want %q", got, session)
+	}
+
+	// Rotated token can't be used again.
+	_, status = u.Refresh(context.Background(), tokens.RefreshToken)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("Refresh with rotated token status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	_, status = u.Refresh(context.Background(), refreshed.Response.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("Refresh with new token status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestRefreshExpired(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUserWithPassword(userDomain.User{Login: "aliceuser", Role: userDomain.RoleEditor}, "Password1!")
+	u := newAccessTokensTestUsecase(t, repo)
+
+	tokens := signInWithRefresh(t, u, "aliceuser", "Password1!")
+	tokenHash := userDomain.HashAuthToken(tokens.RefreshToken, u.tokenPepper)
+	if d := time.Until(repo.tokenExpiry(tokenHash)); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("refresh token expires in %v, want about %v", d, u.refreshTokenTTL)
+	}
+
+	repo.setTokenExpiry(tokenHash, time.Now().Add(-time.Second))
+	_, status := u.Refresh(context.Background(), tokens.RefreshToken)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("Refresh with expired token status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if d := repo.tokenExpiry(tokenHash); !d.IsZero() {
+		t.Fatal("expired refresh token isn't deleted")
+	}
+}
+
+func TestDenylistSync(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUserWithPassword(userDomain.User{Login: "aliceuser", Role: userDomain.RoleEditor}, "Password1!")
+	repo.addUserWithPassword(userDomain.User{Login: "bobuser1", Role: userDomain.RoleEditor}, "Password1!")
+	u := newAccessTokensTestUsecase(t, repo)
+
+	alice := signInWithRefresh(t, u, "aliceuser", "Password1!")
+	bob := signInWithRefresh(t, u, "bobuser1", "Password1!")
+
+	// Revocation too old to affect unexpired access tokens is purged.
+	repo.revocations["stale"] = time.Now().Add(-time.Hour)
+
+	err := repo.RevokeUserSessions(context.Background(), "aliceuser")
+	if err != nil {
+		t.Fatalf("RevokeUserSessions: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = u.StartDenylistSync(ctx)
+	if err != nil {
+		t.Fatalf("StartDenylistSync: %v", err)
+	}
+
+	if ok, _, _ := u.AuthorizeUserByToken(context.Background(), alice.Token); ok {
+		t.Fatal("access token of revoked session authorizes")
+	}
+	principalByToken(t, u, bob.Token)
+
+	repo.mutex.Lock()
+	_, stale := repo.revocations["stale"]
+	repo.mutex.Unlock()
+	if stale {
+		t.Fatal("stale revocation isn't purged")
+	}
+
+	// Sync stops with context.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+
+	repo.mutex.Lock()
+	syncs := repo.denylistSyncs
+	repo.mutex.Unlock()
+	if syncs < 2 {
+		t.Fatalf("denylist syncs = %d, want periodic syncs", syncs)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if repo.denylistSyncs != syncs {
+		t.Fatalf("denylist syncs after cancel = %d, want %d", repo.denylistSyncs, syncs)
+	}
+}
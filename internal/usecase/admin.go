@@ -0,0 +1,239 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/srgklmv/astral/internal/domain/invite"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+const (
+	defaultInviteCodeTTL   = time.Hour * 24 * 7
+	bootstrapInviteCodeTTL = time.Hour * 24
+)
+
+func (u usecase) CreateInviteCode(ctx context.Context, principal userDomain.Principal, request dto.CreateInviteCodeRequest) (dto.APIResponse[*dto.CreateInviteCodeResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.CreateInviteCodeResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	if request.Role == "" {
+		request.Role = u.defaultRole
+	}
+	if request.Uses == 0 {
+		request.Uses = 1
+	}
+
+	ttl := defaultInviteCodeTTL
+	if request.TTL != 0 {
+		ttl = time.Duration(request.TTL) * time.Second
+	}
+
+	switch {
+	case !userDomain.IsValidRole(request.Role):
+		return dto.NewAPIResponse[*dto.CreateInviteCodeResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.BadRoleErrorText,
+		}, nil, nil), http.StatusBadRequest
+	case request.Uses < 0:
+		return dto.NewAPIResponse[*dto.CreateInviteCodeResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.BadInviteUsesErrorText,
+		}, nil, nil), http.StatusBadRequest
+	case ttl < 0:
+		return dto.NewAPIResponse[*dto.CreateInviteCodeResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.BadInviteTTLErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	code, err := invite.GenerateCode()
+	if err != nil {
+		logger.Error("invite code generation error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.CreateInviteCodeResponse, any](&dto.Error{
+			Code: apperrors.InviteCodeGenerationErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	inviteCode, err := u.inviteRepository.CreateInviteCode(
+		ctx,
+		invite.HashCode(code),
+		request.Role,
+		request.Uses,
+		&principal.Login,
+		time.Now().Add(ttl),
+	)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.CreateInviteCodeResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.CreateInviteCodeResponse, any](nil, &dto.CreateInviteCodeResponse{
+		InviteCode: dto.NewInviteCode(inviteCode),
+		Code:       code,
+	}, nil), http.StatusCreated
+}
+
+func (u usecase) GetInviteCodes(ctx context.Context, principal userDomain.Principal) (dto.APIResponse[*dto.GetInviteCodesResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.GetInviteCodesResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	codes, err := u.inviteRepository.GetInviteCodes(ctx)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.GetInviteCodesResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	codesDTO := dto.NewGetInviteCodesResponse().FromDomain(codes)
+
+	return dto.NewAPIResponse[*dto.GetInviteCodesResponse, any](nil, &codesDTO, nil), http.StatusOK
+}
+
+func (u usecase) RevokeInviteCode(ctx context.Context, principal userDomain.Principal, id string) (dto.APIResponse[dto.RevokeInviteCodeResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[dto.RevokeInviteCodeResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	revoked, err := u.inviteRepository.RevokeInviteCode(ctx, id)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.RevokeInviteCodeResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !revoked {
+		return dto.NewAPIResponse[dto.RevokeInviteCodeResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.InviteCodeNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	return dto.NewAPIResponse[dto.RevokeInviteCodeResponse, any](nil, dto.RevokeInviteCodeResponse{
+		id: true,
+	}, nil), http.StatusOK
+}
+
+// ResetUserPassword sets temporary password which must be changed at next login
+// and revokes all user sessions.
+func (u usecase) ResetUserPassword(ctx context.Context, principal userDomain.Principal, login string) (dto.APIResponse[*dto.ResetUserPasswordResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.ResetUserPasswordResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	exists, err := u.userRepository.IsLoginExists(ctx, login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ResetUserPasswordResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !exists {
+		return dto.NewAPIResponse[*dto.ResetUserPasswordResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.UserNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	password, err := userDomain.GenerateTemporaryPassword()
+	if err != nil {
+		logger.Error("temporary password generation error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ResetUserPasswordResponse, any](&dto.Error{
+			Code: apperrors.PasswordHashErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	hashedPassword, err := u.hashParams.HashPassword(password)
+	if err != nil {
+		logger.Error("password hashing error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ResetUserPasswordResponse, any](&dto.Error{
+			Code: apperrors.PasswordHashErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	err = u.userRepository.UpdateUserPassword(ctx, login, hashedPassword, true)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ResetUserPasswordResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	_, err = u.userRepository.DeleteAllUserTokens(ctx, login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ResetUserPasswordResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.ResetUserPasswordResponse, any](nil, &dto.ResetUserPasswordResponse{
+		Login:    login,
+		Password: password,
+	}, nil), http.StatusOK
+}
+
+// BootstrapAdminInviteCode prints one-time admin invite code if there is no admin yet.
+// Bootstrap codes from previous runs are revoked.
+func (u usecase) BootstrapAdminInviteCode(ctx context.Context) error {
+	adminExists, err := u.userRepository.IsAdminExists(ctx)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return err
+	}
+	if adminExists {
+		return nil
+	}
+
+	err = u.inviteRepository.RevokeBootstrapInviteCodes(ctx)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return err
+	}
+
+	code, err := invite.GenerateCode()
+	if err != nil {
+		logger.Error("invite code generation error", slog.String("error", err.Error()))
+		return err
+	}
+
+	_, err = u.inviteRepository.CreateInviteCode(ctx, invite.HashCode(code), userDomain.RoleAdmin, 1, nil, time.Now().Add(bootstrapInviteCodeTTL))
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return err
+	}
+
+	logger.Info("No admin found. Register the first one with this invite code.", slog.String("invite", code))
+	return nil
+}
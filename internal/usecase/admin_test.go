@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/dto"
+)
+
+func TestInviteCodes(t *testing.T) {
+	repo := newFakeRepository()
+	admin := repo.addUser(userDomain.User{Login: "admin", Role: userDomain.RoleAdmin})
+	editor := repo.addUser(userDomain.User{Login: "editor", Role: userDomain.RoleEditor})
+	u := newTestUsecase(repo)
+
+	_, status := u.CreateInviteCode(context.Background(), userDomain.Principal{User: editor}, dto.CreateInviteCodeRequest{})
+	if status != http.StatusForbidden {
+		t.Fatalf("CreateInviteCode by editor status = %d, want %d", status, http.StatusForbidden)
+	}
+
+	_, status = u.CreateInviteCode(context.Background(), userDomain.Principal{User: admin}, dto.CreateInviteCodeRequest{Role: "superuser"})
+	if status != http.StatusBadRequest {
+		t.Fatalf("CreateInviteCode with unknown role status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	created, status := u.CreateInviteCode(context.Background(), userDomain.Principal{User: admin}, dto.CreateInviteCodeRequest{Role: userDomain.RoleAuditor})
+	if status != http.StatusCreated {
+		t.Fatalf("CreateInviteCode status = %d, want %d", status, http.StatusCreated)
+	}
+	code := created.Response.Code
+
+	_, status = u.Register(context.Background(), code, "aliceuser", "Password1!")
+	if status != http.StatusCreated {
+		t.Fatalf("Register with invite code status = %d, want %d", status, http.StatusCreated)
+	}
+	if user, _ := repo.GetUserByLogin(context.Background(), "aliceuser"); user.Role != userDomain.RoleAuditor {
+		t.Fatalf("registered role = %q, want %q", user.Role, userDomain.RoleAuditor)
+	}
+
+	// Single use code is used up.
+	_, status = u.Register(context.Background(), code, "bobuser1", "Password1!")
+	if status != http.StatusBadRequest {
+		t.Fatalf("Register with used code status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	created, _ = u.CreateInviteCode(context.Background(), userDomain.Principal{User: admin}, dto.CreateInviteCodeRequest{Uses: 2})
+	_, status = u.RevokeInviteCode(context.Background(), userDomain.Principal{User: admin}, created.Response.ID)
+	if status != http.StatusOK {
+		t.Fatalf("RevokeInviteCode status = %d, want %d", status, http.StatusOK)
+	}
+	_, status = u.Register(context.Background(), created.Response.Code, "bobuser1", "Password1!")
+	if status != http.StatusBadRequest {
+		t.Fatalf("Register with revoked code status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	// Without code new user gets default role.
+	_, status = u.Register(context.Background(), "", "caroluser", "Password1!")
+	if status != http.StatusCreated {
+		t.Fatalf("Register without invite code status = %d, want %d", status, http.StatusCreated)
+	}
+	if user, _ := repo.GetUserByLogin(context.Background(), "caroluser"); user.Role != u.defaultRole {
+		t.Fatalf("registered role = %q, want %q", user.Role, u.defaultRole)
+	}
+}
+
+func TestBootstrapAdminInviteCode(t *testing.T) {
+	repo := newFakeRepository()
+	u := newTestUsecase(repo)
+
+	for range 2 {
+		err := u.BootstrapAdminInviteCode(context.Background())
+		if err != nil {
+			t.Fatalf("BootstrapAdminInviteCode: %v", err)
+		}
+	}
+
+	// Restart revokes code printed before.
+	var active int
+	for _, code := range repo.invites {
+		if code.RevokedAt == nil {
+			active++
+			if code.Role != userDomain.RoleAdmin || code.MaxUses != 1 {
+				t.Fatalf("bootstrap code role %q, uses %d, want admin single use", code.Role, code.MaxUses)
+			}
+		}
+	}
+	if len(repo.invites) != 2 || active != 1 {
+		t.Fatalf("invite codes = %d, active %d, want 2, 1", len(repo.invites), active)
+	}
+
+	repo.addUser(userDomain.User{Login: "admin", Role: userDomain.RoleAdmin})
+	err := u.BootstrapAdminInviteCode(context.Background())
+	if err != nil {
+		t.Fatalf("BootstrapAdminInviteCode: %v", err)
+	}
+	if len(repo.invites) != 2 {
+		t.Fatal("bootstrap code created while admin exists")
+	}
+}
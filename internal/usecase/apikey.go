@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/srgklmv/astral/internal/domain/document"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// CreateAPIKey creates scoped API key. Key is returned only once and stored hashed.
+func (u usecase) CreateAPIKey(ctx context.Context, principal userDomain.Principal, request dto.CreateAPIKeyRequest) (dto.APIResponse[*dto.CreateAPIKeyResponse, any], int) {
+	isValid, errText := validateCreateAPIKeyRequest(request)
+	if !isValid {
+		return dto.NewAPIResponse[*dto.CreateAPIKeyResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: errText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	key, err := userDomain.GenerateAPIKey()
+	if err != nil {
+		logger.Error("api key generation error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.CreateAPIKeyResponse, any](&dto.Error{
+			Code: apperrors.AuthTokenGenerationErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	apiKey := userDomain.APIKey{
+		KeyHash:     userDomain.HashAuthToken(key, u.tokenPepper),
+		Name:        request.Name,
+		Scopes:      slices.Compact(slices.Sorted(slices.Values(request.Scopes))),
+		DocumentIDs: request.Documents,
+		Tags:        document.NormalizeTags(request.Tags),
+	}
+	if request.TTL != 0 {
+		expiresAt := time.Now().Add(time.Duration(request.TTL) * time.Second)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	apiKey, err = u.apiKeyRepository.CreateAPIKey(ctx, principal.Login, apiKey)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.CreateAPIKeyResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.CreateAPIKeyResponse, any](nil, &dto.CreateAPIKeyResponse{
+		APIKey: dto.NewAPIKey(apiKey),
+		Key:    key,
+	}, nil), http.StatusCreated
+}
+
+func (u usecase) GetAPIKeys(ctx context.Context, principal userDomain.Principal) (dto.APIResponse[*dto.GetAPIKeysResponse, any], int) {
+	apiKeys, err := u.apiKeyRepository.GetUserAPIKeys(ctx, principal.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.GetAPIKeysResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	apiKeysDTO := dto.NewGetAPIKeysResponse().FromDomain(apiKeys)
+
+	return dto.NewAPIResponse[*dto.GetAPIKeysResponse, any](nil, &apiKeysDTO, nil), http.StatusOK
+}
+
+func (u usecase) RevokeAPIKey(ctx context.Context, principal userDomain.Principal, id string) (dto.APIResponse[dto.RevokeAPIKeyResponse, any], int) {
+	deleted, err := u.apiKeyRepository.DeleteUserAPIKey(ctx, principal.Login, id)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.RevokeAPIKeyResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !deleted {
+		return dto.NewAPIResponse[dto.RevokeAPIKeyResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.APIKeyNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	return dto.NewAPIResponse[dto.RevokeAPIKeyResponse, any](nil, dto.RevokeAPIKeyResponse{
+		id: true,
+	}, nil), http.StatusOK
+}
+
+// authorizeUserByAPIKey resolves principal by API key.
+func (u usecase) authorizeUserByAPIKey(ctx context.Context, key string) (bool, userDomain.Principal, error) {
+	var principal userDomain.Principal
+
+	keyHash := userDomain.HashAuthToken(key, u.tokenPepper)
+
+	user, apiKey, err := u.apiKeyRepository.GetUserByAPIKey(ctx, keyHash)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return false, principal, nil
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return false, principal, err
+	}
+
+	if user.IsDisabled {
+		return false, principal, userDomain.ErrUserDisabled
+	}
+
+	now := time.Now()
+	if apiKey.IsExpired(now) {
+		return false, principal, userDomain.ErrAuthTokenExpired
+	}
+
+	err = u.apiKeyRepository.TouchAPIKey(ctx, keyHash)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return false, principal, err
+	}
+	apiKey.LastUsedAt = &now
+
+	return true, userDomain.Principal{User: user, APIKey: &apiKey}, nil
+}
+
+func validateCreateAPIKeyRequest(request dto.CreateAPIKeyRequest) (bool, apperrors.ErrorText) {
+	if request.Name == "" {
+		return false, apperrors.APIKeyNameRequiredErrorText
+	}
+
+	if len(request.Scopes) == 0 {
+		return false, apperrors.BadAPIKeyScopeErrorText
+	}
+	for _, scope := range request.Scopes {
+		if !userDomain.IsValidScope(scope) {
+			return false, apperrors.BadAPIKeyScopeErrorText
+		}
+	}
+
+	for _, id := range request.Documents {
+		if _, err := uuid.Parse(id); err != nil {
+			return false, apperrors.BadAPIKeyDocumentErrorText
+		}
+	}
+
+	if request.TTL < 0 {
+		return false, apperrors.BadAPIKeyTTLErrorText
+	}
+
+	return true, ""
+}
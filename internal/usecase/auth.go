@@ -6,48 +6,31 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/srgklmv/astral/internal/domain/invite"
 	userDomain "github.com/srgklmv/astral/internal/domain/user"
 	"github.com/srgklmv/astral/internal/models/apperrors"
 	"github.com/srgklmv/astral/internal/models/dto"
 	"github.com/srgklmv/astral/pkg/logger"
 )
 
-func (u usecase) Register(ctx context.Context, token, login, password string) (dto.APIResponse[*dto.RegisterResponse, any], int) {
-	matched, err := userDomain.ValidateLogin(login)
-	if err != nil {
-		logger.Error("login validation error", slog.String("error", err.Error()))
-		return dto.NewAPIResponse[*dto.RegisterResponse, any](
-			&dto.Error{
-				Code: apperrors.RegexErrorCode,
-				Text: apperrors.InternalErrorText,
-			}, nil, nil,
-		), http.StatusInternalServerError
-	}
-	if !matched {
+func (u usecase) Register(ctx context.Context, inviteCode, login, password string) (dto.APIResponse[*dto.RegisterResponse, any], int) {
+	if !u.loginPolicy.Validate(login) {
 		return dto.NewAPIResponse[*dto.RegisterResponse, any](
 			&dto.Error{
 				Code: apperrors.BadRequestErrorCode,
-				Text: apperrors.RegisterBadLoginErrorText,
+				Text: apperrors.ErrorText(u.loginPolicy.Description()),
 			}, nil, nil,
 		), http.StatusBadRequest
 	}
 
-	matched, err = userDomain.ValidatePassword(password)
-	if err != nil {
-		logger.Error("password validation error", slog.String("error", err.Error()))
-		return dto.NewAPIResponse[*dto.RegisterResponse, any](
-			&dto.Error{
-				Code: apperrors.RegexErrorCode,
-				Text: apperrors.InternalErrorText,
-			}, nil, nil,
-		), http.StatusInternalServerError
-	}
+	matched, reason := u.passwordPolicy.Validate(password)
 	if !matched {
 		return dto.NewAPIResponse[*dto.RegisterResponse, any](
 			&dto.Error{
 				Code: apperrors.BadRequestErrorCode,
-				Text: apperrors.RegisterBadPasswordErrorText,
+				Text: apperrors.ErrorText(reason),
 			}, nil, nil,
 		), http.StatusBadRequest
 	}
@@ -71,21 +54,7 @@ func (u usecase) Register(ctx context.Context, token, login, password string) (d
 		), http.StatusBadRequest
 	}
 
-	var isAdmin bool
-	if token != "" {
-		isAdmin, err = u.userRepository.IsAdminTokenValid(ctx, token)
-		if err != nil {
-			logger.Error("repository call error", slog.String("error", err.Error()))
-			return dto.NewAPIResponse[*dto.RegisterResponse, any](
-				&dto.Error{
-					Code: apperrors.RepositoryCallErrorCode,
-					Text: apperrors.InternalErrorText,
-				}, nil, nil,
-			), http.StatusInternalServerError
-		}
-	}
-
-	hashedPassword, err := userDomain.HashPassword(password)
+	hashedPassword, err := u.hashParams.HashPassword(password)
 	if err != nil {
 		logger.Error("password hashing error", slog.String("error", err.Error()))
 		return dto.NewAPIResponse[*dto.RegisterResponse, any](
@@ -96,7 +65,20 @@ func (u usecase) Register(ctx context.Context, token, login, password string) (d
 		), http.StatusInternalServerError
 	}
 
-	user, err := u.userRepository.CreateUser(ctx, login, hashedPassword, isAdmin)
+	var user userDomain.User
+	if inviteCode != "" {
+		user, err = u.userRepository.CreateUserWithInviteCode(ctx, invite.HashCode(inviteCode), login, hashedPassword)
+	} else {
+		user, err = u.userRepository.CreateUser(ctx, login, hashedPassword, u.defaultRole)
+	}
+	if err != nil && errors.Is(err, invite.ErrInvalidCode) {
+		return dto.NewAPIResponse[*dto.RegisterResponse, any](
+			&dto.Error{
+				Code: apperrors.BadRequestErrorCode,
+				Text: apperrors.InviteCodeInvalidErrorText,
+			}, nil, nil,
+		), http.StatusBadRequest
+	}
 	if err != nil {
 		logger.Error("repository call error", slog.String("error", err.Error()))
 		return dto.NewAPIResponse[*dto.RegisterResponse, any](
@@ -114,7 +96,7 @@ func (u usecase) Register(ctx context.Context, token, login, password string) (d
 	), http.StatusCreated
 }
 
-func (u usecase) Auth(ctx context.Context, login, password string) (dto.APIResponse[*dto.AuthResponse, any], int) {
+func (u usecase) Auth(ctx context.Context, login, password, device, userAgent, ip string) (dto.APIResponse[*dto.AuthResponse, any], int) {
 	if login == "" || password == "" {
 		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
 			Code: apperrors.BadRequestErrorCode,
@@ -122,22 +104,39 @@ func (u usecase) Auth(ctx context.Context, login, password string) (dto.APIRespo
 		}, nil, nil), http.StatusBadRequest
 	}
 
-	userExists, err := u.userRepository.IsLoginExists(ctx, login)
+	attempt, reserved, err := u.reserveAuthAttempt(ctx, login, ip)
 	if err != nil {
-		logger.Error("repository call error", slog.String("error", err.Error()))
+		logger.Error("lockout check error", slog.String("error", err.Error()))
 		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
 			Code: apperrors.RepositoryCallErrorCode,
 			Text: apperrors.InternalErrorText,
 		}, nil, nil), http.StatusInternalServerError
 	}
-	if !userExists {
+	if !reserved {
 		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
-			Code: apperrors.BadRequestErrorCode,
-			Text: apperrors.AuthWrongCredentialsErrorText,
-		}, nil, nil), http.StatusBadRequest
+			Code: apperrors.AuthLockedErrorCode,
+			Text: apperrors.AuthLockedErrorText,
+		}, nil, nil), http.StatusTooManyRequests
+	}
+	defer u.releaseAuthAttempt(ctx, attempt)
+
+	identity, err := u.authenticate(ctx, login, password)
+	if err != nil && errors.Is(err, userDomain.ErrInvalidCredentials) {
+		return u.authFailed(ctx, attempt)
+	}
+	if err != nil {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.AuthInternalErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	// LDAP is the only external password backend.
+	if identity.Provider != userDomain.IdentityProviderLocal {
+		return u.authExternalIdentity(ctx, identity, u.ldapAutoProvision, u.ldapGroupRoles, device, userAgent, ip)
 	}
 
-	hashed, err := u.userRepository.GetUserHashedPassword(ctx, login)
+	user, err := u.userRepository.GetUserByLogin(ctx, identity.Login)
 	if err != nil {
 		logger.Error("repository call error", slog.String("error", err.Error()))
 		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
@@ -145,21 +144,47 @@ func (u usecase) Auth(ctx context.Context, login, password string) (dto.APIRespo
 			Text: apperrors.InternalErrorText,
 		}, nil, nil), http.StatusInternalServerError
 	}
-	if hashed == "" {
+
+	return u.completeAuth(ctx, user, device, userAgent, ip)
+}
+
+// completeAuth requires second factor if user enabled it, issues auth token otherwise.
+func (u usecase) completeAuth(ctx context.Context, user userDomain.User, device, userAgent, ip string) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	if user.IsDisabled {
 		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
-			Code: apperrors.BadRequestErrorCode,
-			Text: apperrors.AuthWrongCredentialsErrorText,
-		}, nil, nil), http.StatusBadRequest
+			Code: apperrors.UserDisabledErrorCode,
+			Text: apperrors.UserDisabledErrorText,
+		}, nil, nil), http.StatusForbidden
 	}
 
-	if !userDomain.IsValidPassword(password, hashed) {
+	totp, err := u.twoFactorRepository.GetTOTP(ctx, user.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
 		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
-			Code: apperrors.BadRequestErrorCode,
-			Text: apperrors.AuthWrongCredentialsErrorText,
-		}, nil, nil), http.StatusBadRequest
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if totp.IsEnabled() {
+		return u.createAuthChallenge(ctx, user.Login, device)
+	}
+
+	return u.issueAuthToken(ctx, user, device, userAgent, ip)
+}
+
+// issueAuthToken finishes successful authentication: clears login lockout
+// and creates new session.
+func (u usecase) issueAuthToken(ctx context.Context, user userDomain.User, device, userAgent, ip string) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	_, err := u.lockoutRepository.DeleteLockout(ctx, userDomain.LockoutKindLogin, user.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
 	}
 
-	err = u.userRepository.DeleteAllUserTokens(ctx, login)
+	err = u.userRepository.UpdateLastLogin(ctx, user.Login)
 	if err != nil {
 		logger.Error("repository call error", slog.String("error", err.Error()))
 		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
@@ -169,8 +194,20 @@ func (u usecase) Auth(ctx context.Context, login, password string) (dto.APIRespo
 	}
 
 	token := userDomain.GenerateAuthToken()
+	expiresAt := time.Now().Add(u.tokenTTL)
+	// In stateless mode session holds refresh token.
+	if u.accessTokenSigner != nil {
+		token = userDomain.GenerateRefreshToken()
+		expiresAt = time.Now().Add(u.refreshTokenTTL)
+	}
 
-	err = u.userRepository.SaveAuthToken(ctx, login, token)
+	sessionID, err := u.userRepository.SaveAuthToken(ctx, user.Login, userDomain.AuthToken{
+		TokenHash: userDomain.HashAuthToken(token, u.tokenPepper),
+		Device:    device,
+		UserAgent: userAgent,
+		IP:        ip,
+		ExpiresAt: expiresAt,
+	})
 	if err != nil {
 		logger.Error("repository call error", slog.String("error", err.Error()))
 		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
@@ -179,15 +216,41 @@ func (u usecase) Auth(ctx context.Context, login, password string) (dto.APIRespo
 		}, nil, nil), http.StatusInternalServerError
 	}
 
+	if u.accessTokenSigner != nil {
+		return u.accessTokenResponse(user, sessionID, token, http.StatusCreated)
+	}
+
 	return dto.NewAPIResponse[*dto.AuthResponse, any](
 		nil,
-		&dto.AuthResponse{Token: token},
+		&dto.AuthResponse{
+			Token:              token,
+			MustChangePassword: user.MustChangePassword,
+		},
 		nil,
 	), http.StatusCreated
 }
 
 func (u usecase) Logout(ctx context.Context, token string) (dto.APIResponse[*dto.LogoutResponse, any], int) {
-	err := u.userRepository.DeleteToken(ctx, token)
+	// Access token logs out its session, expired one included.
+	if u.accessTokenSigner != nil && userDomain.IsAccessToken(token) {
+		claims, err := u.accessTokenSigner.Verify(token, time.Now())
+		if err == nil || errors.Is(err, userDomain.ErrAuthTokenExpired) {
+			_, err = u.userRepository.DeleteUserAuthToken(ctx, claims.Subject, claims.SessionID)
+			if err != nil {
+				logger.Error("repository call error", slog.String("error", err.Error()))
+				return dto.NewAPIResponse[*dto.LogoutResponse, any](&dto.Error{
+					Code: apperrors.RepositoryCallErrorCode,
+					Text: apperrors.InternalErrorText,
+				}, nil, nil), http.StatusInternalServerError
+			}
+		}
+
+		return dto.NewAPIResponse[*dto.LogoutResponse, any](nil, &dto.LogoutResponse{
+			token: true,
+		}, nil), http.StatusOK
+	}
+
+	err := u.userRepository.DeleteToken(ctx, userDomain.HashAuthToken(token, u.tokenPepper))
 	if err != nil {
 		logger.Error("repository call error", slog.String("error", err.Error()))
 		return dto.NewAPIResponse[*dto.LogoutResponse, any](&dto.Error{
@@ -201,19 +264,211 @@ func (u usecase) Logout(ctx context.Context, token string) (dto.APIResponse[*dto
 	}, nil), http.StatusOK
 }
 
-func (u usecase) authorizeUserByToken(ctx context.Context, token string) (bool, userDomain.User, error) {
+// ChangePassword replaces user's local password. Wrong current passwords count as failed auth attempts.
+func (u usecase) ChangePassword(ctx context.Context, principal userDomain.Principal, password, newPassword, ip string) (dto.APIResponse[*dto.ChangePasswordResponse, any], int) {
+	hashed, err := u.userRepository.GetUserHashedPassword(ctx, principal.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ChangePasswordResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	// Users provisioned by external provider have no local password.
+	if hashed == "" {
+		return dto.NewAPIResponse[*dto.ChangePasswordResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.ExternalPasswordErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	attempt, reserved, err := u.reserveAuthAttempt(ctx, principal.Login, ip)
+	if err != nil {
+		logger.Error("lockout check error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ChangePasswordResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !reserved {
+		return dto.NewAPIResponse[*dto.ChangePasswordResponse, any](&dto.Error{
+			Code: apperrors.AuthLockedErrorCode,
+			Text: apperrors.AuthLockedErrorText,
+		}, nil, nil), http.StatusTooManyRequests
+	}
+	defer u.releaseAuthAttempt(ctx, attempt)
+
+	if !userDomain.IsValidPassword(password, hashed) {
+		err = u.failAuthAttempt(ctx, attempt)
+		if err != nil {
+			logger.Error("auth failure registration error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.ChangePasswordResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+
+		return dto.NewAPIResponse[*dto.ChangePasswordResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.WrongCurrentPasswordErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	matched, reason := u.passwordPolicy.Validate(newPassword)
+	if !matched {
+		return dto.NewAPIResponse[*dto.ChangePasswordResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.ErrorText(reason),
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	hashedPassword, err := u.hashParams.HashPassword(newPassword)
+	if err != nil {
+		logger.Error("password hashing error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ChangePasswordResponse, any](&dto.Error{
+			Code: apperrors.PasswordHashErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	err = u.userRepository.UpdateUserPassword(ctx, principal.Login, hashedPassword, false)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ChangePasswordResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	revoked, err := u.userRepository.DeleteUserTokensExcept(ctx, principal.Login, principal.Session.ID)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ChangePasswordResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.ChangePasswordResponse, any](nil, &dto.ChangePasswordResponse{
+		RevokedSessions: revoked,
+	}, nil), http.StatusOK
+}
+
+func (u usecase) GetSessions(ctx context.Context, principal userDomain.Principal) (dto.APIResponse[*dto.GetSessionsResponse, any], int) {
+	tokens, err := u.userRepository.GetUserAuthTokens(ctx, principal.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.GetSessionsResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	sessionsDTO := dto.NewGetSessionsResponse().FromDomain(tokens, principal.Session.ID)
+
+	return dto.NewAPIResponse[*dto.GetSessionsResponse, any](nil, &sessionsDTO, nil), http.StatusOK
+}
+
+func (u usecase) RevokeSession(ctx context.Context, principal userDomain.Principal, id string) (dto.APIResponse[dto.RevokeSessionResponse, any], int) {
+	if id == "" {
+		return dto.NewAPIResponse[dto.RevokeSessionResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.SessionIDNotProvidedErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	deleted, err := u.userRepository.DeleteUserAuthToken(ctx, principal.Login, id)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.RevokeSessionResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !deleted {
+		return dto.NewAPIResponse[dto.RevokeSessionResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.SessionNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	return dto.NewAPIResponse[dto.RevokeSessionResponse, any](nil, dto.RevokeSessionResponse{
+		id: true,
+	}, nil), http.StatusOK
+}
+
+func (u usecase) RevokeOtherSessions(ctx context.Context, principal userDomain.Principal) (dto.APIResponse[*dto.RevokeOtherSessionsResponse, any], int) {
+	revoked, err := u.userRepository.DeleteUserTokensExcept(ctx, principal.Login, principal.Session.ID)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.RevokeOtherSessionsResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.RevokeOtherSessionsResponse, any](nil, &dto.RevokeOtherSessionsResponse{
+		Revoked: revoked,
+	}, nil), http.StatusOK
+}
+
+// AuthorizeUserByToken resolves principal by auth token, access token or API key.
+func (u usecase) AuthorizeUserByToken(ctx context.Context, token string) (bool, userDomain.Principal, error) {
+	var principal userDomain.Principal
+
 	if token == "" {
-		return false, userDomain.User{}, nil
+		return false, principal, nil
+	}
+
+	if userDomain.IsAPIKey(token) {
+		return u.authorizeUserByAPIKey(ctx, token)
+	}
+
+	// Refresh tokens are exchanged for access tokens only.
+	if userDomain.IsRefreshToken(token) {
+		return false, principal, nil
+	}
+
+	if userDomain.IsAccessToken(token) {
+		return u.authorizeUserByAccessToken(token)
 	}
 
-	user, err := u.userRepository.GetUserByAuthToken(ctx, token)
+	tokenHash := userDomain.HashAuthToken(token, u.tokenPepper)
+
+	user, authToken, err := u.userRepository.GetUserByAuthToken(ctx, tokenHash)
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
-		return false, user, nil
+		return false, principal, nil
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return false, principal, err
+	}
+
+	if user.IsDisabled {
+		return false, principal, userDomain.ErrUserDisabled
+	}
+
+	now := time.Now()
+	if authToken.IsExpired(now) {
+		err = u.userRepository.DeleteToken(ctx, tokenHash)
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return false, principal, err
+		}
+
+		return false, principal, userDomain.ErrAuthTokenExpired
 	}
+
+	if authToken.ShouldRenew(now, u.tokenRenewalWindow) {
+		authToken.ExpiresAt = now.Add(u.tokenTTL)
+	}
+
+	err = u.userRepository.TouchAuthToken(ctx, tokenHash, authToken.ExpiresAt)
 	if err != nil {
 		logger.Error("repository call error", slog.String("error", err.Error()))
-		return false, user, err
+		return false, principal, err
 	}
+	authToken.LastUsedAt = &now
 
-	return true, user, nil
+	return true, userDomain.Principal{User: user, Session: authToken}, nil
 }
@@ -0,0 +1,227 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+)
+
+// signIn authenticates user with password and returns issued token.
+func signIn(t *testing.T, u usecase, login, password string) string {
+	t.Helper()
+
+	result, status := u.Auth(context.Background(), login, password, "device", "agent", "127.0.0.1")
+	if status != http.StatusCreated {
+		t.Fatalf("Auth status = %d, want %d", status, http.StatusCreated)
+	}
+
+	return result.Response.Token
+}
+
+// setTokenExpiry moves stored token expiration as if time passed.
+func (r *fakeRepository) setTokenExpiry(tokenHash string, expiresAt time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token := r.tokens[tokenHash]
+	token.ExpiresAt = expiresAt
+	r.tokens[tokenHash] = token
+}
+
+func (r *fakeRepository) tokenExpiry(tokenHash string) time.Time {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.tokens[tokenHash].ExpiresAt
+}
+
+func TestAuthTokenTTL(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUserWithPassword(userDomain.User{Login: "alice", Role: userDomain.RoleEditor}, "Password1!")
+	u := newTestUsecase(repo)
+	u.tokenTTL = 2 * time.Hour
+
+	token := signIn(t, u, "alice", "Password1!")
+	tokenHash := userDomain.HashAuthToken(token, u.tokenPepper)
+
+	expiresAt := repo.tokenExpiry(tokenHash)
+	if d := time.Until(expiresAt); d < time.Hour || d > 2*time.Hour {
+		t.Fatalf("token expires in %v, want about %v", d, u.tokenTTL)
+	}
+
+	ok, principal, err := u.AuthorizeUserByToken(context.Background(), token)
+	if !ok || err != nil || principal.Login != "alice" {
+		t.Fatalf("AuthorizeUserByToken = %v, %q, %v, want alice", ok, principal.Login, err)
+	}
+
+	repo.setTokenExpiry(tokenHash, time.Now().Add(-time.Second))
+	_, _, err = u.AuthorizeUserByToken(context.Background(), token)
+	if !errors.Is(err, userDomain.ErrAuthTokenExpired) {
+		t.Fatalf("AuthorizeUserByToken with expired token error = %v, want %v", err, userDomain.ErrAuthTokenExpired)
+	}
+
+	// Expired token is deleted on first use.
+	ok, _, err = u.AuthorizeUserByToken(context.Background(), token)
+	if ok || err != nil {
+		t.Fatalf("AuthorizeUserByToken with deleted token = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func TestAuthTokenSlidingRenewal(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUserWithPassword(userDomain.User{Login: "alice", Role: userDomain.RoleEditor}, "Password1!")
+	u := newTestUsecase(repo)
+	u.tokenTTL = time.Hour
+	u.tokenRenewalWindow = 10 * time.Minute
+
+	token := signIn(t, u, "alice", "Password1!")
+	tokenHash := userDomain.HashAuthToken(token, u.tokenPepper)
+
+	// Token used long before expiration keeps it.
+	expiresAt := time.Now().Add(30 * time.Minute)
+	repo.setTokenExpiry(tokenHash, expiresAt)
+	if ok, _, err := u.AuthorizeUserByToken(context.Background(), token); !ok || err != nil {
+		t.Fatalf("AuthorizeUserByToken = %v, %v, want true, nil", ok, err)
+	}
+	if got := repo.tokenExpiry(tokenHash); !got.Equal(expiresAt) {
+		t.Fatalf("token expiry = %v, want unchanged %v", got, expiresAt)
+	}
+
+	// Token used within renewal window gets full TTL again.
+	repo.setTokenExpiry(tokenHash, time.Now().Add(5*time.Minute))
+	if ok, _, err := u.AuthorizeUserByToken(context.Background(), token); !ok || err != nil {
+		t.Fatalf("AuthorizeUserByToken = %v, %v, want true, nil", ok, err)
+	}
+	if d := time.Until(repo.tokenExpiry(tokenHash)); d < 50*time.Minute {
+		t.Fatalf("renewed token expires in %v, want about %v", d, u.tokenTTL)
+	}
+
+	// Renewal is off without window.
+	u.tokenRenewalWindow = 0
+	expiresAt = time.Now().Add(time.Minute)
+	repo.setTokenExpiry(tokenHash, expiresAt)
+	if ok, _, err := u.AuthorizeUserByToken(context.Background(), token); !ok || err != nil {
+		t.Fatalf("AuthorizeUserByToken = %v, %v, want true, nil", ok, err)
+	}
+	if got := repo.tokenExpiry(tokenHash); !got.Equal(expiresAt) {
+		t.Fatalf("token expiry = %v, want unchanged %v", got, expiresAt)
+	}
+}
+
+// principalByToken returns principal of valid token.
+func principalByToken(t *testing.T, u usecase, token string) userDomain.Principal {
+	t.Helper()
+
+	ok, principal, err := u.AuthorizeUserByToken(context.Background(), token)
+	if !ok || err != nil {
+		t.Fatalf("AuthorizeUserByToken = %v, %v, want true, nil", ok, err)
+	}
+
+	return principal
+}
+
+func TestSessions(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUserWithPassword(userDomain.User{Login: "alice", Role: userDomain.RoleEditor}, "Password1!")
+	repo.addUserWithPassword(userDomain.User{Login: "bob", Role: userDomain.RoleEditor}, "Password1!")
+	u := newTestUsecase(repo)
+
+	laptop := signIn(t, u, "alice", "Password1!")
+	phone := signIn(t, u, "alice", "Password1!")
+	tablet := signIn(t, u, "alice", "Password1!")
+	bob := signIn(t, u, "bob", "Password1!")
+
+	current := principalByToken(t, u, laptop)
+	result, status := u.GetSessions(context.Background(), current)
+	if status != http.StatusOK {
+		t.Fatalf("GetSessions status = %d, want %d", status, http.StatusOK)
+	}
+	if len(result.Response.Sessions) != 3 {
+		t.Fatalf("sessions = %d, want 3", len(result.Response.Sessions))
+	}
+	for _, session := range result.Response.Sessions {
+		if session.Current != (session.ID == current.Session.ID) {
+			t.Fatalf("session %s current = %v", session.ID, session.Current)
+		}
+	}
+
+	// Other user's session can't be revoked.
+	_, status = u.RevokeSession(context.Background(), current, principalByToken(t, u, bob).Session.ID)
+	if status != http.StatusNotFound {
+		t.Fatalf("RevokeSession of other user status = %d, want %d", status, http.StatusNotFound)
+	}
+	principalByToken(t, u, bob)
+
+	_, status = u.RevokeSession(context.Background(), current, principalByToken(t, u, phone).Session.ID)
+	if status != http.StatusOK {
+		t.Fatalf("RevokeSession status = %d, want %d", status, http.StatusOK)
+	}
+	if ok, _, _ := u.AuthorizeUserByToken(context.Background(), phone); ok {
+		t.Fatal("revoked session still authorizes")
+	}
+
+	revoked, status := u.RevokeOtherSessions(context.Background(), current)
+	if status != http.StatusOK || revoked.Response.Revoked != 1 {
+		t.Fatalf("RevokeOtherSessions = %d sessions, status %d, want 1, %d", revoked.Response.Revoked, status, http.StatusOK)
+	}
+	if ok, _, _ := u.AuthorizeUserByToken(context.Background(), tablet); ok {
+		t.Fatal("other session still authorizes")
+	}
+	principalByToken(t, u, laptop)
+	principalByToken(t, u, bob)
+}
+
+func TestChangePassword(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUserWithPassword(userDomain.User{Login: "aliceuser", Role: userDomain.RoleEditor}, "Password1!")
+	u := newTestUsecase(repo)
+	u.setLockoutPolicy(userDomain.LockoutPolicy{MaxAttempts: 2, BackoffBase: time.Nanosecond, LockoutDuration: time.Hour})
+
+	current := principalByToken(t, u, signIn(t, u, "aliceuser", "Password1!"))
+	other := signIn(t, u, "aliceuser", "Password1!")
+
+	_, status := u.ChangePassword(context.Background(), current, "wrong", "Password2!", "127.0.0.1")
+	if status != http.StatusBadRequest {
+		t.Fatalf("ChangePassword with wrong password status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if failures := repo.failureCount(userDomain.LockoutKindLogin, "aliceuser"); failures != 1 {
+		t.Fatalf("login failures = %d, want 1", failures)
+	}
+
+	result, status := u.ChangePassword(context.Background(), current, "Password1!", "Password2!", "127.0.0.1")
+	if status != http.StatusOK || result.Response.RevokedSessions != 1 {
+		t.Fatalf("ChangePassword = %d revoked sessions, status %d, want 1, %d", result.Response.RevokedSessions, status, http.StatusOK)
+	}
+	if ok, _, _ := u.AuthorizeUserByToken(context.Background(), other); ok {
+		t.Fatal("other session still authorizes")
+	}
+	signIn(t, u, "aliceuser", "Password2!")
+
+	// Wrong guesses lock password change as well as login.
+	for range 2 {
+		u.ChangePassword(context.Background(), current, "wrong", "Password3!", "127.0.0.1")
+	}
+	_, status = u.ChangePassword(context.Background(), current, "Password2!", "Password3!", "127.0.0.1")
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("ChangePassword of locked login status = %d, want %d", status, http.StatusTooManyRequests)
+	}
+}
+
+func TestChangePasswordExternalAccount(t *testing.T) {
+	repo := newFakeRepository()
+	user := repo.addUser(userDomain.User{Login: "ldapuser", Role: userDomain.RoleEditor})
+	u := newTestUsecase(repo)
+
+	response, status := u.ChangePassword(context.Background(), userDomain.Principal{User: user}, "Password1!", "Password2!", "127.0.0.1")
+	if status != http.StatusBadRequest || response.Error == nil || response.Error.Text != apperrors.ExternalPasswordErrorText {
+		t.Fatalf("ChangePassword = %d, %+v, want %d, %q", status, response.Error, http.StatusBadRequest, apperrors.ExternalPasswordErrorText)
+	}
+	if failures := repo.failureCount(userDomain.LockoutKindLogin, "ldapuser"); failures != 0 {
+		t.Fatalf("login failures = %d, want 0", failures)
+	}
+}
@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/pkg/ldap"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// authenticator checks login and password against one backend and returns
+// identity of the account. ErrInvalidCredentials is returned on wrong credentials.
+type authenticator interface {
+	Authenticate(ctx context.Context, login, password string) (userDomain.Identity, error)
+}
+
+type ldapClient interface {
+	Authenticate(login, password string) (ldap.Entry, error)
+}
+
+// localAuthenticator checks password hashes stored in database.
+type localAuthenticator struct {
+	userRepository userRepository
+	hashParams     userDomain.HashParams
+}
+
+func (a localAuthenticator) Authenticate(ctx context.Context, login, password string) (userDomain.Identity, error) {
+	var identity userDomain.Identity
+
+	userExists, err := a.userRepository.IsLoginExists(ctx, login)
+	if err != nil {
+		return identity, err
+	}
+	if !userExists {
+		return identity, userDomain.ErrInvalidCredentials
+	}
+
+	hashed, err := a.userRepository.GetUserHashedPassword(ctx, login)
+	if err != nil {
+		return identity, err
+	}
+
+	// Users provisioned by external provider have no local password.
+	if hashed == "" || !userDomain.IsValidPassword(password, hashed) {
+		return identity, userDomain.ErrInvalidCredentials
+	}
+
+	a.rehashPassword(ctx, login, password, hashed)
+
+	return userDomain.Identity{
+		Provider: userDomain.IdentityProviderLocal,
+		Subject:  login,
+		Login:    login,
+	}, nil
+}
+
+// rehashPassword stores password hashed with current parameters if stored hash is outdated.
+// Failures are logged only as old hash stays valid.
+func (a localAuthenticator) rehashPassword(ctx context.Context, login, password, hashed string) {
+	if !a.hashParams.NeedsRehash(hashed) {
+		return
+	}
+
+	rehashed, err := a.hashParams.HashPassword(password)
+	if err != nil {
+		logger.Error("password rehashing error", slog.String("error", err.Error()))
+		return
+	}
+
+	err = a.userRepository.UpdateUserPasswordHash(ctx, login, hashed, rehashed)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+	}
+}
+
+// ldapAuthenticator checks password by bind to directory. Directory login attribute
+// is used as both identity subject and astral login.
+type ldapAuthenticator struct {
+	client ldapClient
+}
+
+func (a ldapAuthenticator) Authenticate(_ context.Context, login, password string) (userDomain.Identity, error) {
+	var identity userDomain.Identity
+
+	entry, err := a.client.Authenticate(login, password)
+	if err != nil && errors.Is(err, ldap.ErrInvalidCredentials) {
+		return identity, userDomain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return identity, err
+	}
+	if entry.Login == "" {
+		return identity, userDomain.ErrIdentityClaimMissing
+	}
+
+	return userDomain.Identity{
+		Provider: userDomain.IdentityProviderLDAP,
+		Subject:  entry.Login,
+		Login:    entry.Login,
+		Groups:   entry.Groups,
+	}, nil
+}
+
+// authenticate tries backends in configured order. Backend errors don't stop others,
+// so local accounts keep working while directory is down. Credentials rejected by any
+// backend count as wrong even if other backend failed, so guesses are locked out
+// during outages too.
+func (u usecase) authenticate(ctx context.Context, login, password string) (userDomain.Identity, error) {
+	var backendErr error
+	var isRejected bool
+
+	for _, authenticator := range u.authenticators {
+		identity, err := authenticator.Authenticate(ctx, login, password)
+		if err == nil {
+			return identity, nil
+		}
+		if errors.Is(err, userDomain.ErrInvalidCredentials) {
+			isRejected = true
+			continue
+		}
+
+		logger.Error("authentication backend error", slog.String("error", err.Error()))
+		backendErr = err
+	}
+
+	if backendErr != nil && !isRejected {
+		return userDomain.Identity{}, backendErr
+	}
+
+	return userDomain.Identity{}, userDomain.ErrInvalidCredentials
+}
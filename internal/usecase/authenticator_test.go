@@ -0,0 +1,175 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/pkg/ldap"
+)
+
+// fakeLDAPClient stands for directory with entries keyed by login. Err makes directory
+// unreachable.
+type fakeLDAPClient struct {
+	entries   map[string]ldap.Entry
+	passwords map[string]string
+	err       error
+}
+
+func (c *fakeLDAPClient) Authenticate(login, password string) (ldap.Entry, error) {
+	if c.err != nil {
+		return ldap.Entry{}, c.err
+	}
+
+	entry, ok := c.entries[login]
+	if !ok || password == "" || c.passwords[login] != password {
+		return ldap.Entry{}, ldap.ErrInvalidCredentials
+	}
+
+	return entry, nil
+}
+
+func newLDAPTestUsecase(repo *fakeRepository, client *fakeLDAPClient) usecase {
+	u := newTestUsecase(repo)
+	u.authenticators = []authenticator{
+		ldapAuthenticator{client: client},
+		localAuthenticator{userRepository: repo, hashParams: testHashParams},
+	}
+	u.ldapAutoProvision = true
+
+	return u
+}
+
+func TestAuthLDAPUnreachable(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUserWithPassword(userDomain.User{Login: "localuser", Role: userDomain.RoleEditor}, "Password1!")
+	u := newLDAPTestUsecase(repo, &fakeLDAPClient{err: errors.New("connection refused")})
+
+	_, status := u.Auth(context.Background(), "localuser", "Password1!", "device", "agent", "127.0.0.1")
+	if status != http.StatusCreated {
+		t.Fatalf("Auth with right local password status = %d, want %d", status, http.StatusCreated)
+	}
+
+	_, status = u.Auth(context.Background(), "localuser", "wrong", "device", "agent", "127.0.0.1")
+	if status != http.StatusBadRequest {
+		t.Fatalf("Auth with wrong local password status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if failures := repo.failureCount(userDomain.LockoutKindLogin, "localuser"); failures != 1 {
+		t.Fatalf("login failures = %d, want 1", failures)
+	}
+}
+
+func TestAuthAllBackendsUnreachable(t *testing.T) {
+	repo := newFakeRepository()
+	u := newTestUsecase(repo)
+	u.authenticators = []authenticator{ldapAuthenticator{client: &fakeLDAPClient{err: errors.New("connection refused")}}}
+
+	_, status := u.Auth(context.Background(), "ldapuser", "Password1!", "device", "agent", "127.0.0.1")
+	if status != http.StatusInternalServerError {
+		t.Fatalf("Auth status = %d, want %d", status, http.StatusInternalServerError)
+	}
+	if failures := repo.failureCount(userDomain.LockoutKindLogin, "ldapuser"); failures != 0 {
+		t.Fatalf("login failures = %d, want 0", failures)
+	}
+}
+
+func TestAuthLDAPGroupRoles(t *testing.T) {
+	repo := newFakeRepository()
+	// Other admin lets ldapuser be demoted.
+	repo.addUser(userDomain.User{Login: "admin", Role: userDomain.RoleAdmin})
+	client := &fakeLDAPClient{
+		entries:   map[string]ldap.Entry{"ldapuser": {Login: "ldapuser", Groups: []string{"writers"}}},
+		passwords: map[string]string{"ldapuser": "Password1!"},
+	}
+	u := newLDAPTestUsecase(repo, client)
+	u.ldapGroupRoles = map[string]string{
+		"writers":  userDomain.RoleEditor,
+		"auditors": userDomain.RoleAuditor,
+		"admins":   userDomain.RoleAdmin,
+	}
+
+	steps := []struct {
+		name   string
+		groups []string
+		// role is set by admin before login if not empty.
+		setRole string
+		want    string
+	}{
+		{name: "provisioned with group role", groups: []string{"writers"}, want: userDomain.RoleEditor},
+		{name: "most privileged group wins", groups: []string{"writers", "admins", "staff"}, want: userDomain.RoleAdmin},
+		{name: "demoted to other group role", groups: []string{"auditors"}, want: userDomain.RoleAuditor},
+		{name: "out of groups gets default role", groups: []string{"staff"}, want: userDomain.RoleViewer},
+		{name: "role set by admin kept", groups: nil, setRole: userDomain.RoleUploader, want: userDomain.RoleUploader},
+	}
+
+	for _, step := range steps {
+		entry := client.entries["ldapuser"]
+		entry.Groups = step.groups
+		client.entries["ldapuser"] = entry
+		if step.setRole != "" {
+			_ = repo.UpdateUserAccess(context.Background(), "ldapuser", &step.setRole, nil)
+		}
+
+		_, status := u.Auth(context.Background(), "ldapuser", "Password1!", "device", "agent", "127.0.0.1")
+		if status != http.StatusCreated {
+			t.Fatalf("%s: Auth status = %d, want %d", step.name, status, http.StatusCreated)
+		}
+
+		user, err := repo.GetUserByLogin(context.Background(), "ldapuser")
+		if err != nil {
+			t.Fatalf("%s: user not found: %v", step.name, err)
+		}
+		if user.Role != step.want {
+			t.Fatalf("%s: role = %q, want %q", step.name, user.Role, step.want)
+		}
+	}
+}
+
+// Group role sync doesn't take away the last enabled admin.
+func TestAuthLDAPGroupRolesLastAdmin(t *testing.T) {
+	repo := newFakeRepository()
+	client := &fakeLDAPClient{
+		entries: map[string]ldap.Entry{
+			"ldapuser":  {Login: "ldapuser", Groups: []string{"admins"}},
+			"ldapuser2": {Login: "ldapuser2", Groups: []string{"admins"}},
+		},
+		passwords: map[string]string{"ldapuser": "Password1!", "ldapuser2": "Password1!"},
+	}
+	u := newLDAPTestUsecase(repo, client)
+	u.ldapGroupRoles = map[string]string{
+		"writers": userDomain.RoleEditor,
+		"admins":  userDomain.RoleAdmin,
+	}
+
+	signIn(t, u, "ldapuser", "Password1!")
+
+	client.entries["ldapuser"] = ldap.Entry{Login: "ldapuser", Groups: []string{"writers"}}
+	signIn(t, u, "ldapuser", "Password1!")
+	if user, _ := repo.GetUserByLogin(context.Background(), "ldapuser"); user.Role != userDomain.RoleAdmin {
+		t.Fatalf("last admin role = %q, want %q", user.Role, userDomain.RoleAdmin)
+	}
+
+	// Role is synced once other admin shows up.
+	signIn(t, u, "ldapuser2", "Password1!")
+	signIn(t, u, "ldapuser", "Password1!")
+	if user, _ := repo.GetUserByLogin(context.Background(), "ldapuser"); user.Role != userDomain.RoleEditor {
+		t.Fatalf("role = %q, want %q", user.Role, userDomain.RoleEditor)
+	}
+}
+
+func TestNewGroupRoles(t *testing.T) {
+	roles, err := newGroupRoles([]string{"admins"}, map[string]string{"writers": userDomain.RoleEditor, "admins": userDomain.RoleViewer})
+	if err != nil {
+		t.Fatalf("newGroupRoles: %v", err)
+	}
+	if roles["admins"] != userDomain.RoleAdmin || roles["writers"] != userDomain.RoleEditor {
+		t.Fatalf("roles = %v", roles)
+	}
+
+	_, err = newGroupRoles(nil, map[string]string{"writers": "superuser"})
+	if err == nil {
+		t.Fatal("newGroupRoles accepted unknown role")
+	}
+}
@@ -9,29 +9,37 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/srgklmv/astral/internal/domain/document"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
 	"github.com/srgklmv/astral/internal/models/apperrors"
 	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/jsonpatch"
 	"github.com/srgklmv/astral/pkg/logger"
-	"github.com/srgklmv/astral/pkg/utils"
 )
 
-func (u usecase) UploadDocument(ctx context.Context, token string, meta dto.UploadDocumentRequestMetadata, json dto.UploadDocumentRequestJSON, file *bytes.Buffer) (dto.APIResponse[any, *dto.UploadFileResponse], int) {
-	isTokenValid, user, err := u.authorizeUserByToken(ctx, token)
-	if err != nil {
-		logger.Error("repository call error", slog.String("error", err.Error()))
+var errPatchedNotObject = errors.New("patched document is not an object")
+
+func (u usecase) UploadDocument(ctx context.Context, user userDomain.Principal, meta dto.UploadDocumentRequestMetadata, json dto.UploadDocumentRequestJSON, file *bytes.Buffer) (dto.APIResponse[any, *dto.UploadFileResponse], int) {
+	meta.Tags = document.NormalizeTags(meta.Tags)
+
+	if !user.HasPermission(userDomain.PermissionDocsWrite) ||
+		len(meta.GrantedTo) != 0 && !user.HasPermission(userDomain.PermissionDocsShare) {
 		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
-			Code: apperrors.RepositoryCallErrorCode,
-			Text: apperrors.InternalErrorText,
-		}, nil, nil), http.StatusInternalServerError
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
 	}
-	if !isTokenValid {
+
+	if !user.HasScope(userDomain.ScopeDocsWrite) ||
+		len(meta.GrantedTo) != 0 && !user.HasScope(userDomain.ScopeDocsShare) ||
+		!user.AllowsDocument("", meta.Tags) {
 		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
-			Code: apperrors.UnauthorizedErrorCode,
-			Text: apperrors.UnauthorizedErrorText,
-		}, nil, nil), http.StatusUnauthorized
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.APIKeyScopeErrorText,
+		}, nil, nil), http.StatusForbidden
 	}
 
-	isMetaValid, errorText := u.validateDocumentMetadata(meta)
+	isMetaValid, errorText := u.validateDocumentMetadata(meta.DocumentMetadata)
 	if !isMetaValid {
 		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
 			Code: apperrors.BadRequestErrorCode,
@@ -53,6 +61,21 @@ func (u usecase) UploadDocument(ctx context.Context, token string, meta dto.Uplo
 		}, nil, nil), http.StatusBadRequest
 	}
 
+	grants, grantErrors, err := u.validateGrants(ctx, meta.GrantedTo)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if len(grantErrors) != 0 {
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.GrantTargetsInvalidErrorText,
+		}, grantErrors, nil), http.StatusBadRequest
+	}
+
 	doc, err := u.documentRepository.UploadDocument(
 		ctx,
 		user.Login,
@@ -60,7 +83,8 @@ func (u usecase) UploadDocument(ctx context.Context, token string, meta dto.Uplo
 		meta.IsFile,
 		meta.Mimetype,
 		meta.IsPublic,
-		meta.GrantedTo,
+		grants,
+		meta.Tags,
 		json,
 		file,
 	)
@@ -78,20 +102,19 @@ func (u usecase) UploadDocument(ctx context.Context, token string, meta dto.Uplo
 	}), http.StatusCreated
 }
 
-func (u usecase) GetDocuments(ctx context.Context, request dto.GetDocumentsRequest) (dto.APIResponse[any, *dto.GetDocumentsResponse], int) {
-	isAuthorized, user, err := u.authorizeUserByToken(ctx, request.Token)
-	if err != nil {
-		logger.Error("repository call error", slog.String("error", err.Error()))
+func (u usecase) GetDocuments(ctx context.Context, user userDomain.Principal, request dto.GetDocumentsRequest) (dto.APIResponse[any, *dto.GetDocumentsResponse], int) {
+	if !user.HasPermission(userDomain.PermissionDocsRead) {
 		return dto.NewAPIResponse[any, *dto.GetDocumentsResponse](&dto.Error{
-			Code: apperrors.AuthInternalErrorCode,
-			Text: apperrors.InternalErrorText,
-		}, nil, nil), http.StatusInternalServerError
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
 	}
-	if !isAuthorized {
+
+	if !user.HasScope(userDomain.ScopeDocsRead) {
 		return dto.NewAPIResponse[any, *dto.GetDocumentsResponse](&dto.Error{
-			Code: apperrors.UnauthorizedErrorCode,
-			Text: apperrors.UnauthorizedErrorText,
-		}, nil, nil), http.StatusUnauthorized
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.APIKeyScopeErrorText,
+		}, nil, nil), http.StatusForbidden
 	}
 
 	request, isValid, errText := u.validateGetDocumentsRequest(request)
@@ -107,11 +130,12 @@ func (u usecase) GetDocuments(ctx context.Context, request dto.GetDocumentsReque
 	documents, err := u.documentRepository.GetDocumentsData(
 		ctx,
 		user.Login,
-		user.IsAdmin,
+		user.HasPermission(userDomain.PermissionDocsReadAny),
 		request.Login,
 		request.Key,
 		request.Value,
 		request.Limit,
+		documentFilter(user),
 	)
 	if err != nil {
 		logger.Error("repository call error", slog.String("error", err.Error()))
@@ -126,58 +150,24 @@ func (u usecase) GetDocuments(ctx context.Context, request dto.GetDocumentsReque
 	return dto.NewAPIResponse[any, *dto.GetDocumentsResponse](nil, nil, &docsDTO), http.StatusOK
 }
 
-func (u usecase) GetDocument(ctx context.Context, token, id string) (dto.APIResponse[any, any], []byte, string, int) {
-	isAuthorized, user, err := u.authorizeUserByToken(ctx, token)
-	if err != nil {
-		logger.Error("repository call error", slog.String("error", err.Error()))
-		return dto.NewAPIResponse[any, any](&dto.Error{
-			Code: apperrors.AuthInternalErrorCode,
-			Text: apperrors.InternalErrorText,
-		}, nil, nil), nil, "", http.StatusInternalServerError
-	}
-	if !isAuthorized {
-		return dto.NewAPIResponse[any, any](&dto.Error{
-			Code: apperrors.UnauthorizedErrorCode,
-			Text: apperrors.UnauthorizedErrorText,
-		}, nil, nil), nil, "", http.StatusUnauthorized
+// GetDocument returns current document content or content of given version if it is set.
+func (u usecase) GetDocument(ctx context.Context, user userDomain.Principal, id, version string) (dto.APIResponse[any, any], []byte, string, int) {
+	doc, response, status := u.authorizeDocument(ctx, user, id, readDocument)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, any](response, nil, nil), nil, "", status
 	}
 
-	uid, err := uuid.Parse(id)
-	if err != nil {
-		return dto.NewAPIResponse[any, any](&dto.Error{
-			Code: apperrors.BadRequestErrorCode,
-			Text: apperrors.BadIDProvidedErrorText,
-		}, nil, nil), nil, "", http.StatusBadRequest
-	}
-
-	doc, err := u.documentRepository.GetDocument(ctx, uid)
-	if err != nil && errors.Is(err, sql.ErrNoRows) {
-		return dto.NewAPIResponse[any, any](&dto.Error{
-			Code: apperrors.BadRequestErrorCode,
-			Text: apperrors.DocumentNotFoundErrorText,
-		}, nil, nil), nil, "", http.StatusNotFound
-	}
-	if err != nil {
-		logger.Error("repository call error", slog.String("error", err.Error()))
-		return dto.NewAPIResponse[any, any](&dto.Error{
-			Code: apperrors.RepositoryCallErrorCode,
-			Text: apperrors.InternalErrorText,
-		}, nil, nil), nil, "", http.StatusInternalServerError
-	}
+	if version != "" {
+		v, response, status := u.getDocumentVersion(ctx, doc.ID, version)
+		if status != http.StatusOK {
+			return dto.NewAPIResponse[any, any](response, nil, nil), nil, "", status
+		}
 
-	if !doc.IsPublic && token == "" {
-		return dto.NewAPIResponse[any, any](&dto.Error{
-			Code: apperrors.ForbiddenErrorCode,
-			Text: apperrors.ForbiddenErrorText,
-		}, nil, nil), nil, "", http.StatusForbidden
-	}
+		if !v.IsFile {
+			return dto.NewAPIResponse[any, any](nil, nil, &v.JSON), nil, "", http.StatusOK
+		}
 
-	isOwner := utils.IsSliceIncludesValue(doc.GrantedTo, user.Login)
-	if !isOwner && doc.Owner != user.Login && !user.IsAdmin {
-		return dto.NewAPIResponse[any, any](&dto.Error{
-			Code: apperrors.ForbiddenErrorCode,
-			Text: apperrors.ForbiddenErrorText,
-		}, nil, nil), nil, "", http.StatusForbidden
+		return dto.APIResponse[any, any]{}, v.File, v.Filename, http.StatusOK
 	}
 
 	if !doc.IsFile {
@@ -191,63 +181,162 @@ func (u usecase) GetDocument(ctx context.Context, token, id string) (dto.APIResp
 	return dto.APIResponse[any, any]{}, doc.File, doc.Filename, http.StatusOK
 }
 
-func (u usecase) DeleteDocument(ctx context.Context, token, id string) (dto.APIResponse[any, *dto.DeleteDocumentResponse], int) {
-	if id == "" {
-		return dto.NewAPIResponse[any, *dto.DeleteDocumentResponse](&dto.Error{
+// UpdateDocument replaces document content, name, mimetype and public flag keeping its ID,
+// so links to document stay valid.
+func (u usecase) UpdateDocument(ctx context.Context, user userDomain.Principal, id string, meta dto.DocumentMetadata, json dto.UploadDocumentRequestJSON, file *bytes.Buffer) (dto.APIResponse[any, *dto.UploadFileResponse], int) {
+	isMetaValid, errorText := u.validateDocumentMetadata(meta)
+	if !isMetaValid {
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
 			Code: apperrors.BadRequestErrorCode,
-			Text: apperrors.DocumentIDNotProvidedErrorText,
+			Text: errorText,
 		}, nil, nil), http.StatusBadRequest
 	}
 
-	isValid, user, err := u.authorizeUserByToken(ctx, token)
+	if meta.IsFile && len(file.Bytes()) == 0 {
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.FileNotProvidedErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	if !meta.IsFile && len(json) == 0 {
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.JSONNotProvidedErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	doc, response, status := u.authorizeDocument(ctx, user, id, writeDocument)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](response, nil, nil), status
+	}
+
+	// Changing document visibility is sharing, not editing.
+	if meta.IsPublic != doc.IsPublic {
+		_, response, status = u.authorizeDocument(ctx, user, id, shareDocument)
+		if status != http.StatusOK {
+			return dto.NewAPIResponse[any, *dto.UploadFileResponse](response, nil, nil), status
+		}
+	}
+
+	doc, err := u.documentRepository.UpdateDocument(
+		ctx,
+		doc.ID,
+		user.Login,
+		meta.Name,
+		meta.IsFile,
+		meta.Mimetype,
+		meta.IsPublic,
+		json,
+		file,
+		u.versionRetention,
+	)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.DocumentNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
 	if err != nil {
-		logger.Error("validateAuthToken error", slog.String("error", err.Error()))
-		return dto.NewAPIResponse[any, *dto.DeleteDocumentResponse](&dto.Error{
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
 			Code: apperrors.RepositoryCallErrorCode,
 			Text: apperrors.InternalErrorText,
 		}, nil, nil), http.StatusInternalServerError
 	}
-	if !isValid {
-		return dto.NewAPIResponse[any, *dto.DeleteDocumentResponse](&dto.Error{
-			Code: apperrors.UnauthorizedErrorCode,
-			Text: apperrors.UnauthorizedErrorText,
-		}, nil, nil), http.StatusUnauthorized
-	}
 
-	uid, err := uuid.Parse(id)
+	return dto.NewAPIResponse[any, *dto.UploadFileResponse](nil, nil, &dto.UploadFileResponse{
+		JSON:     doc.JSON,
+		Filename: doc.Filename,
+	}), http.StatusOK
+}
+
+// PatchDocument applies JSON Merge Patch or JSON Patch to JSON document content.
+func (u usecase) PatchDocument(ctx context.Context, user userDomain.Principal, id, contentType string, patch []byte) (dto.APIResponse[any, *dto.UploadFileResponse], int) {
+	apply, err := jsonpatch.Decode(contentType, patch)
+	if err != nil && errors.Is(err, jsonpatch.ErrUnsupportedType) {
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PatchMediaTypeErrorText,
+		}, nil, nil), http.StatusUnsupportedMediaType
+	}
 	if err != nil {
-		logger.Error("uuid parse error", slog.String("error", err.Error()))
-		return dto.NewAPIResponse[any, *dto.DeleteDocumentResponse](&dto.Error{
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
 			Code: apperrors.BadRequestErrorCode,
-			Text: apperrors.BadIDProvidedErrorText,
+			Text: apperrors.InvalidPatchErrorText,
 		}, nil, nil), http.StatusBadRequest
 	}
 
-	doc, err := u.documentRepository.GetDocument(ctx, uid)
+	doc, response, status := u.authorizeDocument(ctx, user, id, writeDocument)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](response, nil, nil), status
+	}
+
+	if doc.IsFile {
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.DocumentNotJSONErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	// Documents content is always an object, while patch may replace it with any value.
+	content, err := u.documentRepository.PatchDocumentJSON(ctx, doc.ID, user.Login, u.versionRetention, func(doc []byte) ([]byte, error) {
+		patched, err := apply(doc)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.HasPrefix(bytes.TrimSpace(patched), []byte("{")) {
+			return nil, errPatchedNotObject
+		}
+
+		return patched, nil
+	})
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
-		return dto.NewAPIResponse[any, *dto.DeleteDocumentResponse](&dto.Error{
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
 			Code: apperrors.BadRequestErrorCode,
 			Text: apperrors.DocumentNotFoundErrorText,
 		}, nil, nil), http.StatusNotFound
 	}
+	if err != nil && errors.Is(err, jsonpatch.ErrPatchFailed) {
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PatchFailedErrorText,
+		}, nil, nil), http.StatusConflict
+	}
+	if err != nil && errors.Is(err, errPatchedNotObject) {
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PatchedNotObjectErrorText,
+		}, nil, nil), http.StatusUnprocessableEntity
+	}
 	if err != nil {
 		logger.Error("repository call error", slog.String("error", err.Error()))
-		return dto.NewAPIResponse[any, *dto.DeleteDocumentResponse](&dto.Error{
+		return dto.NewAPIResponse[any, *dto.UploadFileResponse](&dto.Error{
 			Code: apperrors.RepositoryCallErrorCode,
 			Text: apperrors.InternalErrorText,
 		}, nil, nil), http.StatusInternalServerError
 	}
 
-	isUserGrantedToDocument := utils.IsSliceIncludesValue(doc.GrantedTo, id)
+	return dto.NewAPIResponse[any, *dto.UploadFileResponse](nil, nil, &dto.UploadFileResponse{
+		JSON:     content,
+		Filename: doc.Filename,
+	}), http.StatusOK
+}
 
-	if !isUserGrantedToDocument && doc.Owner != user.Login && !user.IsAdmin {
+func (u usecase) DeleteDocument(ctx context.Context, user userDomain.Principal, id string) (dto.APIResponse[any, *dto.DeleteDocumentResponse], int) {
+	if id == "" {
 		return dto.NewAPIResponse[any, *dto.DeleteDocumentResponse](&dto.Error{
-			Code: apperrors.ForbiddenErrorCode,
-			Text: apperrors.ForbiddenErrorText,
-		}, nil, nil), http.StatusForbidden
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.DocumentIDNotProvidedErrorText,
+		}, nil, nil), http.StatusBadRequest
 	}
 
-	err = u.documentRepository.DeleteDocument(ctx, doc.ID)
+	doc, response, status := u.authorizeDocument(ctx, user, id, deleteDocument)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.DeleteDocumentResponse](response, nil, nil), status
+	}
+
+	err := u.documentRepository.DeleteDocument(ctx, doc.ID)
 	if err != nil {
 		logger.Error("repository call error", slog.String("error", err.Error()))
 		return dto.NewAPIResponse[any, *dto.DeleteDocumentResponse](&dto.Error{
@@ -261,7 +350,47 @@ func (u usecase) DeleteDocument(ctx context.Context, token, id string) (dto.APIR
 	}, nil), http.StatusOK
 }
 
-func (u usecase) validateDocumentMetadata(metadata dto.UploadDocumentRequestMetadata) (bool, apperrors.ErrorText) {
+// getDocument loads document by ID returning error response with status other than 200 on failure.
+func (u usecase) getDocument(ctx context.Context, id string) (document.Document, *dto.Error, int) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return document.Document{}, &dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.BadIDProvidedErrorText,
+		}, http.StatusBadRequest
+	}
+
+	doc, err := u.documentRepository.GetDocument(ctx, uid)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return doc, &dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.DocumentNotFoundErrorText,
+		}, http.StatusNotFound
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return doc, &dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, http.StatusInternalServerError
+	}
+
+	return doc, nil, http.StatusOK
+}
+
+// documentFilter limits documents listing to principal API key restrictions.
+func documentFilter(user userDomain.Principal) document.Filter {
+	if user.APIKey == nil {
+		return document.Filter{}
+	}
+
+	return document.Filter{
+		IDs:  user.APIKey.DocumentIDs,
+		Tags: user.APIKey.Tags,
+	}
+}
+
+func (u usecase) validateDocumentMetadata(metadata dto.DocumentMetadata) (bool, apperrors.ErrorText) {
 	// May be moved to config.
 	allowedMimeTypes := map[string]bool{
 		"application/pdf": true,
@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/srgklmv/astral/internal/domain/document"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+)
+
+func TestUploadDocumentRepeatedGrant(t *testing.T) {
+	repo := newFakeRepository()
+	owner := repo.addUser(userDomain.User{Login: "owner", Role: userDomain.RoleEditor})
+	repo.addUser(userDomain.User{Login: "bob", Role: userDomain.RoleViewer})
+	repo.groups["team"] = nil
+	u := newTestUsecase(repo)
+
+	_, status := u.UploadDocument(context.Background(), userDomain.Principal{User: owner}, dto.UploadDocumentRequestMetadata{
+		DocumentMetadata: dto.DocumentMetadata{Name: "report.pdf", IsFile: true, Mimetype: "application/pdf"},
+		GrantedTo:        []dto.Grant{{Target: "bob"}, {Target: "@team"}, {Target: "bob"}, {Target: "@team"}},
+	}, nil, bytes.NewBufferString("%PDF"))
+	if status != http.StatusCreated {
+		t.Fatalf("UploadDocument status = %d, want %d", status, http.StatusCreated)
+	}
+}
+
+func TestUploadDocumentGrantLevels(t *testing.T) {
+	repo := newFakeRepository()
+	owner := repo.addUser(userDomain.User{Login: "owner", Role: userDomain.RoleEditor})
+	repo.addUser(userDomain.User{Login: "bob", Role: userDomain.RoleViewer})
+	repo.addUser(userDomain.User{Login: "carol", Role: userDomain.RoleViewer})
+	repo.groups["team"] = nil
+	u := newTestUsecase(repo)
+
+	var meta dto.UploadDocumentRequestMetadata
+	err := json.Unmarshal([]byte(`{
+		"name": "report.pdf", "file": true, "mime": "application/pdf",
+		"grant": ["bob", {"target": "carol", "level": "write"}, {"target": "@team", "level": "comment"}, {"target": "bob", "level": "share"}]
+	}`), &meta)
+	if err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+
+	_, status := u.UploadDocument(context.Background(), userDomain.Principal{User: owner}, meta, nil, bytes.NewBufferString("%PDF"))
+	if status != http.StatusCreated {
+		t.Fatalf("UploadDocument status = %d, want %d", status, http.StatusCreated)
+	}
+
+	var doc document.Document
+	for _, d := range repo.documents {
+		doc = d
+	}
+	want := []document.Grant{
+		{Login: "bob", Level: document.LevelShare},
+		{Login: "carol", Level: document.LevelWrite},
+		{Group: "team", Level: document.LevelComment},
+	}
+	if !slices.Equal(doc.Grants, want) {
+		t.Fatalf("grants = %+v, want %+v", doc.Grants, want)
+	}
+}
+
+func TestUploadDocumentBadGrantLevel(t *testing.T) {
+	repo := newFakeRepository()
+	owner := repo.addUser(userDomain.User{Login: "owner", Role: userDomain.RoleEditor})
+	repo.addUser(userDomain.User{Login: "bob", Role: userDomain.RoleViewer})
+	u := newTestUsecase(repo)
+
+	result, status := u.UploadDocument(context.Background(), userDomain.Principal{User: owner}, dto.UploadDocumentRequestMetadata{
+		DocumentMetadata: dto.DocumentMetadata{Name: "report.pdf", IsFile: true, Mimetype: "application/pdf"},
+		GrantedTo:        []dto.Grant{{Target: "bob", Level: "admin"}},
+	}, nil, bytes.NewBufferString("%PDF"))
+	if status != http.StatusBadRequest {
+		t.Fatalf("UploadDocument status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if grantErrors, _ := result.Response.(dto.GrantErrors); grantErrors["bob"] != apperrors.BadGrantLevelErrorText {
+		t.Fatalf("grant errors = %v, want bad level of bob", result.Response)
+	}
+	if len(repo.documents) != 0 {
+		t.Fatal("document uploaded with bad grant level")
+	}
+}
+
+func TestUpdateDocumentVisibility(t *testing.T) {
+	repo := newFakeRepository()
+	owner := repo.addUser(userDomain.User{Login: "owner", Role: userDomain.RoleEditor})
+	writer := repo.addUser(userDomain.User{Login: "writer", Role: userDomain.RoleEditor})
+	doc := repo.addDocument(document.Document{
+		Data:   document.Data{Owner: owner.Login, Filename: "report.pdf", IsFile: true, Mimetype: "application/pdf"},
+		Grants: []document.Grant{{Login: writer.Login, Level: document.LevelWrite}},
+	})
+	u := newTestUsecase(repo)
+
+	update := func(isPublic bool) int {
+		_, status := u.UpdateDocument(context.Background(), userDomain.Principal{User: writer}, doc.ID.String(), dto.DocumentMetadata{
+			Name:     "report.pdf",
+			IsFile:   true,
+			IsPublic: isPublic,
+			Mimetype: "application/pdf",
+		}, nil, bytes.NewBufferString("%PDF"))
+		return status
+	}
+
+	if status := update(false); status != http.StatusOK {
+		t.Fatalf("UpdateDocument keeping visibility status = %d, want %d", status, http.StatusOK)
+	}
+	if status := update(true); status != http.StatusForbidden {
+		t.Fatalf("UpdateDocument making public with write level status = %d, want %d", status, http.StatusForbidden)
+	}
+	if stored, _ := repo.GetDocument(context.Background(), doc.ID); stored.IsPublic {
+		t.Fatal("document made public without share level")
+	}
+
+	_ = repo.AddDocumentGrants(context.Background(), doc.ID, []document.Grant{{Login: writer.Login, Level: document.LevelShare}})
+	if status := update(true); status != http.StatusOK {
+		t.Fatalf("UpdateDocument making public with share level status = %d, want %d", status, http.StatusOK)
+	}
+}
@@ -0,0 +1,167 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/srgklmv/astral/internal/domain/document"
+	"github.com/srgklmv/astral/internal/domain/group"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+func (u usecase) GetDocumentGrants(ctx context.Context, user userDomain.Principal, id string) (dto.APIResponse[any, *dto.GetDocumentGrantsResponse], int) {
+	doc, response, status := u.authorizeDocument(ctx, user, id, readDocumentGrants)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.GetDocumentGrantsResponse](response, nil, nil), status
+	}
+
+	grantsDTO := dto.NewGetDocumentGrantsResponse().FromDomain(doc)
+
+	return dto.NewAPIResponse[any, *dto.GetDocumentGrantsResponse](nil, nil, &grantsDTO), http.StatusOK
+}
+
+// AddDocumentGrants grants document to users and groups with given levels, replacing levels
+// of existing grants. Nothing is granted if any grant is invalid, errors are returned per target then.
+func (u usecase) AddDocumentGrants(ctx context.Context, user userDomain.Principal, id string, request dto.AddDocumentGrantsRequest) (dto.APIResponse[any, *dto.GetDocumentGrantsResponse], int) {
+	doc, response, status := u.authorizeDocument(ctx, user, id, shareDocument)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.GetDocumentGrantsResponse](response, nil, nil), status
+	}
+
+	if len(request.Grants) == 0 {
+		return dto.NewAPIResponse[any, *dto.GetDocumentGrantsResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.GrantsNotProvidedErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	grants, grantErrors, err := u.validateGrants(ctx, request.Grants)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[any, *dto.GetDocumentGrantsResponse](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	if len(grantErrors) != 0 {
+		return dto.NewAPIResponse[any, *dto.GetDocumentGrantsResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.GrantTargetsInvalidErrorText,
+		}, grantErrors, nil), http.StatusBadRequest
+	}
+
+	err = u.documentRepository.AddDocumentGrants(ctx, doc.ID, grants)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[any, *dto.GetDocumentGrantsResponse](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return u.GetDocumentGrants(ctx, user, id)
+}
+
+// RevokeDocumentGrants revokes document grants. Targets without grant are ignored.
+func (u usecase) RevokeDocumentGrants(ctx context.Context, user userDomain.Principal, id string, request dto.RevokeDocumentGrantsRequest) (dto.APIResponse[any, *dto.GetDocumentGrantsResponse], int) {
+	doc, response, status := u.authorizeDocument(ctx, user, id, shareDocument)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.GetDocumentGrantsResponse](response, nil, nil), status
+	}
+
+	if len(request.Targets) == 0 {
+		return dto.NewAPIResponse[any, *dto.GetDocumentGrantsResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.GrantsNotProvidedErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	logins, groups := group.SplitGrants(request.Targets)
+
+	err := u.documentRepository.RevokeDocumentGrants(ctx, doc.ID, logins, groups)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[any, *dto.GetDocumentGrantsResponse](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return u.GetDocumentGrants(ctx, user, id)
+}
+
+// validateGrants converts grants to domain ones, read level is used if it is empty.
+// Repeated target gets the strongest of its levels. Unknown targets and levels are returned as grant errors.
+func (u usecase) validateGrants(ctx context.Context, requested []dto.Grant) ([]document.Grant, dto.GrantErrors, error) {
+	targets := make([]string, 0, len(requested))
+	for _, grant := range requested {
+		targets = append(targets, grant.Target)
+	}
+
+	_, _, grantErrors, err := u.validateGrantTargets(ctx, targets)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	grants := make([]document.Grant, 0, len(requested))
+	indexes := make(map[string]int, len(requested))
+	for _, grant := range requested {
+		if grant.Level == "" {
+			grant.Level = document.LevelRead
+		}
+		if !document.IsValidLevel(grant.Level) {
+			grantErrors[grant.Target] = apperrors.BadGrantLevelErrorText
+			continue
+		}
+
+		if i, ok := indexes[grant.Target]; ok {
+			grants[i].Level = document.MaxLevel(grants[i].Level, grant.Level)
+			continue
+		}
+		indexes[grant.Target] = len(grants)
+
+		name, isGroup := strings.CutPrefix(grant.Target, group.GrantPrefix)
+		if isGroup {
+			grants = append(grants, document.Grant{Group: name, Level: grant.Level})
+			continue
+		}
+		grants = append(grants, document.Grant{Login: grant.Target, Level: grant.Level})
+	}
+
+	return grants, grantErrors, nil
+}
+
+// validateGrantTargets splits grant targets into existing user logins and group names.
+// Unknown targets are returned as grant errors.
+func (u usecase) validateGrantTargets(ctx context.Context, targets []string) ([]string, []string, dto.GrantErrors, error) {
+	logins, groups := group.SplitGrants(targets)
+	grantErrors := make(dto.GrantErrors)
+
+	for _, login := range logins {
+		exists, err := u.userRepository.IsLoginExists(ctx, login)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !exists {
+			grantErrors[login] = apperrors.UserNotFoundErrorText
+		}
+	}
+
+	for _, name := range groups {
+		exists, err := u.groupRepository.IsGroupExists(ctx, name)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !exists {
+			grantErrors[group.GrantPrefix+name] = apperrors.GroupNotFoundErrorText
+		}
+	}
+
+	return logins, groups, grantErrors, nil
+}
@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/srgklmv/astral/internal/domain/document"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/dto"
+)
+
+func TestAddDocumentGrantsRepeatedTarget(t *testing.T) {
+	repo := newFakeRepository()
+	owner := repo.addUser(userDomain.User{Login: "owner", Role: userDomain.RoleEditor})
+	repo.addUser(userDomain.User{Login: "bob", Role: userDomain.RoleEditor})
+	repo.groups["team"] = []string{"owner"}
+	doc := repo.addDocument(document.Document{Data: document.Data{Owner: owner.Login}})
+	u := newTestUsecase(repo)
+
+	result, status := u.AddDocumentGrants(context.Background(), userDomain.Principal{User: owner}, doc.ID.String(), dto.AddDocumentGrantsRequest{
+		Grants: []dto.Grant{
+			{Target: "bob", Level: document.LevelWrite},
+			{Target: "bob", Level: document.LevelRead},
+			{Target: "@team"},
+			{Target: "@team", Level: document.LevelShare},
+		},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("AddDocumentGrants status = %d, want %d", status, http.StatusOK)
+	}
+
+	want := []dto.Grant{
+		{Target: "bob", Level: document.LevelWrite},
+		{Target: "@team", Level: document.LevelShare},
+	}
+	if !slices.Equal(result.Data.Grants, want) {
+		t.Fatalf("grants = %v, want %v", result.Data.Grants, want)
+	}
+}
@@ -0,0 +1,261 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/srgklmv/astral/internal/domain/group"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// CreateGroup creates group with principal as its admin. Sharing permission is required,
+// since groups exist to share documents.
+func (u usecase) CreateGroup(ctx context.Context, principal userDomain.Principal, request dto.CreateGroupRequest) (dto.APIResponse[*dto.Group, any], int) {
+	if !principal.HasPermission(userDomain.PermissionDocsShare) && !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	if !group.IsValidName(request.Name) {
+		return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.GroupNameInvalidErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	exists, err := u.groupRepository.IsGroupExists(ctx, request.Name)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if exists {
+		return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.GroupExistsErrorText,
+		}, nil, nil), http.StatusConflict
+	}
+
+	for _, login := range request.Members {
+		exists, err = u.userRepository.IsLoginExists(ctx, login)
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+		if !exists {
+			return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+				Code: apperrors.BadRequestErrorCode,
+				Text: apperrors.UserNotFoundErrorText,
+			}, nil, nil), http.StatusBadRequest
+		}
+	}
+
+	g, err := u.groupRepository.CreateGroup(ctx, request.Name, principal.Login, request.Members)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	groupDTO := dto.NewGroup(g)
+
+	return dto.NewAPIResponse[*dto.Group, any](nil, &groupDTO, nil), http.StatusCreated
+}
+
+// GetGroups returns groups principal is member of. Users managers get all groups.
+func (u usecase) GetGroups(ctx context.Context, principal userDomain.Principal) (dto.APIResponse[*dto.GetGroupsResponse, any], int) {
+	login := principal.Login
+	if principal.HasPermission(userDomain.PermissionUsersManage) {
+		login = ""
+	}
+
+	groups, err := u.groupRepository.GetGroups(ctx, login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.GetGroupsResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	groupsDTO := dto.NewGetGroupsResponse().FromDomain(groups)
+
+	return dto.NewAPIResponse[*dto.GetGroupsResponse, any](nil, &groupsDTO, nil), http.StatusOK
+}
+
+func (u usecase) GetGroup(ctx context.Context, principal userDomain.Principal, name string) (dto.APIResponse[*dto.Group, any], int) {
+	g, response, status := u.getGroup(ctx, name)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[*dto.Group, any](response, nil, nil), status
+	}
+
+	_, isMember := g.Member(principal.Login)
+	if !isMember && !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.GroupNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	groupDTO := dto.NewGroup(g)
+
+	return dto.NewAPIResponse[*dto.Group, any](nil, &groupDTO, nil), http.StatusOK
+}
+
+// DeleteGroup deletes group and revokes documents access granted to it.
+func (u usecase) DeleteGroup(ctx context.Context, principal userDomain.Principal, name string) (dto.APIResponse[dto.DeleteGroupResponse, any], int) {
+	g, response, status := u.getGroup(ctx, name)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[dto.DeleteGroupResponse, any](response, nil, nil), status
+	}
+
+	if !g.IsAdmin(principal.Login) && !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[dto.DeleteGroupResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	err := u.groupRepository.DeleteGroup(ctx, name)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.DeleteGroupResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[dto.DeleteGroupResponse, any](nil, dto.DeleteGroupResponse{
+		name: true,
+	}, nil), http.StatusOK
+}
+
+// SetGroupMember adds user to group or changes its admin flag. The last group admin can not be demoted.
+func (u usecase) SetGroupMember(ctx context.Context, principal userDomain.Principal, name, login string, request dto.SetGroupMemberRequest) (dto.APIResponse[*dto.Group, any], int) {
+	g, response, status := u.getGroup(ctx, name)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[*dto.Group, any](response, nil, nil), status
+	}
+
+	if !g.IsAdmin(principal.Login) && !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	exists, err := u.userRepository.IsLoginExists(ctx, login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !exists {
+		return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.UserNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	err = u.groupRepository.SetGroupMember(ctx, name, login, request.IsAdmin)
+	if err != nil && errors.Is(err, group.ErrLastAdmin) {
+		return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.LastGroupAdminErrorText,
+		}, nil, nil), http.StatusConflict
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.Group, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	g.Members = slices.DeleteFunc(g.Members, func(member group.Member) bool {
+		return member.Login == login
+	})
+	g.Members = append(g.Members, group.Member{Login: login, IsAdmin: request.IsAdmin})
+	groupDTO := dto.NewGroup(g)
+
+	return dto.NewAPIResponse[*dto.Group, any](nil, &groupDTO, nil), http.StatusOK
+}
+
+// RemoveGroupMember removes user from group. Members may leave group by themselves,
+// except the last group admin.
+func (u usecase) RemoveGroupMember(ctx context.Context, principal userDomain.Principal, name, login string) (dto.APIResponse[dto.RemoveGroupMemberResponse, any], int) {
+	g, response, status := u.getGroup(ctx, name)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[dto.RemoveGroupMemberResponse, any](response, nil, nil), status
+	}
+
+	if login != principal.Login && !g.IsAdmin(principal.Login) && !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[dto.RemoveGroupMemberResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	removed, err := u.groupRepository.RemoveGroupMember(ctx, name, login)
+	if err != nil && errors.Is(err, group.ErrLastAdmin) {
+		return dto.NewAPIResponse[dto.RemoveGroupMemberResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.LastGroupAdminErrorText,
+		}, nil, nil), http.StatusConflict
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.RemoveGroupMemberResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !removed {
+		return dto.NewAPIResponse[dto.RemoveGroupMemberResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.GroupMemberNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	return dto.NewAPIResponse[dto.RemoveGroupMemberResponse, any](nil, dto.RemoveGroupMemberResponse{
+		login: true,
+	}, nil), http.StatusOK
+}
+
+// getGroup loads group returning error response with status other than 200 on failure.
+func (u usecase) getGroup(ctx context.Context, name string) (group.Group, *dto.Error, int) {
+	g, err := u.groupRepository.GetGroup(ctx, name)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return g, &dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.GroupNotFoundErrorText,
+		}, http.StatusNotFound
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return g, &dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, http.StatusInternalServerError
+	}
+
+	return g, nil, http.StatusOK
+}
@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/dto"
+)
+
+func TestGroupLastAdmin(t *testing.T) {
+	repo := newFakeRepository()
+	first := repo.addUser(userDomain.User{Login: "first", Role: userDomain.RoleEditor})
+	repo.groups["team"] = []string{"first", "member"}
+	repo.groupAdmins["team"] = []string{"first"}
+	repo.addUser(userDomain.User{Login: "member", Role: userDomain.RoleEditor})
+	u := newTestUsecase(repo)
+
+	_, status := u.SetGroupMember(context.Background(), userDomain.Principal{User: first}, "team", "first", dto.SetGroupMemberRequest{IsAdmin: false})
+	if status != http.StatusConflict {
+		t.Fatalf("SetGroupMember demoting the last admin status = %d, want %d", status, http.StatusConflict)
+	}
+
+	_, status = u.RemoveGroupMember(context.Background(), userDomain.Principal{User: first}, "team", "first")
+	if status != http.StatusConflict {
+		t.Fatalf("RemoveGroupMember of the last admin status = %d, want %d", status, http.StatusConflict)
+	}
+
+	_, status = u.RemoveGroupMember(context.Background(), userDomain.Principal{User: first}, "team", "member")
+	if status != http.StatusOK {
+		t.Fatalf("RemoveGroupMember status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+// Both admins see another one left, the latter change must be rejected by the write itself.
+func TestGroupConcurrentAdminRemoval(t *testing.T) {
+	repo := newFakeRepository()
+	first := repo.addUser(userDomain.User{Login: "first", Role: userDomain.RoleEditor})
+	second := repo.addUser(userDomain.User{Login: "second", Role: userDomain.RoleEditor})
+	repo.groups["team"] = []string{"first", "second"}
+	repo.groupAdmins["team"] = []string{"first", "second"}
+	u := newTestUsecase(repo)
+
+	var secondStatus int
+	repo.beforeGroupMember = func() {
+		_, secondStatus = u.RemoveGroupMember(context.Background(), userDomain.Principal{User: second}, "team", "second")
+	}
+
+	_, status := u.SetGroupMember(context.Background(), userDomain.Principal{User: first}, "team", "first", dto.SetGroupMemberRequest{IsAdmin: false})
+	if secondStatus != http.StatusOK {
+		t.Fatalf("concurrent RemoveGroupMember status = %d, want %d", secondStatus, http.StatusOK)
+	}
+	if status != http.StatusConflict {
+		t.Fatalf("SetGroupMember status = %d, want %d", status, http.StatusConflict)
+	}
+	if !slices.Equal(repo.groupAdmins["team"], []string{"first"}) {
+		t.Fatalf("group admins = %v, want [first]", repo.groupAdmins["team"])
+	}
+}
@@ -0,0 +1,247 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/srgklmv/astral/internal/domain/document"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// shareLinkPasswordMaxLength keeps link passwords hashable with bcrypt.
+const shareLinkPasswordMaxLength = 72
+
+// CreateShareLink creates link to download document without account. Token is returned
+// only once and stored hashed.
+func (u usecase) CreateShareLink(ctx context.Context, user userDomain.Principal, id string, request dto.CreateShareLinkRequest) (dto.APIResponse[any, *dto.CreateShareLinkResponse], int) {
+	doc, response, status := u.authorizeDocument(ctx, user, id, shareDocument)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.CreateShareLinkResponse](response, nil, nil), status
+	}
+
+	isValid, errText := u.validateCreateShareLinkRequest(request)
+	if !isValid {
+		return dto.NewAPIResponse[any, *dto.CreateShareLinkResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: errText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	token, err := document.GenerateLinkToken()
+	if err != nil {
+		logger.Error("share link token generation error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[any, *dto.CreateShareLinkResponse](&dto.Error{
+			Code: apperrors.AuthTokenGenerationErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	link := document.Link{
+		DocumentID: doc.ID,
+		CreatedBy:  user.Login,
+		TokenHash:  userDomain.HashAuthToken(token, u.tokenPepper),
+		ExpiresAt:  time.Now().Add(orDefault(request.TTL, u.shareLinkTTL)),
+	}
+	if request.MaxDownloads != 0 {
+		link.MaxDownloads = &request.MaxDownloads
+	}
+	if request.Password != "" {
+		hashedPassword, err := u.hashParams.HashPassword(request.Password)
+		if err != nil {
+			logger.Error("password hashing error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[any, *dto.CreateShareLinkResponse](&dto.Error{
+				Code: apperrors.PasswordHashErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+		link.PasswordHash = &hashedPassword
+	}
+
+	link, err = u.shareLinkRepository.CreateShareLink(ctx, link)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[any, *dto.CreateShareLinkResponse](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[any, *dto.CreateShareLinkResponse](nil, nil, &dto.CreateShareLinkResponse{
+		ShareLink: dto.NewShareLink(link, time.Now()),
+		Token:     token,
+	}), http.StatusCreated
+}
+
+// GetShareLinks lists document share links with their use counters.
+func (u usecase) GetShareLinks(ctx context.Context, user userDomain.Principal, id string) (dto.APIResponse[any, *dto.GetShareLinksResponse], int) {
+	doc, response, status := u.authorizeDocument(ctx, user, id, shareDocument)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.GetShareLinksResponse](response, nil, nil), status
+	}
+
+	links, err := u.shareLinkRepository.GetDocumentShareLinks(ctx, doc.ID)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[any, *dto.GetShareLinksResponse](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	linksDTO := dto.NewGetShareLinksResponse().FromDomain(links)
+
+	return dto.NewAPIResponse[any, *dto.GetShareLinksResponse](nil, nil, &linksDTO), http.StatusOK
+}
+
+// RevokeShareLink revokes link. Revoked links are kept to show their use counters.
+func (u usecase) RevokeShareLink(ctx context.Context, user userDomain.Principal, id, linkID string) (dto.APIResponse[any, *dto.RevokeShareLinkResponse], int) {
+	doc, response, status := u.authorizeDocument(ctx, user, id, shareDocument)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.RevokeShareLinkResponse](response, nil, nil), status
+	}
+
+	revoked, err := u.shareLinkRepository.RevokeShareLink(ctx, doc.ID, linkID)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[any, *dto.RevokeShareLinkResponse](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !revoked {
+		return dto.NewAPIResponse[any, *dto.RevokeShareLinkResponse](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.ShareLinkNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	return dto.NewAPIResponse[any, *dto.RevokeShareLinkResponse](nil, dto.RevokeShareLinkResponse{
+		linkID: true,
+	}, nil), http.StatusOK
+}
+
+// OpenShareLink returns current content of shared document and counts link use, isFile tells
+// if file is returned instead of JSON response. Link stops working once its creator may no
+// longer share the document.
+func (u usecase) OpenShareLink(ctx context.Context, token, password string) (dto.APIResponse[any, any], []byte, string, bool, int) {
+	notFound := dto.NewAPIResponse[any, any](&dto.Error{
+		Code: apperrors.BadRequestErrorCode,
+		Text: apperrors.ShareLinkNotFoundErrorText,
+	}, nil, nil)
+	internalError := dto.NewAPIResponse[any, any](&dto.Error{
+		Code: apperrors.RepositoryCallErrorCode,
+		Text: apperrors.InternalErrorText,
+	}, nil, nil)
+
+	if token == "" {
+		return notFound, nil, "", false, http.StatusNotFound
+	}
+
+	link, err := u.shareLinkRepository.GetShareLink(ctx, userDomain.HashAuthToken(token, u.tokenPepper))
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return notFound, nil, "", false, http.StatusNotFound
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return internalError, nil, "", false, http.StatusInternalServerError
+	}
+
+	if !link.IsActive(time.Now()) {
+		return notFound, nil, "", false, http.StatusNotFound
+	}
+
+	if link.HasPassword() && !userDomain.IsValidPassword(password, *link.PasswordHash) {
+		// Missing password is a prompt rather than a guess.
+		if password != "" {
+			registered, err := u.shareLinkRepository.RegisterShareLinkPasswordFailure(ctx, link.ID, document.LinkMaxPasswordFailures)
+			if err != nil {
+				logger.Error("repository call error", slog.String("error", err.Error()))
+				return internalError, nil, "", false, http.StatusInternalServerError
+			}
+			// Concurrent guesses locked the link after it was loaded.
+			if !registered {
+				return notFound, nil, "", false, http.StatusNotFound
+			}
+		}
+
+		return dto.NewAPIResponse[any, any](&dto.Error{
+			Code: apperrors.UnauthorizedErrorCode,
+			Text: apperrors.ShareLinkPasswordErrorText,
+		}, nil, nil), nil, "", false, http.StatusUnauthorized
+	}
+
+	doc, response, status := u.getDocument(ctx, link.DocumentID.String())
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, any](response, nil, nil), nil, "", false, status
+	}
+
+	mayShare, err := u.creatorMayShare(ctx, link.CreatedBy, doc)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return internalError, nil, "", false, http.StatusInternalServerError
+	}
+	if !mayShare {
+		return notFound, nil, "", false, http.StatusNotFound
+	}
+
+	used, err := u.shareLinkRepository.UseShareLink(ctx, link.ID, document.LinkMaxPasswordFailures)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return internalError, nil, "", false, http.StatusInternalServerError
+	}
+	if !used {
+		return notFound, nil, "", false, http.StatusNotFound
+	}
+
+	if !doc.IsFile {
+		return dto.NewAPIResponse[any, any](nil, nil, &doc.JSON), nil, "", false, http.StatusOK
+	}
+
+	return dto.APIResponse[any, any]{}, doc.File, doc.Filename, true, http.StatusOK
+}
+
+// creatorMayShare checks link creator against the same policy as when link was created,
+// so revoked grants and disabled accounts close links too. API key limits are not kept.
+func (u usecase) creatorMayShare(ctx context.Context, login string, doc document.Document) (bool, error) {
+	creator, err := u.userRepository.GetUserByLogin(ctx, login)
+	if err != nil {
+		return false, err
+	}
+
+	if creator.IsDisabled || !creator.HasPermission(shareDocument.permission) {
+		return false, nil
+	}
+	if creator.HasPermission(shareDocument.anyPermission) {
+		return true, nil
+	}
+
+	level, err := u.documentLevel(ctx, creator.Login, doc)
+	if err != nil {
+		return false, err
+	}
+
+	return document.LevelAllows(level, shareDocument.level), nil
+}
+
+func (u usecase) validateCreateShareLinkRequest(request dto.CreateShareLinkRequest) (bool, apperrors.ErrorText) {
+	if request.TTL < 0 || request.TTL > int(u.shareLinkMaxTTL/time.Second) {
+		return false, apperrors.BadShareLinkTTLErrorText
+	}
+
+	if request.MaxDownloads < 0 {
+		return false, apperrors.BadShareLinkDownloadsErrorText
+	}
+
+	if len(request.Password) > shareLinkPasswordMaxLength {
+		return false, apperrors.BadShareLinkPasswordErrorText
+	}
+
+	return true, ""
+}
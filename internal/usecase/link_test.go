@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/srgklmv/astral/internal/domain/document"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+)
+
+// addShareLink adds link to doc made by its owner and returns link token.
+func addShareLink(t *testing.T, repo *fakeRepository, u usecase, doc document.Document, password string) string {
+	t.Helper()
+
+	link := document.Link{
+		ID:         "link",
+		DocumentID: doc.ID,
+		CreatedBy:  doc.Owner,
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	if password != "" {
+		hashed, err := testHashParams.HashPassword(password)
+		if err != nil {
+			t.Fatalf("HashPassword: %v", err)
+		}
+		link.PasswordHash = &hashed
+	}
+
+	token := "token"
+	repo.links[userDomain.HashAuthToken(token, u.tokenPepper)] = link
+
+	return token
+}
+
+func (r *fakeRepository) shareLinkFailures(id string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, link := range r.links {
+		if link.ID == id {
+			return link.PasswordFailures
+		}
+	}
+
+	return 0
+}
+
+func TestOpenShareLinkPasswordFailures(t *testing.T) {
+	repo := newFakeRepository()
+	owner := repo.addUser(userDomain.User{Login: "owner", Role: userDomain.RoleEditor})
+	doc := repo.addDocument(document.Document{Data: document.Data{Owner: owner.Login}, JSON: map[string]any{"a": 1.0}})
+	u := newTestUsecase(repo)
+	token := addShareLink(t, repo, u, doc, "Secret1!")
+
+	for range document.LinkMaxPasswordFailures {
+		if _, _, _, _, status := u.OpenShareLink(context.Background(), token, "wrong"); status != http.StatusUnauthorized {
+			t.Fatalf("OpenShareLink with wrong password status = %d, want %d", status, http.StatusUnauthorized)
+		}
+	}
+
+	if _, _, _, _, status := u.OpenShareLink(context.Background(), token, "Secret1!"); status != http.StatusNotFound {
+		t.Fatalf("OpenShareLink of locked link status = %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+// Guess which loaded the link before it got locked must not be counted past the limit.
+func TestOpenShareLinkConcurrentPasswordFailures(t *testing.T) {
+	repo := newFakeRepository()
+	owner := repo.addUser(userDomain.User{Login: "owner", Role: userDomain.RoleEditor})
+	doc := repo.addDocument(document.Document{Data: document.Data{Owner: owner.Login}, JSON: map[string]any{"a": 1.0}})
+	u := newTestUsecase(repo)
+	token := addShareLink(t, repo, u, doc, "Secret1!")
+
+	repo.afterGetShareLink = func() {
+		for range document.LinkMaxPasswordFailures {
+			_, _ = repo.RegisterShareLinkPasswordFailure(context.Background(), "link", document.LinkMaxPasswordFailures)
+		}
+	}
+
+	if _, _, _, _, status := u.OpenShareLink(context.Background(), token, "wrong"); status != http.StatusNotFound {
+		t.Fatalf("OpenShareLink of concurrently locked link status = %d, want %d", status, http.StatusNotFound)
+	}
+	if failures := repo.shareLinkFailures("link"); failures != document.LinkMaxPasswordFailures {
+		t.Fatalf("password failures = %d, want %d", failures, document.LinkMaxPasswordFailures)
+	}
+}
+
+func TestOpenShareLinkEmptyFile(t *testing.T) {
+	repo := newFakeRepository()
+	owner := repo.addUser(userDomain.User{Login: "owner", Role: userDomain.RoleEditor})
+	doc := repo.addDocument(document.Document{Data: document.Data{Owner: owner.Login, Filename: "empty.txt", IsFile: true}})
+	u := newTestUsecase(repo)
+	token := addShareLink(t, repo, u, doc, "")
+
+	_, file, filename, isFile, status := u.OpenShareLink(context.Background(), token, "")
+	if status != http.StatusOK {
+		t.Fatalf("OpenShareLink status = %d, want %d", status, http.StatusOK)
+	}
+	if !isFile || len(file) != 0 || filename != "empty.txt" {
+		t.Fatalf("OpenShareLink = file %q, name %q, isFile %v, want empty file empty.txt", file, filename, isFile)
+	}
+}
@@ -0,0 +1,163 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+func (u usecase) GetLockouts(ctx context.Context, principal userDomain.Principal) (dto.APIResponse[*dto.GetLockoutsResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.GetLockoutsResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	lockouts, err := u.lockoutRepository.GetLockouts(ctx)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.GetLockoutsResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	lockoutsDTO := dto.NewGetLockoutsResponse().FromDomain(lockouts)
+
+	return dto.NewAPIResponse[*dto.GetLockoutsResponse, any](nil, &lockoutsDTO, nil), http.StatusOK
+}
+
+func (u usecase) ClearLockout(ctx context.Context, principal userDomain.Principal, kind, key string) (dto.APIResponse[dto.ClearLockoutResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[dto.ClearLockoutResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	if !userDomain.IsValidLockoutKind(kind) {
+		return dto.NewAPIResponse[dto.ClearLockoutResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.BadLockoutKindErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	deleted, err := u.lockoutRepository.DeleteLockout(ctx, kind, key)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.ClearLockoutResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !deleted {
+		return dto.NewAPIResponse[dto.ClearLockoutResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.LockoutNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	return dto.NewAPIResponse[dto.ClearLockoutResponse, any](nil, dto.ClearLockoutResponse{
+		key: true,
+	}, nil), http.StatusOK
+}
+
+// authAttempt is an auth attempt counted against login and client IP lockouts.
+type authAttempt struct {
+	keys []lockoutKey
+	// done is set once attempt is registered as failure or released.
+	done bool
+}
+
+type lockoutKey struct {
+	kind     string
+	key      string
+	failures int
+}
+
+// reserveAuthAttempt counts attempt as failed for login and client IP before credentials
+// are checked, so concurrent requests can't make more guesses than lockout policies allow.
+// It returns false if login or client IP is locked. Reserved attempt must be either
+// registered with failAuthAttempt or taken back with releaseAuthAttempt.
+func (u usecase) reserveAuthAttempt(ctx context.Context, login, ip string) (*authAttempt, bool, error) {
+	attempt := &authAttempt{}
+
+	for _, k := range []lockoutKey{{kind: userDomain.LockoutKindLogin, key: login}, {kind: userDomain.LockoutKindIP, key: ip}} {
+		if k.key == "" {
+			continue
+		}
+
+		failures, reserved, err := u.lockoutRepository.ReserveAuthAttempt(ctx, k.kind, k.key, u.lockoutWindow, u.lockoutPolicies[k.kind])
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			u.releaseAuthAttempt(ctx, attempt)
+			return nil, false, err
+		}
+		if !reserved {
+			u.releaseAuthAttempt(ctx, attempt)
+			return nil, false, nil
+		}
+
+		k.failures = failures
+		attempt.keys = append(attempt.keys, k)
+	}
+
+	return attempt, true, nil
+}
+
+// failAuthAttempt keeps reserved attempt counted and locks login and client IP
+// with backoff according to lockout policies.
+func (u usecase) failAuthAttempt(ctx context.Context, attempt *authAttempt) error {
+	attempt.done = true
+	now := time.Now()
+
+	for _, k := range attempt.keys {
+		err := u.lockoutRepository.LockAuthKey(ctx, k.kind, k.key, now.Add(u.lockoutPolicies[k.kind].LockDuration(k.failures)))
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseAuthAttempt takes back reserved attempt which didn't turn out a wrong guess.
+// It's meant to be deferred, attempt registered as failure is kept.
+func (u usecase) releaseAuthAttempt(ctx context.Context, attempt *authAttempt) {
+	if attempt.done {
+		return
+	}
+	attempt.done = true
+
+	for _, k := range attempt.keys {
+		err := u.lockoutRepository.ReleaseAuthAttempt(ctx, k.kind, k.key, u.lockoutPolicies[k.kind].MaxAttempts)
+		if err != nil {
+			logger.Error("auth attempt release error", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// authFailed registers failed auth attempt and returns wrong credentials response.
+func (u usecase) authFailed(ctx context.Context, attempt *authAttempt) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	err := u.failAuthAttempt(ctx, attempt)
+	if err != nil {
+		logger.Error("auth failure registration error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+		Code: apperrors.BadRequestErrorCode,
+		Text: apperrors.AuthWrongCredentialsErrorText,
+	}, nil, nil), http.StatusBadRequest
+}
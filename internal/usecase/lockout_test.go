@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+)
+
+// lockedFor returns how long key stays locked.
+func (r *fakeRepository) lockedFor(kind, key string) time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return time.Until(r.locks[kind+":"+key])
+}
+
+// expireLocks lifts locks as if their time passed, counters are kept.
+func (r *fakeRepository) expireLocks() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	clear(r.locks)
+}
+
+// setLockoutPolicy applies policy to both logins and client IPs.
+func (u usecase) setLockoutPolicy(policy userDomain.LockoutPolicy) {
+	u.lockoutPolicies[userDomain.LockoutKindLogin] = policy
+	u.lockoutPolicies[userDomain.LockoutKindIP] = policy
+}
+
+func TestAuthLockoutThreshold(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUserWithPassword(userDomain.User{Login: "aliceuser", Role: userDomain.RoleEditor}, "Password1!")
+	u := newTestUsecase(repo)
+	u.setLockoutPolicy(userDomain.LockoutPolicy{MaxAttempts: 3, BackoffBase: time.Nanosecond, LockoutDuration: time.Hour})
+
+	for range 3 {
+		_, status := u.Auth(context.Background(), "aliceuser", "wrong", "device", "agent", "127.0.0.1")
+		if status != http.StatusBadRequest {
+			t.Fatalf("Auth with wrong password status = %d, want %d", status, http.StatusBadRequest)
+		}
+	}
+
+	// Right password isn't checked while login is locked.
+	_, status := u.Auth(context.Background(), "aliceuser", "Password1!", "device", "agent", "127.0.0.1")
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("Auth of locked login status = %d, want %d", status, http.StatusTooManyRequests)
+	}
+	if d := repo.lockedFor(userDomain.LockoutKindLogin, "aliceuser"); d < 59*time.Minute {
+		t.Fatalf("login locked for %v, want about %v", d, time.Hour)
+	}
+	if failures := repo.failureCount(userDomain.LockoutKindLogin, "aliceuser"); failures != 3 {
+		t.Fatalf("login failures = %d, want 3", failures)
+	}
+
+	repo.expireLocks()
+	signIn(t, u, "aliceuser", "Password1!")
+	if failures := repo.failureCount(userDomain.LockoutKindLogin, "aliceuser"); failures != 0 {
+		t.Fatalf("login failures after success = %d, want 0", failures)
+	}
+	if failures := repo.failureCount(userDomain.LockoutKindIP, "127.0.0.1"); failures != 3 {
+		t.Fatalf("ip failures after success = %d, want 3", failures)
+	}
+}
+
+func TestAuthLockoutBackoff(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUserWithPassword(userDomain.User{Login: "aliceuser", Role: userDomain.RoleEditor}, "Password1!")
+	u := newTestUsecase(repo)
+	u.setLockoutPolicy(userDomain.LockoutPolicy{MaxAttempts: 5, BackoffBase: time.Minute, LockoutDuration: time.Hour})
+
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, time.Hour} {
+		_, status := u.Auth(context.Background(), "aliceuser", "wrong", "device", "agent", "127.0.0.1")
+		if status != http.StatusBadRequest {
+			t.Fatalf("failure %d: Auth status = %d, want %d", i+1, status, http.StatusBadRequest)
+		}
+
+		d := repo.lockedFor(userDomain.LockoutKindLogin, "aliceuser")
+		if d > want || d < want-time.Second {
+			t.Fatalf("failure %d: login locked for %v, want %v", i+1, d, want)
+		}
+
+		_, status = u.Auth(context.Background(), "aliceuser", "Password1!", "device", "agent", "127.0.0.1")
+		if status != http.StatusTooManyRequests {
+			t.Fatalf("failure %d: Auth during backoff status = %d, want %d", i+1, status, http.StatusTooManyRequests)
+		}
+
+		repo.expireLocks()
+	}
+}
+
+// Concurrent guesses are counted before passwords are checked, so no more than
+// MaxAttempts of them get to the check.
+func TestAuthLockoutConcurrent(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUserWithPassword(userDomain.User{Login: "aliceuser", Role: userDomain.RoleEditor}, "Password1!")
+	u := newTestUsecase(repo)
+	u.setLockoutPolicy(userDomain.LockoutPolicy{MaxAttempts: 3, BackoffBase: time.Nanosecond, LockoutDuration: time.Hour})
+
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		checked int
+	)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, status := u.Auth(context.Background(), "aliceuser", "wrong", "device", "agent", "127.0.0.1")
+			if status != http.StatusTooManyRequests {
+				mutex.Lock()
+				checked++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if checked != 3 {
+		t.Fatalf("checked passwords = %d, want 3", checked)
+	}
+}
+
+func TestDisableTOTPLockout(t *testing.T) {
+	repo := newFakeRepository()
+	user := repo.addUserWithPassword(userDomain.User{Login: "aliceuser", Role: userDomain.RoleEditor}, "Password1!")
+	u := newTestUsecase(repo)
+	u.setLockoutPolicy(userDomain.LockoutPolicy{MaxAttempts: 2, BackoffBase: time.Nanosecond, LockoutDuration: time.Hour})
+
+	for range 2 {
+		_, status := u.DisableTOTP(context.Background(), userDomain.Principal{User: user}, "wrong", "127.0.0.1")
+		if status != http.StatusBadRequest {
+			t.Fatalf("DisableTOTP with wrong password status = %d, want %d", status, http.StatusBadRequest)
+		}
+	}
+
+	_, status := u.DisableTOTP(context.Background(), userDomain.Principal{User: user}, "Password1!", "127.0.0.1")
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("DisableTOTP of locked login status = %d, want %d", status, http.StatusTooManyRequests)
+	}
+}
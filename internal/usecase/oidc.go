@@ -0,0 +1,209 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+	"github.com/srgklmv/astral/pkg/oidc"
+)
+
+type oidcClient interface {
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (map[string]any, error)
+}
+
+// OIDCLogin starts OpenID Connect login and returns provider URL to redirect user to.
+func (u usecase) OIDCLogin(ctx context.Context, device string) (dto.APIResponse[*dto.OIDCLoginResponse, any], int) {
+	if u.oidcClient == nil {
+		return dto.NewAPIResponse[*dto.OIDCLoginResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.OIDCDisabledErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	state := userDomain.GenerateAuthToken()
+	nonce := userDomain.GenerateAuthToken()
+	codeVerifier := oidc.GenerateVerifier()
+
+	err := u.identityRepository.SaveOIDCState(ctx, userDomain.OIDCState{
+		StateHash:    userDomain.HashAuthToken(state, u.tokenPepper),
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		Device:       device,
+		ExpiresAt:    time.Now().Add(u.oidcStateTTL),
+	})
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.OIDCLoginResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	url, err := u.oidcClient.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		logger.Error("identity provider discovery error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.OIDCLoginResponse, any](&dto.Error{
+			Code: apperrors.IdentityProviderErrorCode,
+			Text: apperrors.OIDCLoginFailedErrorText,
+		}, nil, nil), http.StatusBadGateway
+	}
+
+	return dto.NewAPIResponse[*dto.OIDCLoginResponse, any](nil, &dto.OIDCLoginResponse{
+		URL:   url,
+		State: state,
+	}, nil), http.StatusOK
+}
+
+// OIDCCallback finishes OpenID Connect login: exchanges authorization code, maps identity
+// to user, provisioning it if allowed, and issues auth token.
+func (u usecase) OIDCCallback(ctx context.Context, state, code, userAgent, ip string) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	if u.oidcClient == nil {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.OIDCDisabledErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	if state == "" || code == "" {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.OIDCStateInvalidErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	oidcState, err := u.identityRepository.ConsumeOIDCState(ctx, userDomain.HashAuthToken(state, u.tokenPepper))
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.OIDCStateInvalidErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if oidcState.IsExpired(time.Now()) {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.OIDCStateInvalidErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	claims, err := u.oidcClient.Exchange(ctx, code, oidcState.CodeVerifier, oidcState.Nonce)
+	if err != nil {
+		logger.Error("identity provider code exchange error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.IdentityProviderErrorCode,
+			Text: apperrors.OIDCLoginFailedErrorText,
+		}, nil, nil), http.StatusUnauthorized
+	}
+
+	identity, err := userDomain.IdentityFromClaims(u.oidcProvider, claims, u.oidcLoginClaim, u.oidcGroupsClaim)
+	if err != nil {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.IdentityProviderErrorCode,
+			Text: apperrors.IdentityInvalidErrorText,
+		}, nil, nil), http.StatusUnauthorized
+	}
+
+	return u.authExternalIdentity(ctx, identity, u.oidcAutoProvision, u.oidcGroupRoles, oidcState.Device, userAgent, ip)
+}
+
+// authExternalIdentity completes auth of user linked to external identity, creating user
+// on first login if provisioning is allowed. Role is synced from identity groups
+// if groupRoles is set. Local accounts with the same login are never taken over.
+func (u usecase) authExternalIdentity(ctx context.Context, identity userDomain.Identity, autoProvision bool, groupRoles map[string]string, device, userAgent, ip string) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	if !u.loginPolicy.Validate(identity.Login) {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.IdentityProviderErrorCode,
+			Text: apperrors.IdentityInvalidErrorText,
+		}, nil, nil), http.StatusUnauthorized
+	}
+
+	user, err := u.identityRepository.GetUserByIdentity(ctx, identity.Provider, identity.Subject)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		isLoginTaken, err := u.userRepository.IsLoginExists(ctx, identity.Login)
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+		if isLoginTaken {
+			return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+				Code: apperrors.IdentityProviderErrorCode,
+				Text: apperrors.IdentityLoginTakenErrorText,
+			}, nil, nil), http.StatusConflict
+		}
+		if !autoProvision {
+			return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+				Code: apperrors.IdentityProviderErrorCode,
+				Text: apperrors.IdentityNotProvisionedErrorText,
+			}, nil, nil), http.StatusForbidden
+		}
+
+		user, err = u.identityRepository.CreateUserWithIdentity(ctx, identity, identity.SyncRole("", u.defaultRole, groupRoles))
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+
+		logger.Info("user provisioned", slog.String("login", user.Login), slog.String("provider", identity.Provider))
+	}
+
+	role := identity.SyncRole(user.Role, u.defaultRole, groupRoles)
+	if len(groupRoles) > 0 && user.Role != role {
+		err = u.userRepository.UpdateUserAccess(ctx, user.Login, &role, nil)
+		// The last enabled admin keeps the role so users can still be managed.
+		if err != nil && errors.Is(err, userDomain.ErrLastAdmin) {
+			logger.Info("role sync skipped for the last admin", slog.String("login", user.Login), slog.String("role", role))
+			return u.completeAuth(ctx, user, device, userAgent, ip)
+		}
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+		user.Role = role
+
+		// Access tokens carry role, so they are revoked to be refreshed with actual one.
+		if u.accessTokenSigner != nil {
+			err = u.revocationRepository.RevokeUserSessions(ctx, user.Login)
+			if err != nil {
+				logger.Error("repository call error", slog.String("error", err.Error()))
+				return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+					Code: apperrors.RepositoryCallErrorCode,
+					Text: apperrors.InternalErrorText,
+				}, nil, nil), http.StatusInternalServerError
+			}
+		}
+	}
+
+	return u.completeAuth(ctx, user, device, userAgent, ip)
+}
@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+)
+
+// fakeOIDCClient accepts code "code" for the nonce and verifier of the last started login.
+type fakeOIDCClient struct {
+	claims       map[string]any
+	nonce        string
+	codeVerifier string
+}
+
+func (c *fakeOIDCClient) AuthCodeURL(_ context.Context, state, nonce, codeVerifier string) (string, error) {
+	c.nonce = nonce
+	c.codeVerifier = codeVerifier
+
+	return "https://idp.example/authorize?state=" + state, nil
+}
+
+func (c *fakeOIDCClient) Exchange(_ context.Context, code, codeVerifier, nonce string) (map[string]any, error) {
+	if code != "code" || codeVerifier != c.codeVerifier || nonce != c.nonce {
+		return nil, errors.New("invalid grant")
+	}
+
+	return c.claims, nil
+}
+
+func newOIDCTestUsecase(repo *fakeRepository, login string) usecase {
+	u := newTestUsecase(repo)
+	u.oidcClient = &fakeOIDCClient{claims: map[string]any{"sub": "subject", "preferred_username": login}}
+	u.oidcProvider = "idp"
+	u.oidcLoginClaim = "preferred_username"
+	u.oidcGroupsClaim = "groups"
+	u.oidcAutoProvision = true
+	u.oidcStateTTL = time.Minute
+
+	return u
+}
+
+// startOIDCLogin returns state of started login.
+func startOIDCLogin(t *testing.T, u usecase) string {
+	t.Helper()
+
+	response, status := u.OIDCLogin(context.Background(), "device")
+	if status != http.StatusOK {
+		t.Fatalf("OIDCLogin status = %d, want %d", status, http.StatusOK)
+	}
+
+	return response.Response.State
+}
+
+func TestOIDCCallback(t *testing.T) {
+	repo := newFakeRepository()
+	u := newOIDCTestUsecase(repo, "oidcuser1")
+	state := startOIDCLogin(t, u)
+
+	response, status := u.OIDCCallback(context.Background(), state, "code", "agent", "127.0.0.1")
+	if status != http.StatusCreated {
+		t.Fatalf("OIDCCallback status = %d, want %d: %+v", status, http.StatusCreated, response.Error)
+	}
+	if response.Response.Token == "" {
+		t.Fatal("OIDCCallback returned no token")
+	}
+
+	user, err := repo.GetUserByLogin(context.Background(), "oidcuser1")
+	if err != nil {
+		t.Fatalf("provisioned user not found: %v", err)
+	}
+	if user.Role != userDomain.RoleViewer {
+		t.Fatalf("provisioned user role = %q, want %q", user.Role, userDomain.RoleViewer)
+	}
+}
+
+func TestOIDCCallbackState(t *testing.T) {
+	repo := newFakeRepository()
+	u := newOIDCTestUsecase(repo, "oidcuser1")
+	state := startOIDCLogin(t, u)
+
+	_, status := u.OIDCCallback(context.Background(), state, "code", "agent", "127.0.0.1")
+	if status != http.StatusCreated {
+		t.Fatalf("OIDCCallback status = %d, want %d", status, http.StatusCreated)
+	}
+
+	tests := []struct {
+		name  string
+		state string
+	}{
+		{name: "replayed", state: state},
+		{name: "unknown", state: userDomain.GenerateAuthToken()},
+		{name: "empty", state: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, status := u.OIDCCallback(context.Background(), tt.state, "code", "agent", "127.0.0.1")
+			if status != http.StatusBadRequest {
+				t.Fatalf("OIDCCallback status = %d, want %d", status, http.StatusBadRequest)
+			}
+			if response.Error == nil || response.Error.Text != apperrors.OIDCStateInvalidErrorText {
+				t.Fatalf("OIDCCallback error = %+v, want %q", response.Error, apperrors.OIDCStateInvalidErrorText)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackExpiredState(t *testing.T) {
+	repo := newFakeRepository()
+	u := newOIDCTestUsecase(repo, "oidcuser1")
+	u.oidcStateTTL = -time.Second
+	state := startOIDCLogin(t, u)
+
+	_, status := u.OIDCCallback(context.Background(), state, "code", "agent", "127.0.0.1")
+	if status != http.StatusBadRequest {
+		t.Fatalf("OIDCCallback status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestOIDCCallbackExchangeFailure(t *testing.T) {
+	repo := newFakeRepository()
+	u := newOIDCTestUsecase(repo, "oidcuser1")
+	state := startOIDCLogin(t, u)
+
+	_, status := u.OIDCCallback(context.Background(), state, "other-code", "agent", "127.0.0.1")
+	if status != http.StatusUnauthorized {
+		t.Fatalf("OIDCCallback status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestOIDCCallbackLocalLoginTaken(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addUser(userDomain.User{Login: "localuser", Role: userDomain.RoleAdmin})
+	u := newOIDCTestUsecase(repo, "localuser")
+	state := startOIDCLogin(t, u)
+
+	response, status := u.OIDCCallback(context.Background(), state, "code", "agent", "127.0.0.1")
+	if status != http.StatusConflict {
+		t.Fatalf("OIDCCallback status = %d, want %d", status, http.StatusConflict)
+	}
+	if response.Error == nil || response.Error.Text != apperrors.IdentityLoginTakenErrorText {
+		t.Fatalf("OIDCCallback error = %+v, want %q", response.Error, apperrors.IdentityLoginTakenErrorText)
+	}
+	if len(repo.tokens) != 0 {
+		t.Fatal("session created for local account")
+	}
+}
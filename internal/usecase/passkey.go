@@ -0,0 +1,361 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+	"github.com/srgklmv/astral/pkg/webauthn"
+)
+
+type webAuthnClient interface {
+	BeginRegistration(user webauthn.User) (any, []byte, error)
+	FinishRegistration(user webauthn.User, session, response []byte) (webauthn.Credential, error)
+	BeginLogin(user *webauthn.User) (any, []byte, error)
+	FinishLogin(session, response []byte, findUser func(userHandle []byte) (webauthn.User, error)) (webauthn.User, webauthn.Credential, error)
+}
+
+// BeginPasskeyRegistration returns credential creation options for principal.
+func (u usecase) BeginPasskeyRegistration(ctx context.Context, principal userDomain.Principal) (dto.APIResponse[*dto.BeginPasskeyResponse, any], int) {
+	if u.webAuthnClient == nil {
+		return dto.NewAPIResponse[*dto.BeginPasskeyResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeysDisabledErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	user, err := u.getPasskeyUser(ctx, principal.Login, true)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.BeginPasskeyResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	options, data, err := u.webAuthnClient.BeginRegistration(user)
+	if err != nil {
+		logger.Error("passkey registration error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.BeginPasskeyResponse, any](&dto.Error{
+			Code: apperrors.AuthInternalErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return u.savePasskeySession(ctx, userDomain.PasskeySessionRegistration, principal.Login, "", options, data)
+}
+
+// FinishPasskeyRegistration verifies authenticator response and stores new passkey.
+func (u usecase) FinishPasskeyRegistration(ctx context.Context, principal userDomain.Principal, request dto.FinishPasskeyRegistrationRequest) (dto.APIResponse[*dto.Passkey, any], int) {
+	if u.webAuthnClient == nil {
+		return dto.NewAPIResponse[*dto.Passkey, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeysDisabledErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	if request.Name == "" {
+		return dto.NewAPIResponse[*dto.Passkey, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeyNameRequiredErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	session, err := u.consumePasskeySession(ctx, request.Session, userDomain.PasskeySessionRegistration)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return dto.NewAPIResponse[*dto.Passkey, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeySessionInvalidErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.Passkey, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if session.Login != principal.Login {
+		return dto.NewAPIResponse[*dto.Passkey, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeySessionInvalidErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	user, err := u.getPasskeyUser(ctx, principal.Login, false)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.Passkey, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	credential, err := u.webAuthnClient.FinishRegistration(user, session.Data, request.Credential)
+	if err != nil {
+		logger.Info("passkey registration failed", slog.String("login", principal.Login), slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.Passkey, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeyInvalidErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	passkey, err := u.passkeyRepository.CreatePasskey(ctx, principal.Login, userDomain.Passkey{
+		CredentialID:    credential.ID,
+		Name:            request.Name,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      credential.Transports,
+		AAGUID:          credential.AAGUID,
+		SignCount:       credential.SignCount,
+		BackupEligible:  credential.BackupEligible,
+		BackupState:     credential.BackupState,
+	})
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.Passkey, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	passkeyDTO := dto.NewPasskey(passkey)
+
+	return dto.NewAPIResponse[*dto.Passkey, any](nil, &passkeyDTO, nil), http.StatusCreated
+}
+
+func (u usecase) GetPasskeys(ctx context.Context, principal userDomain.Principal) (dto.APIResponse[*dto.GetPasskeysResponse, any], int) {
+	passkeys, err := u.passkeyRepository.GetUserPasskeys(ctx, principal.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.GetPasskeysResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	passkeysDTO := dto.NewGetPasskeysResponse().FromDomain(passkeys)
+
+	return dto.NewAPIResponse[*dto.GetPasskeysResponse, any](nil, &passkeysDTO, nil), http.StatusOK
+}
+
+func (u usecase) RemovePasskey(ctx context.Context, principal userDomain.Principal, id string) (dto.APIResponse[dto.RemovePasskeyResponse, any], int) {
+	deleted, err := u.passkeyRepository.DeleteUserPasskey(ctx, principal.Login, id)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.RemovePasskeyResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !deleted {
+		return dto.NewAPIResponse[dto.RemovePasskeyResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeyNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	return dto.NewAPIResponse[dto.RemovePasskeyResponse, any](nil, dto.RemovePasskeyResponse{
+		id: true,
+	}, nil), http.StatusOK
+}
+
+// BeginPasskeyLogin returns discoverable login options, so authenticator chooses account
+// by itself. Options are the same for everyone and tell nothing about accounts.
+func (u usecase) BeginPasskeyLogin(ctx context.Context, device string) (dto.APIResponse[*dto.BeginPasskeyResponse, any], int) {
+	if u.webAuthnClient == nil {
+		return dto.NewAPIResponse[*dto.BeginPasskeyResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeysDisabledErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	options, data, err := u.webAuthnClient.BeginLogin(nil)
+	if err != nil {
+		logger.Error("passkey login error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.BeginPasskeyResponse, any](&dto.Error{
+			Code: apperrors.AuthInternalErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return u.savePasskeySession(ctx, userDomain.PasskeySessionLogin, "", device, options, data)
+}
+
+// FinishPasskeyLogin verifies assertion and issues auth token. Passkeys require user
+// verification, so they are not followed by TOTP challenge.
+func (u usecase) FinishPasskeyLogin(ctx context.Context, request dto.FinishPasskeyLoginRequest, userAgent, ip string) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	if u.webAuthnClient == nil {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeysDisabledErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	session, err := u.consumePasskeySession(ctx, request.Session, userDomain.PasskeySessionLogin)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeySessionInvalidErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	var user userDomain.User
+	_, credential, err := u.webAuthnClient.FinishLogin(session.Data, request.Credential, func(userHandle []byte) (webauthn.User, error) {
+		found, err := u.passkeyRepository.GetUserByPasskeyHandle(ctx, userHandle)
+		if err != nil {
+			return webauthn.User{}, err
+		}
+		user = found
+
+		return u.newPasskeyUser(ctx, found, userHandle)
+	})
+	if err != nil {
+		logger.Info("passkey login failed", slog.String("ip", ip), slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeyInvalidErrorText,
+		}, nil, nil), http.StatusUnauthorized
+	}
+
+	// Counter is checked again atomically, so the same assertion can't be replayed concurrently.
+	updated, err := u.passkeyRepository.UpdatePasskeyUsage(ctx, credential.ID, credential.SignCount, credential.BackupState)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !updated {
+		logger.Info("passkey sign counter check failed", slog.String("login", user.Login))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.PasskeyInvalidErrorText,
+		}, nil, nil), http.StatusUnauthorized
+	}
+
+	if user.IsDisabled {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.UserDisabledErrorCode,
+			Text: apperrors.UserDisabledErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	return u.issueAuthToken(ctx, user, session.Device, userAgent, ip)
+}
+
+// savePasskeySession stores ceremony data and returns options with session token to client.
+func (u usecase) savePasskeySession(ctx context.Context, kind, login, device string, options any, data []byte) (dto.APIResponse[*dto.BeginPasskeyResponse, any], int) {
+	session := userDomain.GenerateAuthToken()
+
+	err := u.passkeyRepository.SavePasskeySession(ctx, userDomain.PasskeySession{
+		SessionHash: userDomain.HashAuthToken(session, u.tokenPepper),
+		Kind:        kind,
+		Login:       login,
+		Data:        data,
+		Device:      device,
+		ExpiresAt:   time.Now().Add(u.passkeyTimeout),
+	})
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.BeginPasskeyResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.BeginPasskeyResponse, any](nil, &dto.BeginPasskeyResponse{
+		Session: session,
+		Options: options,
+	}, nil), http.StatusOK
+}
+
+// consumePasskeySession returns pending ceremony of given kind. sql.ErrNoRows is returned
+// if session is unknown, expired or of another kind.
+func (u usecase) consumePasskeySession(ctx context.Context, session, kind string) (userDomain.PasskeySession, error) {
+	if session == "" {
+		return userDomain.PasskeySession{}, sql.ErrNoRows
+	}
+
+	passkeySession, err := u.passkeyRepository.ConsumePasskeySession(ctx, userDomain.HashAuthToken(session, u.tokenPepper))
+	if err != nil {
+		return passkeySession, err
+	}
+	if passkeySession.Kind != kind || passkeySession.IsExpired(time.Now()) {
+		return passkeySession, sql.ErrNoRows
+	}
+
+	return passkeySession, nil
+}
+
+// getPasskeyUser returns WebAuthn user by login. User gets random handle with first passkey,
+// createHandle is set to make it on registration.
+func (u usecase) getPasskeyUser(ctx context.Context, login string, createHandle bool) (webauthn.User, error) {
+	user, err := u.userRepository.GetUserByLogin(ctx, login)
+	if err != nil {
+		return webauthn.User{}, err
+	}
+
+	handle, err := u.passkeyRepository.GetPasskeyHandle(ctx, login)
+	if err != nil {
+		return webauthn.User{}, err
+	}
+
+	if handle == nil && createHandle {
+		handle, err = userDomain.GeneratePasskeyHandle()
+		if err != nil {
+			return webauthn.User{}, err
+		}
+
+		handle, err = u.passkeyRepository.SetPasskeyHandle(ctx, login, handle)
+		if err != nil {
+			return webauthn.User{}, err
+		}
+	}
+
+	return u.newPasskeyUser(ctx, user, handle)
+}
+
+// newPasskeyUser returns WebAuthn user with its passkeys. Handle is random, so neither
+// login nor user ID is stored by authenticator as user identifier.
+func (u usecase) newPasskeyUser(ctx context.Context, user userDomain.User, handle []byte) (webauthn.User, error) {
+	passkeys, err := u.passkeyRepository.GetUserPasskeys(ctx, user.Login)
+	if err != nil {
+		return webauthn.User{}, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		credentials = append(credentials, webauthn.Credential{
+			ID:              passkey.CredentialID,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transports:      passkey.Transports,
+			AAGUID:          passkey.AAGUID,
+			SignCount:       passkey.SignCount,
+			BackupEligible:  passkey.BackupEligible,
+			BackupState:     passkey.BackupState,
+		})
+	}
+
+	return webauthn.User{
+		ID:          handle,
+		Name:        user.Login,
+		Credentials: credentials,
+	}, nil
+}
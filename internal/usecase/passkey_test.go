@@ -0,0 +1,232 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/webauthn"
+	"github.com/srgklmv/astral/pkg/webauthn/webauthntest"
+)
+
+const passkeyTestOrigin = "https://astral.example.com"
+
+func newPasskeyTestUsecase(t *testing.T, repo *fakeRepository) usecase {
+	t.Helper()
+
+	client, err := webauthn.New(webauthn.Config{
+		RPID:          "astral.example.com",
+		RPDisplayName: "astral",
+		RPOrigins:     []string{passkeyTestOrigin},
+		Timeout:       time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("webauthn.New: %v", err)
+	}
+
+	u := newTestUsecase(repo)
+	u.webAuthnClient = client
+	u.passkeyTimeout = time.Minute
+
+	return u
+}
+
+// registerPasskey registers authenticator credential for user.
+func registerPasskey(t *testing.T, u usecase, authenticator *webauthntest.Authenticator, user userDomain.User) {
+	t.Helper()
+
+	principal := userDomain.Principal{User: user}
+
+	begin, status := u.BeginPasskeyRegistration(context.Background(), principal)
+	if status != http.StatusOK {
+		t.Fatalf("BeginPasskeyRegistration status = %d, want %d", status, http.StatusOK)
+	}
+
+	credential, err := authenticator.Register(begin.Response.Options)
+	if err != nil {
+		t.Fatalf("authenticator Register: %v", err)
+	}
+
+	_, status = u.FinishPasskeyRegistration(context.Background(), principal, dto.FinishPasskeyRegistrationRequest{
+		Session:    begin.Response.Session,
+		Name:       "laptop",
+		Credential: credential,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("FinishPasskeyRegistration status = %d, want %d", status, http.StatusCreated)
+	}
+}
+
+// beginPasskeyLogin starts discoverable login and returns its session with authenticator response.
+func beginPasskeyLogin(t *testing.T, u usecase, authenticator *webauthntest.Authenticator) dto.FinishPasskeyLoginRequest {
+	t.Helper()
+
+	begin, status := u.BeginPasskeyLogin(context.Background(), "device")
+	if status != http.StatusOK {
+		t.Fatalf("BeginPasskeyLogin status = %d, want %d", status, http.StatusOK)
+	}
+
+	credential, err := authenticator.Login(begin.Response.Options)
+	if err != nil {
+		t.Fatalf("authenticator Login: %v", err)
+	}
+
+	return dto.FinishPasskeyLoginRequest{Session: begin.Response.Session, Credential: credential}
+}
+
+func finishPasskeyLogin(u usecase, request dto.FinishPasskeyLoginRequest) int {
+	_, status := u.FinishPasskeyLogin(context.Background(), request, "agent", "127.0.0.1")
+	return status
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	repo := newFakeRepository()
+	user := repo.addUser(userDomain.User{Login: "passkeyuser", Role: userDomain.RoleEditor})
+	u := newPasskeyTestUsecase(t, repo)
+	authenticator := webauthntest.New(passkeyTestOrigin)
+
+	registerPasskey(t, u, authenticator, user)
+
+	handle := authenticator.Credentials()[0].UserHandle
+	if len(handle) != 32 || bytes.Equal(handle, binary.BigEndian.AppendUint64(nil, uint64(user.ID))) {
+		t.Fatalf("user handle = %x, want random 32 bytes", handle)
+	}
+
+	if status := finishPasskeyLogin(u, beginPasskeyLogin(t, u, authenticator)); status != http.StatusCreated {
+		t.Fatalf("FinishPasskeyLogin status = %d, want %d", status, http.StatusCreated)
+	}
+
+	// Second passkey keeps user handle.
+	registerPasskey(t, u, webauthntest.New(passkeyTestOrigin), user)
+	if !bytes.Equal(repo.handles[user.Login], handle) {
+		t.Fatal("user handle changed on second registration")
+	}
+}
+
+// Login options list no credentials, so they don't tell which accounts have passkeys.
+func TestBeginPasskeyLoginDiscoverable(t *testing.T) {
+	repo := newFakeRepository()
+	user := repo.addUser(userDomain.User{Login: "passkeyuser", Role: userDomain.RoleEditor})
+	u := newPasskeyTestUsecase(t, repo)
+	registerPasskey(t, u, webauthntest.New(passkeyTestOrigin), user)
+
+	begin, status := u.BeginPasskeyLogin(context.Background(), "device")
+	if status != http.StatusOK {
+		t.Fatalf("BeginPasskeyLogin status = %d, want %d", status, http.StatusOK)
+	}
+
+	options, err := json.Marshal(begin.Response.Options)
+	if err != nil {
+		t.Fatalf("options marshaling: %v", err)
+	}
+	if bytes.Contains(options, []byte("allowCredentials")) {
+		t.Fatalf("options = %s, want no allowed credentials", options)
+	}
+}
+
+func TestPasskeySessionSingleUse(t *testing.T) {
+	repo := newFakeRepository()
+	user := repo.addUser(userDomain.User{Login: "passkeyuser", Role: userDomain.RoleEditor})
+	u := newPasskeyTestUsecase(t, repo)
+	authenticator := webauthntest.New(passkeyTestOrigin)
+	registerPasskey(t, u, authenticator, user)
+
+	request := beginPasskeyLogin(t, u, authenticator)
+	if status := finishPasskeyLogin(u, request); status != http.StatusCreated {
+		t.Fatalf("FinishPasskeyLogin status = %d, want %d", status, http.StatusCreated)
+	}
+	if status := finishPasskeyLogin(u, request); status != http.StatusBadRequest {
+		t.Fatalf("replayed FinishPasskeyLogin status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	// Registration session can't finish login.
+	begin, _ := u.BeginPasskeyRegistration(context.Background(), userDomain.Principal{User: user})
+	request.Session = begin.Response.Session
+	if status := finishPasskeyLogin(u, request); status != http.StatusBadRequest {
+		t.Fatalf("FinishPasskeyLogin with registration session status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestPasskeyLoginWithoutUserVerification(t *testing.T) {
+	repo := newFakeRepository()
+	user := repo.addUser(userDomain.User{Login: "passkeyuser", Role: userDomain.RoleEditor})
+	u := newPasskeyTestUsecase(t, repo)
+	authenticator := webauthntest.New(passkeyTestOrigin)
+	registerPasskey(t, u, authenticator, user)
+
+	authenticator.SkipUserVerification = true
+	if status := finishPasskeyLogin(u, beginPasskeyLogin(t, u, authenticator)); status != http.StatusUnauthorized {
+		t.Fatalf("FinishPasskeyLogin status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if len(repo.tokens) != 0 {
+		t.Fatal("session created without user verification")
+	}
+}
+
+func TestPasskeyLoginDisabledUser(t *testing.T) {
+	repo := newFakeRepository()
+	user := repo.addUser(userDomain.User{Login: "passkeyuser", Role: userDomain.RoleEditor})
+	u := newPasskeyTestUsecase(t, repo)
+	authenticator := webauthntest.New(passkeyTestOrigin)
+	registerPasskey(t, u, authenticator, user)
+
+	isDisabled := true
+	_ = repo.UpdateUserAccess(context.Background(), user.Login, nil, &isDisabled)
+	if status := finishPasskeyLogin(u, beginPasskeyLogin(t, u, authenticator)); status != http.StatusForbidden {
+		t.Fatalf("FinishPasskeyLogin status = %d, want %d", status, http.StatusForbidden)
+	}
+	if len(repo.tokens) != 0 {
+		t.Fatal("session created for disabled user")
+	}
+}
+
+func TestPasskeyLoginClonedCredential(t *testing.T) {
+	repo := newFakeRepository()
+	user := repo.addUser(userDomain.User{Login: "passkeyuser", Role: userDomain.RoleEditor})
+	u := newPasskeyTestUsecase(t, repo)
+	authenticator := webauthntest.New(passkeyTestOrigin)
+	registerPasskey(t, u, authenticator, user)
+
+	if status := finishPasskeyLogin(u, beginPasskeyLogin(t, u, authenticator)); status != http.StatusCreated {
+		t.Fatalf("FinishPasskeyLogin status = %d, want %d", status, http.StatusCreated)
+	}
+
+	authenticator.Credentials()[0].SignCount = 0
+	if status := finishPasskeyLogin(u, beginPasskeyLogin(t, u, authenticator)); status != http.StatusUnauthorized {
+		t.Fatalf("FinishPasskeyLogin with regressed counter status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+// Both logins pass signature check against the same stored counter, the one storing
+// lower counter after the other must be rejected by UpdatePasskeyUsage.
+func TestPasskeyLoginConcurrentCounter(t *testing.T) {
+	repo := newFakeRepository()
+	user := repo.addUser(userDomain.User{Login: "passkeyuser", Role: userDomain.RoleEditor})
+	u := newPasskeyTestUsecase(t, repo)
+	authenticator := webauthntest.New(passkeyTestOrigin)
+	registerPasskey(t, u, authenticator, user)
+
+	first := beginPasskeyLogin(t, u, authenticator)
+	second := beginPasskeyLogin(t, u, authenticator)
+
+	var secondStatus int
+	repo.beforePasskeyUsage = func() {
+		secondStatus = finishPasskeyLogin(u, second)
+	}
+
+	if status := finishPasskeyLogin(u, first); status != http.StatusUnauthorized {
+		t.Fatalf("FinishPasskeyLogin with outdated counter status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if secondStatus != http.StatusCreated {
+		t.Fatalf("concurrent FinishPasskeyLogin status = %d, want %d", secondStatus, http.StatusCreated)
+	}
+	if len(repo.tokens) != 1 {
+		t.Fatalf("sessions = %d, want 1", len(repo.tokens))
+	}
+}
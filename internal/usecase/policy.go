@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/srgklmv/astral/internal/domain/document"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+	"github.com/srgklmv/astral/pkg/utils"
+)
+
+// documentAction is what principal needs to act on existing document: role permission,
+// API key scope and grant level. Holders of anyPermission act regardless of grants.
+type documentAction struct {
+	level         string
+	permission    string
+	scope         string
+	anyPermission string
+}
+
+var (
+	readDocument = documentAction{
+		level:         document.LevelRead,
+		permission:    userDomain.PermissionDocsRead,
+		scope:         userDomain.ScopeDocsRead,
+		anyPermission: userDomain.PermissionDocsReadAny,
+	}
+	writeDocument = documentAction{
+		level:      document.LevelWrite,
+		permission: userDomain.PermissionDocsWrite,
+		scope:      userDomain.ScopeDocsWrite,
+	}
+	deleteDocument = documentAction{
+		level:         document.LevelDelete,
+		permission:    userDomain.PermissionDocsDelete,
+		scope:         userDomain.ScopeDocsDelete,
+		anyPermission: userDomain.PermissionDocsDeleteAny,
+	}
+	shareDocument = documentAction{
+		level:         document.LevelShare,
+		permission:    userDomain.PermissionDocsShare,
+		scope:         userDomain.ScopeDocsShare,
+		anyPermission: userDomain.PermissionDocsShareAny,
+	}
+	// Grants are shown to those who may change them, reading them needs no share scope.
+	readDocumentGrants = documentAction{
+		level:         document.LevelShare,
+		permission:    userDomain.PermissionDocsRead,
+		scope:         userDomain.ScopeDocsRead,
+		anyPermission: userDomain.PermissionDocsShareAny,
+	}
+)
+
+// authorizeDocument loads document principal may perform action on, returning error response
+// with status other than 200 on failure. It is the only place document access is decided.
+func (u usecase) authorizeDocument(ctx context.Context, user userDomain.Principal, id string, action documentAction) (document.Document, *dto.Error, int) {
+	if !user.HasPermission(action.permission) {
+		return document.Document{}, &dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, http.StatusForbidden
+	}
+
+	if !user.HasScope(action.scope) {
+		return document.Document{}, &dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.APIKeyScopeErrorText,
+		}, http.StatusForbidden
+	}
+
+	doc, response, status := u.getDocument(ctx, id)
+	if status != http.StatusOK {
+		return doc, response, status
+	}
+
+	if action.anyPermission == "" || !user.HasPermission(action.anyPermission) {
+		level, err := u.documentLevel(ctx, user.Login, doc)
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return doc, &dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, http.StatusInternalServerError
+		}
+
+		if !document.LevelAllows(level, action.level) {
+			return doc, &dto.Error{
+				Code: apperrors.ForbiddenErrorCode,
+				Text: apperrors.ForbiddenErrorText,
+			}, http.StatusForbidden
+		}
+	}
+
+	if !user.AllowsDocument(doc.ID.String(), doc.Tags) {
+		return doc, &dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.APIKeyScopeErrorText,
+		}, http.StatusForbidden
+	}
+
+	return doc, nil, http.StatusOK
+}
+
+// documentLevel returns the strongest level user has on document directly or as a member
+// of granted group. Owner has every level, empty level means no access.
+func (u usecase) documentLevel(ctx context.Context, login string, doc document.Document) (string, error) {
+	if doc.Owner == login {
+		return document.LevelShare, nil
+	}
+
+	var level string
+	var groups []string
+	for _, grant := range doc.Grants {
+		if grant.Group != "" {
+			groups = append(groups, grant.Group)
+			continue
+		}
+		if grant.Login == login {
+			level = document.MaxLevel(level, grant.Level)
+		}
+	}
+
+	if len(groups) == 0 {
+		return level, nil
+	}
+
+	memberGroups, err := u.groupRepository.GetMemberGroups(ctx, login, groups)
+	if err != nil {
+		return "", err
+	}
+
+	for _, grant := range doc.Grants {
+		if grant.Group != "" && utils.IsSliceIncludesValue(memberGroups, grant.Group) {
+			level = document.MaxLevel(level, grant.Level)
+		}
+	}
+
+	return level, nil
+}
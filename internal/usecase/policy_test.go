@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/srgklmv/astral/internal/domain/document"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+)
+
+func TestAuthorizeDocumentAPIKey(t *testing.T) {
+	repo := newFakeRepository()
+	owner := repo.addUser(userDomain.User{Login: "owner", Role: userDomain.RoleEditor})
+	doc := repo.addDocument(document.Document{
+		Data: document.Data{Owner: owner.Login, Filename: "report.pdf", IsFile: true, Mimetype: "application/pdf", Tags: []string{"finance", "q3"}},
+	})
+	other := repo.addDocument(document.Document{
+		Data: document.Data{Owner: "someone", Filename: "notes.txt", IsFile: true, Mimetype: "text/plain"},
+	})
+	u := newTestUsecase(repo)
+
+	withKey := func(key userDomain.APIKey) userDomain.Principal {
+		return userDomain.Principal{User: owner, APIKey: &key}
+	}
+
+	tests := []struct {
+		name      string
+		principal userDomain.Principal
+		id        string
+		action    documentAction
+		status    int
+		text      apperrors.ErrorText
+	}{
+		{name: "session is not limited by scopes", principal: userDomain.Principal{User: owner}, id: doc.ID.String(), action: deleteDocument, status: http.StatusOK},
+		{name: "key with scope", principal: withKey(userDomain.APIKey{Scopes: []string{userDomain.ScopeDocsRead}}), id: doc.ID.String(), action: readDocument, status: http.StatusOK},
+		{name: "key without scope", principal: withKey(userDomain.APIKey{Scopes: []string{userDomain.ScopeDocsRead}}), id: doc.ID.String(), action: writeDocument, status: http.StatusForbidden, text: apperrors.APIKeyScopeErrorText},
+		{name: "grants are read with read scope", principal: withKey(userDomain.APIKey{Scopes: []string{userDomain.ScopeDocsRead}}), id: doc.ID.String(), action: readDocumentGrants, status: http.StatusOK},
+		{name: "key limited to document", principal: withKey(userDomain.APIKey{Scopes: []string{userDomain.ScopeDocsRead}, DocumentIDs: []string{doc.ID.String()}}), id: doc.ID.String(), action: readDocument, status: http.StatusOK},
+		{name: "key limited to other document", principal: withKey(userDomain.APIKey{Scopes: []string{userDomain.ScopeDocsRead}, DocumentIDs: []string{other.ID.String()}}), id: doc.ID.String(), action: readDocument, status: http.StatusForbidden, text: apperrors.APIKeyScopeErrorText},
+		{name: "key limited to tag", principal: withKey(userDomain.APIKey{Scopes: []string{userDomain.ScopeDocsRead}, Tags: []string{"q3"}}), id: doc.ID.String(), action: readDocument, status: http.StatusOK},
+		{name: "key limited to other tag", principal: withKey(userDomain.APIKey{Scopes: []string{userDomain.ScopeDocsRead}, Tags: []string{"hr"}}), id: doc.ID.String(), action: readDocument, status: http.StatusForbidden, text: apperrors.APIKeyScopeErrorText},
+		{name: "key limited to document or tag", principal: withKey(userDomain.APIKey{Scopes: []string{userDomain.ScopeDocsRead}, DocumentIDs: []string{other.ID.String()}, Tags: []string{"finance"}}), id: doc.ID.String(), action: readDocument, status: http.StatusOK},
+		// Key doesn't grant more than its owner has.
+		{name: "key to document not granted", principal: withKey(userDomain.APIKey{Scopes: []string{userDomain.ScopeDocsRead}, DocumentIDs: []string{other.ID.String()}}), id: other.ID.String(), action: readDocument, status: http.StatusForbidden, text: apperrors.ForbiddenErrorText},
+	}
+
+	for _, tt := range tests {
+		_, response, status := u.authorizeDocument(context.Background(), tt.principal, tt.id, tt.action)
+		if status != tt.status {
+			t.Fatalf("%s: status = %d, want %d", tt.name, status, tt.status)
+		}
+		if tt.text != "" && (response == nil || response.Text != tt.text) {
+			t.Fatalf("%s: error = %+v, want %q", tt.name, response, tt.text)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// EnrollTOTP generates new TOTP secret to be confirmed with ConfirmTOTP.
+// Repeated calls replace secret until enrollment is confirmed.
+func (u usecase) EnrollTOTP(ctx context.Context, principal userDomain.Principal) (dto.APIResponse[*dto.EnrollTOTPResponse, any], int) {
+	totp, err := u.twoFactorRepository.GetTOTP(ctx, principal.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.EnrollTOTPResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if totp.IsEnabled() {
+		return dto.NewAPIResponse[*dto.EnrollTOTPResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.TwoFactorAlreadyEnabledErrorText,
+		}, nil, nil), http.StatusConflict
+	}
+
+	secret, err := userDomain.GenerateTOTPSecret()
+	if err != nil {
+		logger.Error("totp secret generation error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.EnrollTOTPResponse, any](&dto.Error{
+			Code: apperrors.AuthTokenGenerationErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	err = u.twoFactorRepository.SaveTOTPSecret(ctx, principal.Login, secret)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.EnrollTOTPResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.EnrollTOTPResponse, any](nil, &dto.EnrollTOTPResponse{
+		Secret: secret,
+		URI:    userDomain.TOTPURI(u.twoFactorIssuer, principal.Login, secret),
+	}, nil), http.StatusCreated
+}
+
+// ConfirmTOTP enables TOTP if code matches enrolled secret and returns new recovery codes.
+// Wrong codes count as failed auth attempts.
+func (u usecase) ConfirmTOTP(ctx context.Context, principal userDomain.Principal, code, ip string) (dto.APIResponse[*dto.ConfirmTOTPResponse, any], int) {
+	attempt, reserved, err := u.reserveAuthAttempt(ctx, principal.Login, ip)
+	if err != nil {
+		logger.Error("lockout check error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ConfirmTOTPResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !reserved {
+		return dto.NewAPIResponse[*dto.ConfirmTOTPResponse, any](&dto.Error{
+			Code: apperrors.AuthLockedErrorCode,
+			Text: apperrors.AuthLockedErrorText,
+		}, nil, nil), http.StatusTooManyRequests
+	}
+	defer u.releaseAuthAttempt(ctx, attempt)
+
+	totp, err := u.twoFactorRepository.GetTOTP(ctx, principal.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ConfirmTOTPResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !totp.IsEnrolled() {
+		return dto.NewAPIResponse[*dto.ConfirmTOTPResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.TwoFactorNotEnrolledErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+	if totp.IsEnabled() {
+		return dto.NewAPIResponse[*dto.ConfirmTOTPResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.TwoFactorAlreadyEnabledErrorText,
+		}, nil, nil), http.StatusConflict
+	}
+
+	step, ok := totp.Validate(code, time.Now())
+	if !ok {
+		err = u.failAuthAttempt(ctx, attempt)
+		if err != nil {
+			logger.Error("auth failure registration error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.ConfirmTOTPResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+
+		return dto.NewAPIResponse[*dto.ConfirmTOTPResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.TwoFactorCodeInvalidErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	codes, err := userDomain.GenerateRecoveryCodes(u.recoveryCodes)
+	if err != nil {
+		logger.Error("recovery codes generation error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ConfirmTOTPResponse, any](&dto.Error{
+			Code: apperrors.AuthTokenGenerationErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	codeHashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		codeHashes = append(codeHashes, userDomain.HashRecoveryCode(c))
+	}
+
+	err = u.twoFactorRepository.ConfirmTOTP(ctx, principal.Login, step, codeHashes)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.ConfirmTOTPResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.ConfirmTOTPResponse, any](nil, &dto.ConfirmTOTPResponse{
+		RecoveryCodes: codes,
+	}, nil), http.StatusOK
+}
+
+// DisableTOTP turns off user's own second factor. Current password is required,
+// wrong passwords count as failed auth attempts.
+func (u usecase) DisableTOTP(ctx context.Context, principal userDomain.Principal, password, ip string) (dto.APIResponse[dto.DisableTOTPResponse, any], int) {
+	attempt, reserved, err := u.reserveAuthAttempt(ctx, principal.Login, ip)
+	if err != nil {
+		logger.Error("lockout check error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.DisableTOTPResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !reserved {
+		return dto.NewAPIResponse[dto.DisableTOTPResponse, any](&dto.Error{
+			Code: apperrors.AuthLockedErrorCode,
+			Text: apperrors.AuthLockedErrorText,
+		}, nil, nil), http.StatusTooManyRequests
+	}
+	defer u.releaseAuthAttempt(ctx, attempt)
+
+	hashed, err := u.userRepository.GetUserHashedPassword(ctx, principal.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.DisableTOTPResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	if !userDomain.IsValidPassword(password, hashed) {
+		err = u.failAuthAttempt(ctx, attempt)
+		if err != nil {
+			logger.Error("auth failure registration error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[dto.DisableTOTPResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+
+		return dto.NewAPIResponse[dto.DisableTOTPResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.WrongCurrentPasswordErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	deleted, err := u.twoFactorRepository.DeleteTOTP(ctx, principal.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.DisableTOTPResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !deleted {
+		return dto.NewAPIResponse[dto.DisableTOTPResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.TwoFactorNotEnabledErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	return dto.NewAPIResponse[dto.DisableTOTPResponse, any](nil, dto.DisableTOTPResponse{
+		principal.Login: true,
+	}, nil), http.StatusOK
+}
+
+// VerifyTwoFactor exchanges auth challenge and TOTP or recovery code for auth token.
+// Wrong codes count as failed auth attempts and drop challenge after configured number of tries.
+func (u usecase) VerifyTwoFactor(ctx context.Context, challenge, code, userAgent, ip string) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	if challenge == "" {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.TwoFactorChallengeInvalidErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	challengeHash := userDomain.HashAuthToken(challenge, u.tokenPepper)
+
+	authChallenge, err := u.twoFactorRepository.GetAuthChallenge(ctx, challengeHash)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.TwoFactorChallengeInvalidErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !time.Now().Before(authChallenge.ExpiresAt) {
+		return u.dropAuthChallenge(ctx, challengeHash, apperrors.TwoFactorChallengeInvalidErrorText)
+	}
+
+	attempt, reserved, err := u.reserveAuthAttempt(ctx, authChallenge.Login, ip)
+	if err != nil {
+		logger.Error("lockout check error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !reserved {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.AuthLockedErrorCode,
+			Text: apperrors.AuthLockedErrorText,
+		}, nil, nil), http.StatusTooManyRequests
+	}
+	defer u.releaseAuthAttempt(ctx, attempt)
+
+	totp, err := u.twoFactorRepository.GetTOTP(ctx, authChallenge.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	// Second factor was reset after password check.
+	if !totp.IsEnabled() {
+		return u.dropAuthChallenge(ctx, challengeHash, apperrors.TwoFactorChallengeInvalidErrorText)
+	}
+
+	var ok bool
+	if userDomain.IsTOTPCode(code) {
+		step, matched := totp.Validate(code, time.Now())
+		if matched {
+			ok, err = u.twoFactorRepository.UseTOTPStep(ctx, authChallenge.Login, step)
+		}
+	} else if code != "" {
+		ok, err = u.twoFactorRepository.UseRecoveryCode(ctx, authChallenge.Login, userDomain.HashRecoveryCode(code))
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !ok {
+		return u.twoFactorFailed(ctx, attempt, authChallenge)
+	}
+
+	err = u.twoFactorRepository.DeleteAuthChallenge(ctx, challengeHash)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	user, err := u.userRepository.GetUserByLogin(ctx, authChallenge.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if user.IsDisabled {
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.UserDisabledErrorCode,
+			Text: apperrors.UserDisabledErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	return u.issueAuthToken(ctx, user, authChallenge.Device, userAgent, ip)
+}
+
+// ResetUserTwoFactor disables user's second factor, e.g. when device and recovery codes are lost.
+func (u usecase) ResetUserTwoFactor(ctx context.Context, principal userDomain.Principal, login string) (dto.APIResponse[dto.ResetUserTwoFactorResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[dto.ResetUserTwoFactorResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	exists, err := u.userRepository.IsLoginExists(ctx, login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.ResetUserTwoFactorResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !exists {
+		return dto.NewAPIResponse[dto.ResetUserTwoFactorResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.UserNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	deleted, err := u.twoFactorRepository.DeleteTOTP(ctx, login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[dto.ResetUserTwoFactorResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if !deleted {
+		return dto.NewAPIResponse[dto.ResetUserTwoFactorResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.TwoFactorNotEnabledErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+
+	return dto.NewAPIResponse[dto.ResetUserTwoFactorResponse, any](nil, dto.ResetUserTwoFactorResponse{
+		login: true,
+	}, nil), http.StatusOK
+}
+
+// createAuthChallenge returns challenge to be exchanged for auth token with VerifyTwoFactor.
+func (u usecase) createAuthChallenge(ctx context.Context, login, device string) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	challenge := userDomain.GenerateAuthToken()
+
+	err := u.twoFactorRepository.CreateAuthChallenge(ctx, userDomain.AuthChallenge{
+		ChallengeHash: userDomain.HashAuthToken(challenge, u.tokenPepper),
+		Login:         login,
+		Device:        device,
+		ExpiresAt:     time.Now().Add(u.challengeTTL),
+	})
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.AuthResponse, any](nil, &dto.AuthResponse{
+		Challenge:         challenge,
+		TwoFactorRequired: true,
+	}, nil), http.StatusAccepted
+}
+
+// twoFactorFailed registers failed auth attempt and drops challenge when attempts are exhausted.
+func (u usecase) twoFactorFailed(ctx context.Context, attempt *authAttempt, challenge userDomain.AuthChallenge) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	err := u.failAuthAttempt(ctx, attempt)
+	if err != nil {
+		logger.Error("auth failure registration error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	attempts, err := u.twoFactorRepository.RegisterAuthChallengeAttempt(ctx, challenge.ChallengeHash)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+	if attempts >= u.challengeMaxAttempts {
+		return u.dropAuthChallenge(ctx, challenge.ChallengeHash, apperrors.TwoFactorCodeInvalidErrorText)
+	}
+
+	return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+		Code: apperrors.BadRequestErrorCode,
+		Text: apperrors.TwoFactorCodeInvalidErrorText,
+	}, nil, nil), http.StatusBadRequest
+}
+
+// dropAuthChallenge deletes challenge and returns bad request response with given text.
+func (u usecase) dropAuthChallenge(ctx context.Context, challengeHash string, text apperrors.ErrorText) (dto.APIResponse[*dto.AuthResponse, any], int) {
+	err := u.twoFactorRepository.DeleteAuthChallenge(ctx, challengeHash)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.AuthResponse, any](&dto.Error{
+		Code: apperrors.BadRequestErrorCode,
+		Text: text,
+	}, nil, nil), http.StatusBadRequest
+}
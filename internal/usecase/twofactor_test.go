@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+)
+
+// totpCode returns RFC 6238 code of secret for time step.
+func totpCode(secret string, step int64) string {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		panic(err)
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// newTwoFactorTestUsecase returns usecase with user having TOTP enabled, its secret,
+// recovery codes and time step used to confirm enrollment.
+func newTwoFactorTestUsecase(t *testing.T) (usecase, string, []string, int64) {
+	t.Helper()
+
+	repo := newFakeRepository()
+	user := repo.addUserWithPassword(userDomain.User{Login: "aliceuser", Role: userDomain.RoleEditor}, "Password1!")
+	u := newTestUsecase(repo)
+	u.setLockoutPolicy(userDomain.LockoutPolicy{MaxAttempts: 10, BackoffBase: time.Nanosecond, LockoutDuration: time.Hour})
+	u.challengeTTL = time.Minute
+	u.challengeMaxAttempts = 5
+	u.recoveryCodes = 3
+
+	enrolled, status := u.EnrollTOTP(context.Background(), userDomain.Principal{User: user})
+	if status != http.StatusCreated {
+		t.Fatalf("EnrollTOTP status = %d, want %d", status, http.StatusCreated)
+	}
+
+	step := time.Now().Unix() / 30
+	confirmed, status := u.ConfirmTOTP(context.Background(), userDomain.Principal{User: user}, totpCode(enrolled.Response.Secret, step), "127.0.0.1")
+	if status != http.StatusOK {
+		t.Fatalf("ConfirmTOTP status = %d, want %d", status, http.StatusOK)
+	}
+	if len(confirmed.Response.RecoveryCodes) != 3 {
+		t.Fatalf("recovery codes = %d, want 3", len(confirmed.Response.RecoveryCodes))
+	}
+
+	return u, enrolled.Response.Secret, confirmed.Response.RecoveryCodes, step
+}
+
+// authChallenge passes password check and returns second factor challenge.
+func authChallenge(t *testing.T, u usecase) string {
+	t.Helper()
+
+	result, status := u.Auth(context.Background(), "aliceuser", "Password1!", "device", "agent", "127.0.0.1")
+	if status != http.StatusAccepted || !result.Response.TwoFactorRequired {
+		t.Fatalf("Auth status = %d, want %d with challenge", status, http.StatusAccepted)
+	}
+
+	return result.Response.Challenge
+}
+
+func TestVerifyTwoFactorStepReplay(t *testing.T) {
+	u, secret, _, step := newTwoFactorTestUsecase(t)
+
+	challenge := authChallenge(t, u)
+
+	// Code used to confirm enrollment can't be used again.
+	_, status := u.VerifyTwoFactor(context.Background(), challenge, totpCode(secret, step), "agent", "127.0.0.1")
+	if status != http.StatusBadRequest {
+		t.Fatalf("VerifyTwoFactor with used code status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	// Next step code is accepted within clock skew.
+	next := totpCode(secret, step+1)
+	_, status = u.VerifyTwoFactor(context.Background(), challenge, next, "agent", "127.0.0.1")
+	if status != http.StatusCreated {
+		t.Fatalf("VerifyTwoFactor status = %d, want %d", status, http.StatusCreated)
+	}
+
+	// Challenge is exchanged once.
+	_, status = u.VerifyTwoFactor(context.Background(), challenge, next, "agent", "127.0.0.1")
+	if status != http.StatusBadRequest {
+		t.Fatalf("VerifyTwoFactor with used challenge status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	// Code of the same or earlier step is rejected with new challenge.
+	challenge = authChallenge(t, u)
+	for _, code := range []string{next, totpCode(secret, step)} {
+		_, status = u.VerifyTwoFactor(context.Background(), challenge, code, "agent", "127.0.0.1")
+		if status != http.StatusBadRequest {
+			t.Fatalf("VerifyTwoFactor with replayed code status = %d, want %d", status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestVerifyTwoFactorRecoveryCode(t *testing.T) {
+	u, _, codes, _ := newTwoFactorTestUsecase(t)
+
+	// Recovery codes are accepted regardless of case and dashes.
+	code := strings.ToLower(strings.ReplaceAll(codes[0], "-", ""))
+	_, status := u.VerifyTwoFactor(context.Background(), authChallenge(t, u), code, "agent", "127.0.0.1")
+	if status != http.StatusCreated {
+		t.Fatalf("VerifyTwoFactor with recovery code status = %d, want %d", status, http.StatusCreated)
+	}
+
+	_, status = u.VerifyTwoFactor(context.Background(), authChallenge(t, u), codes[0], "agent", "127.0.0.1")
+	if status != http.StatusBadRequest {
+		t.Fatalf("VerifyTwoFactor with used recovery code status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	_, status = u.VerifyTwoFactor(context.Background(), authChallenge(t, u), codes[1], "agent", "127.0.0.1")
+	if status != http.StatusCreated {
+		t.Fatalf("VerifyTwoFactor with other recovery code status = %d, want %d", status, http.StatusCreated)
+	}
+}
+
+func TestVerifyTwoFactorChallengeAttempts(t *testing.T) {
+	u, secret, _, step := newTwoFactorTestUsecase(t)
+
+	challenge := authChallenge(t, u)
+	for range u.challengeMaxAttempts {
+		_, status := u.VerifyTwoFactor(context.Background(), challenge, "000000", "agent", "127.0.0.1")
+		if status != http.StatusBadRequest {
+			t.Fatalf("VerifyTwoFactor with wrong code status = %d, want %d", status, http.StatusBadRequest)
+		}
+	}
+
+	// Challenge is dropped after too many wrong codes.
+	_, status := u.VerifyTwoFactor(context.Background(), challenge, totpCode(secret, step+1), "agent", "127.0.0.1")
+	if status != http.StatusBadRequest {
+		t.Fatalf("VerifyTwoFactor with dropped challenge status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
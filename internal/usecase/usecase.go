@@ -3,43 +3,594 @@ package usecase
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/srgklmv/astral/internal/config"
 	"github.com/srgklmv/astral/internal/domain/document"
+	"github.com/srgklmv/astral/internal/domain/group"
+	"github.com/srgklmv/astral/internal/domain/invite"
 	"github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/pkg/ldap"
+	"github.com/srgklmv/astral/pkg/logger"
+	"github.com/srgklmv/astral/pkg/oidc"
+	"github.com/srgklmv/astral/pkg/webauthn"
 )
 
 type repository interface {
 	userRepository
 	documentRepository
+	inviteRepository
+	lockoutRepository
+	twoFactorRepository
+	apiKeyRepository
+	revocationRepository
+	identityRepository
+	passkeyRepository
+	groupRepository
+	shareLinkRepository
 }
 
 type documentRepository interface {
-	UploadDocument(ctx context.Context, login, filename string, isFile bool, mimetype string, isPublic bool, grantedTo []string, json map[string]any, file *bytes.Buffer) (document.Document, error)
+	UploadDocument(ctx context.Context, login, filename string, isFile bool, mimetype string, isPublic bool, grants []document.Grant, tags []string, json map[string]any, file *bytes.Buffer) (document.Document, error)
+	UpdateDocument(ctx context.Context, id uuid.UUID, author, filename string, isFile bool, mimetype string, isPublic bool, json map[string]any, file *bytes.Buffer, keepVersions int) (document.Document, error)
+	PatchDocumentJSON(ctx context.Context, id uuid.UUID, author string, keepVersions int, patch func(doc []byte) ([]byte, error)) (map[string]any, error)
+	GetDocumentVersions(ctx context.Context, id uuid.UUID) ([]document.Version, error)
+	GetDocumentVersion(ctx context.Context, id uuid.UUID, number int) (document.Version, error)
+	RestoreDocumentVersion(ctx context.Context, id uuid.UUID, number int, author string, keepVersions int) (int, error)
 	DeleteDocument(ctx context.Context, id uuid.UUID) error
+	AddDocumentGrants(ctx context.Context, id uuid.UUID, grants []document.Grant) error
+	RevokeDocumentGrants(ctx context.Context, id uuid.UUID, logins, groups []string) error
 	GetDocument(ctx context.Context, id uuid.UUID) (document.Document, error)
-	GetDocumentsData(ctx context.Context, userLogin string, isAdmin bool, login, key, value string, limit int) (document.DocumentsData, error)
+	GetDocumentsData(ctx context.Context, userLogin string, readAny bool, login, key, value string, limit int, filter document.Filter) (document.DocumentsData, error)
 }
 
 type userRepository interface {
 	IsLoginExists(ctx context.Context, login string) (bool, error)
-	IsAdminTokenValid(ctx context.Context, token string) (bool, error)
-	CreateUser(ctx context.Context, login, hashedPassword string, isAdmin bool) (user.User, error)
-	SaveAuthToken(ctx context.Context, login, token string) error
-	DeleteToken(ctx context.Context, token string) error
+	IsAdminExists(ctx context.Context) (bool, error)
+	CreateUser(ctx context.Context, login, hashedPassword, role string) (user.User, error)
+	CreateUserWithInviteCode(ctx context.Context, codeHash, login, hashedPassword string) (user.User, error)
+	SaveAuthToken(ctx context.Context, login string, authToken user.AuthToken) (string, error)
+	RotateAuthToken(ctx context.Context, tokenHash, newTokenHash string, expiresAt time.Time) (bool, error)
+	TouchAuthToken(ctx context.Context, tokenHash string, expiresAt time.Time) error
+	DeleteToken(ctx context.Context, tokenHash string) error
 	GetUserHashedPassword(ctx context.Context, login string) (string, error)
-	DeleteAllUserTokens(ctx context.Context, login string) error
-	GetUserByAuthToken(ctx context.Context, token string) (user.User, error)
+	GetUserByLogin(ctx context.Context, login string) (user.User, error)
+	UpdateUserPassword(ctx context.Context, login, hashedPassword string, mustChangePassword bool) error
+	UpdateUserPasswordHash(ctx context.Context, login, oldHashedPassword, hashedPassword string) error
+	GetUserDeletionReport(ctx context.Context, login string) (user.DeletionReport, error)
+	CountAdmins(ctx context.Context) (int, error)
+	EnsureArchiveUser(ctx context.Context, login string) error
+	DeleteUser(ctx context.Context, login, transferTo string) error
+	GetUsers(ctx context.Context, search string, limit, offset int) ([]user.Summary, int, error)
+	UpdateUserAccess(ctx context.Context, login string, role *string, isDisabled *bool) error
+	UpdateLastLogin(ctx context.Context, login string) error
+	DeleteAllUserTokens(ctx context.Context, login string) (int, error)
+	GetUserByAuthToken(ctx context.Context, tokenHash string) (user.User, user.AuthToken, error)
+	GetUserAuthTokens(ctx context.Context, login string) ([]user.AuthToken, error)
+	DeleteUserAuthToken(ctx context.Context, login, id string) (bool, error)
+	DeleteUserTokensExcept(ctx context.Context, login, id string) (int, error)
+}
+
+type inviteRepository interface {
+	CreateInviteCode(ctx context.Context, codeHash, role string, maxUses int, createdBy *string, expiresAt time.Time) (invite.Code, error)
+	GetInviteCodes(ctx context.Context) ([]invite.Code, error)
+	RevokeInviteCode(ctx context.Context, id string) (bool, error)
+	RevokeBootstrapInviteCodes(ctx context.Context) error
+}
+
+type lockoutRepository interface {
+	ReserveAuthAttempt(ctx context.Context, kind, key string, window time.Duration, policy user.LockoutPolicy) (int, bool, error)
+	ReleaseAuthAttempt(ctx context.Context, kind, key string, maxAttempts int) error
+	LockAuthKey(ctx context.Context, kind, key string, lockedUntil time.Time) error
+	DeleteLockout(ctx context.Context, kind, key string) (bool, error)
+	GetLockouts(ctx context.Context) ([]user.Lockout, error)
+}
+
+type twoFactorRepository interface {
+	GetTOTP(ctx context.Context, login string) (user.TOTP, error)
+	SaveTOTPSecret(ctx context.Context, login, secret string) error
+	ConfirmTOTP(ctx context.Context, login string, step int64, recoveryCodeHashes []string) error
+	UseTOTPStep(ctx context.Context, login string, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, login, codeHash string) (bool, error)
+	DeleteTOTP(ctx context.Context, login string) (bool, error)
+	CreateAuthChallenge(ctx context.Context, challenge user.AuthChallenge) error
+	GetAuthChallenge(ctx context.Context, challengeHash string) (user.AuthChallenge, error)
+	RegisterAuthChallengeAttempt(ctx context.Context, challengeHash string) (int, error)
+	DeleteAuthChallenge(ctx context.Context, challengeHash string) error
+}
+
+type apiKeyRepository interface {
+	CreateAPIKey(ctx context.Context, login string, apiKey user.APIKey) (user.APIKey, error)
+	GetUserAPIKeys(ctx context.Context, login string) ([]user.APIKey, error)
+	DeleteUserAPIKey(ctx context.Context, login, id string) (bool, error)
+	GetUserByAPIKey(ctx context.Context, keyHash string) (user.User, user.APIKey, error)
+	TouchAPIKey(ctx context.Context, keyHash string) error
+}
+
+type revocationRepository interface {
+	GetDatabaseTime(ctx context.Context) (time.Time, error)
+	GetSessionRevocations(ctx context.Context, since time.Time) (map[string]time.Time, error)
+	DeleteSessionRevocations(ctx context.Context, before time.Time) error
+	RevokeUserSessions(ctx context.Context, login string) error
+}
+
+type identityRepository interface {
+	SaveOIDCState(ctx context.Context, state user.OIDCState) error
+	ConsumeOIDCState(ctx context.Context, stateHash string) (user.OIDCState, error)
+	GetUserByIdentity(ctx context.Context, provider, subject string) (user.User, error)
+	CreateUserWithIdentity(ctx context.Context, identity user.Identity, role string) (user.User, error)
+}
+
+type passkeyRepository interface {
+	CreatePasskey(ctx context.Context, login string, passkey user.Passkey) (user.Passkey, error)
+	GetUserPasskeys(ctx context.Context, login string) ([]user.Passkey, error)
+	DeleteUserPasskey(ctx context.Context, login, id string) (bool, error)
+	GetPasskeyHandle(ctx context.Context, login string) ([]byte, error)
+	SetPasskeyHandle(ctx context.Context, login string, handle []byte) ([]byte, error)
+	GetUserByPasskeyHandle(ctx context.Context, handle []byte) (user.User, error)
+	UpdatePasskeyUsage(ctx context.Context, credentialID []byte, signCount uint32, backupState bool) (bool, error)
+	SavePasskeySession(ctx context.Context, session user.PasskeySession) error
+	ConsumePasskeySession(ctx context.Context, sessionHash string) (user.PasskeySession, error)
+}
+
+type groupRepository interface {
+	IsGroupExists(ctx context.Context, name string) (bool, error)
+	CreateGroup(ctx context.Context, name, creator string, members []string) (group.Group, error)
+	GetGroup(ctx context.Context, name string) (group.Group, error)
+	GetGroups(ctx context.Context, login string) ([]group.Group, error)
+	DeleteGroup(ctx context.Context, name string) error
+	SetGroupMember(ctx context.Context, name, login string, isAdmin bool) error
+	RemoveGroupMember(ctx context.Context, name, login string) (bool, error)
+	GetMemberGroups(ctx context.Context, login string, groups []string) ([]string, error)
+}
+
+type shareLinkRepository interface {
+	CreateShareLink(ctx context.Context, link document.Link) (document.Link, error)
+	GetDocumentShareLinks(ctx context.Context, id uuid.UUID) ([]document.Link, error)
+	GetShareLink(ctx context.Context, tokenHash string) (document.Link, error)
+	UseShareLink(ctx context.Context, id string, maxPasswordFailures int) (bool, error)
+	RegisterShareLinkPasswordFailure(ctx context.Context, id string, maxFailures int) (bool, error)
+	RevokeShareLink(ctx context.Context, documentID uuid.UUID, id string) (bool, error)
 }
 
 type usecase struct {
-	userRepository     userRepository
-	documentRepository documentRepository
+	userRepository       userRepository
+	documentRepository   documentRepository
+	inviteRepository     inviteRepository
+	lockoutRepository    lockoutRepository
+	twoFactorRepository  twoFactorRepository
+	apiKeyRepository     apiKeyRepository
+	revocationRepository revocationRepository
+	identityRepository   identityRepository
+	passkeyRepository    passkeyRepository
+	groupRepository      groupRepository
+	shareLinkRepository  shareLinkRepository
+	tokenTTL             time.Duration
+	tokenRenewalWindow   time.Duration
+	tokenPepper          string
+	archiveLogin         string
+	defaultRole          string
+	lockoutPolicies      map[string]user.LockoutPolicy
+	lockoutWindow        time.Duration
+	loginPolicy          user.LoginPolicy
+	passwordPolicy       user.PasswordPolicy
+	hashParams           user.HashParams
+	// authenticators are password auth backends in configured order.
+	authenticators []authenticator
+	// ldapGroupRoles map directory groups to roles, roles are not synced if empty.
+	ldapGroupRoles       map[string]string
+	ldapAutoProvision    bool
+	twoFactorIssuer      string
+	challengeTTL         time.Duration
+	challengeMaxAttempts int
+	recoveryCodes        int
+	// accessTokenSigner is set in stateless access tokens mode only.
+	accessTokenSigner    *user.AccessTokenSigner
+	accessTokenTTL       time.Duration
+	refreshTokenTTL      time.Duration
+	denylist             *user.Denylist
+	denylistSyncInterval time.Duration
+	// oidcClient is set if OpenID Connect login is enabled only.
+	oidcClient        oidcClient
+	oidcProvider      string
+	oidcLoginClaim    string
+	oidcGroupsClaim   string
+	oidcGroupRoles    map[string]string
+	oidcAutoProvision bool
+	oidcStateTTL      time.Duration
+	// webAuthnClient is set if passkeys are enabled only.
+	webAuthnClient webAuthnClient
+	passkeyTimeout time.Duration
+	// versionRetention is a number of document versions kept, zero keeps all.
+	versionRetention int
+	shareLinkTTL     time.Duration
+	shareLinkMaxTTL  time.Duration
 }
 
-func New(repository repository) *usecase {
+func New(repository repository, authConfig config.Auth, documentsConfig config.Documents) (*usecase, error) {
+	hashParams := newHashParams(authConfig.PasswordHashing)
+	err := hashParams.Validate()
+	if err != nil {
+		logger.Error("password hashing config error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	passwordPolicy, err := newPasswordPolicy(authConfig.PasswordPolicy, hashParams)
+	if err != nil {
+		logger.Error("password policy error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	authenticators, err := newAuthenticators(authConfig, repository, hashParams)
+	if err != nil {
+		logger.Error("auth backends config error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	var accessTokenSigner *user.AccessTokenSigner
+	if authConfig.AccessTokens.Enabled {
+		signer, err := user.NewAccessTokenSigner(authConfig.AccessTokens.SigningKey)
+		if err != nil {
+			logger.Error("access token signing key error", slog.String("error", err.Error()))
+			return nil, err
+		}
+		accessTokenSigner = &signer
+	}
+
+	var oidcClient oidcClient
+	if authConfig.OIDC.Enabled {
+		if authConfig.OIDC.Issuer == "" || authConfig.OIDC.ClientID == "" {
+			err = errors.New("oidc issuer and client ID required")
+			logger.Error("oidc config error", slog.String("error", err.Error()))
+			return nil, err
+		}
+
+		oidcClient = oidc.New(oidc.Config{
+			Issuer:       authConfig.OIDC.Issuer,
+			ClientID:     authConfig.OIDC.ClientID,
+			ClientSecret: authConfig.OIDC.ClientSecret,
+			RedirectURL:  authConfig.OIDC.RedirectURL,
+			Scopes:       authConfig.OIDC.Scopes,
+		})
+	}
+
+	passkeyTimeout := orDefault(authConfig.Passkeys.Timeout, time.Minute*5)
+
+	var webAuthnClient webAuthnClient
+	if authConfig.Passkeys.Enabled {
+		rpDisplayName := authConfig.Passkeys.RPDisplayName
+		if rpDisplayName == "" {
+			rpDisplayName = "astral"
+		}
+
+		client, err := webauthn.New(webauthn.Config{
+			RPID:          authConfig.Passkeys.RPID,
+			RPDisplayName: rpDisplayName,
+			RPOrigins:     authConfig.Passkeys.RPOrigins,
+			Timeout:       passkeyTimeout,
+		})
+		if err != nil {
+			logger.Error("passkeys config error", slog.String("error", err.Error()))
+			return nil, err
+		}
+		webAuthnClient = client
+	}
+
+	oidcLoginClaim := authConfig.OIDC.LoginClaim
+	if oidcLoginClaim == "" {
+		oidcLoginClaim = "preferred_username"
+	}
+
+	oidcGroupsClaim := authConfig.OIDC.GroupsClaim
+	if oidcGroupsClaim == "" {
+		oidcGroupsClaim = "groups"
+	}
+
+	defaultRole := authConfig.DefaultRole
+	if defaultRole == "" {
+		defaultRole = user.RoleEditor
+	}
+	if !user.IsValidRole(defaultRole) {
+		err = fmt.Errorf("unknown default role %q", defaultRole)
+		logger.Error("roles config error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	ldapGroupRoles, err := newGroupRoles(authConfig.LDAP.AdminGroups, authConfig.LDAP.GroupRoles)
+	if err != nil {
+		logger.Error("ldap config error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	oidcGroupRoles, err := newGroupRoles(authConfig.OIDC.AdminGroups, authConfig.OIDC.GroupRoles)
+	if err != nil {
+		logger.Error("oidc config error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	if documentsConfig.VersionRetention < 0 {
+		err = errors.New("document version retention must not be negative")
+		logger.Error("documents config error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	shareLinkTTL := orDefault(documentsConfig.ShareLinkTTL, time.Hour*24*7)
+	shareLinkMaxTTL := orDefault(documentsConfig.ShareLinkMaxTTL, time.Hour*24*30)
+	if shareLinkTTL <= 0 || shareLinkMaxTTL < shareLinkTTL {
+		err = errors.New("share link TTL must be positive and not exceed max TTL")
+		logger.Error("documents config error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	archiveLogin := authConfig.ArchiveLogin
+	if archiveLogin == "" {
+		archiveLogin = "archive"
+	}
+
+	tokenTTL := time.Duration(authConfig.TokenTTL) * time.Second
+	if tokenTTL == 0 {
+		tokenTTL = time.Hour * 24
+	}
+
+	twoFactorIssuer := authConfig.TwoFactor.Issuer
+	if twoFactorIssuer == "" {
+		twoFactorIssuer = "astral"
+	}
+
+	challengeMaxAttempts := authConfig.TwoFactor.ChallengeMaxAttempts
+	if challengeMaxAttempts == 0 {
+		challengeMaxAttempts = 5
+	}
+
+	recoveryCodes := authConfig.TwoFactor.RecoveryCodes
+	if recoveryCodes == 0 {
+		recoveryCodes = 10
+	}
+
 	return &usecase{
-		userRepository:     repository,
-		documentRepository: repository,
+		userRepository:       repository,
+		documentRepository:   repository,
+		inviteRepository:     repository,
+		lockoutRepository:    repository,
+		twoFactorRepository:  repository,
+		apiKeyRepository:     repository,
+		revocationRepository: repository,
+		identityRepository:   repository,
+		passkeyRepository:    repository,
+		groupRepository:      repository,
+		shareLinkRepository:  repository,
+		tokenTTL:             tokenTTL,
+		tokenRenewalWindow:   time.Duration(authConfig.TokenRenewalWindow) * time.Second,
+		tokenPepper:          authConfig.TokenPepper,
+		archiveLogin:         archiveLogin,
+		defaultRole:          defaultRole,
+		lockoutPolicies:      newLockoutPolicies(authConfig.Lockout),
+		lockoutWindow:        orDefault(authConfig.Lockout.Window, time.Minute*15),
+		loginPolicy:          newLoginPolicy(authConfig.LoginPolicy),
+		passwordPolicy:       passwordPolicy,
+		hashParams:           hashParams,
+		authenticators:       authenticators,
+		ldapGroupRoles:       ldapGroupRoles,
+		ldapAutoProvision:    authConfig.LDAP.AutoProvision,
+		twoFactorIssuer:      twoFactorIssuer,
+		challengeTTL:         orDefault(authConfig.TwoFactor.ChallengeTTL, time.Minute*5),
+		challengeMaxAttempts: challengeMaxAttempts,
+		recoveryCodes:        recoveryCodes,
+		accessTokenSigner:    accessTokenSigner,
+		accessTokenTTL:       orDefault(authConfig.AccessTokens.TTL, time.Minute*15),
+		refreshTokenTTL:      orDefault(authConfig.AccessTokens.RefreshTTL, time.Hour*24*30),
+		denylist:             user.NewDenylist(),
+		denylistSyncInterval: orDefault(authConfig.AccessTokens.DenylistSyncInterval, time.Second*10),
+		oidcClient:           oidcClient,
+		oidcProvider:         authConfig.OIDC.Issuer,
+		oidcLoginClaim:       oidcLoginClaim,
+		oidcGroupsClaim:      oidcGroupsClaim,
+		oidcGroupRoles:       oidcGroupRoles,
+		oidcAutoProvision:    authConfig.OIDC.AutoProvision,
+		oidcStateTTL:         orDefault(authConfig.OIDC.StateTTL, time.Minute*10),
+		webAuthnClient:       webAuthnClient,
+		passkeyTimeout:       passkeyTimeout,
+		versionRetention:     documentsConfig.VersionRetention,
+		shareLinkTTL:         shareLinkTTL,
+		shareLinkMaxTTL:      shareLinkMaxTTL,
+	}, nil
+}
+
+// newGroupRoles merges admin groups into group to role mapping.
+func newGroupRoles(adminGroups []string, groupRoles map[string]string) (map[string]string, error) {
+	roles := make(map[string]string, len(adminGroups)+len(groupRoles))
+	for group, role := range groupRoles {
+		if !user.IsValidRole(role) {
+			return nil, fmt.Errorf("unknown role %q of group %q", role, group)
+		}
+		roles[group] = role
+	}
+	for _, group := range adminGroups {
+		roles[group] = user.RoleAdmin
+	}
+
+	return roles, nil
+}
+
+func newLoginPolicy(cfg config.LoginPolicy) user.LoginPolicy {
+	if cfg == (config.LoginPolicy{}) {
+		return user.DefaultLoginPolicy()
+	}
+
+	policy := user.LoginPolicy{
+		MinLength:    cfg.MinLength,
+		MaxLength:    cfg.MaxLength,
+		AllowUnicode: cfg.AllowUnicode,
+		SpecialChars: cfg.SpecialChars,
+	}
+	if policy.MaxLength == 0 {
+		policy.MaxLength = 255
+	}
+
+	return policy
+}
+
+// bcryptMaxPasswordBytes is the longest password bcrypt hashes.
+const bcryptMaxPasswordBytes = 72
+
+func newPasswordPolicy(cfg config.PasswordPolicy, hashParams user.HashParams) (user.PasswordPolicy, error) {
+	if cfg == (config.PasswordPolicy{}) {
+		policy := user.DefaultPasswordPolicy()
+		if hashParams.Algorithm == user.HashAlgorithmBcrypt {
+			policy.MaxBytes = bcryptMaxPasswordBytes
+		}
+
+		return policy, nil
+	}
+
+	policy := user.PasswordPolicy{
+		MinLength:           cfg.MinLength,
+		MaxLength:           cfg.MaxLength,
+		RequireUpper:        cfg.RequireUpper,
+		RequireLower:        cfg.RequireLower,
+		RequireDigit:        cfg.RequireDigit,
+		RequireSpecial:      cfg.RequireSpecial,
+		SpecialChars:        cfg.SpecialChars,
+		AllowUnicode:        cfg.AllowUnicode,
+		AllowSpaces:         cfg.AllowSpaces,
+		PassphraseMinLength: cfg.PassphraseMinLength,
+		MinEntropy:          cfg.MinEntropy,
+	}
+	// Bcrypt ignores bytes after 72nd, so it gets lower limit by default.
+	if policy.MaxLength == 0 {
+		policy.MaxLength = 128
+		if hashParams.Algorithm == user.HashAlgorithmBcrypt {
+			policy.MaxLength = 72
+		}
+	}
+	if hashParams.Algorithm == user.HashAlgorithmBcrypt && policy.MaxLength > 72 {
+		return policy, errors.New("password max length must not exceed 72 with bcrypt hashing")
+	}
+	// Non-ASCII characters take several bytes, so length in characters is not enough for bcrypt.
+	if hashParams.Algorithm == user.HashAlgorithmBcrypt {
+		policy.MaxBytes = bcryptMaxPasswordBytes
+	}
+
+	if cfg.DenyListPath != "" {
+		denyList, err := user.LoadDenyList(cfg.DenyListPath)
+		if err != nil {
+			return policy, err
+		}
+		policy.DenyList = denyList
+	}
+
+	return policy, nil
+}
+
+func newHashParams(cfg config.PasswordHashing) user.HashParams {
+	params := user.DefaultHashParams()
+
+	if cfg.Algorithm != "" {
+		params.Algorithm = cfg.Algorithm
+	}
+	if cfg.Argon2Memory != 0 {
+		params.Argon2Memory = cfg.Argon2Memory
+	}
+	if cfg.Argon2Iterations != 0 {
+		params.Argon2Iterations = cfg.Argon2Iterations
+	}
+	if cfg.Argon2Parallelism != 0 {
+		params.Argon2Parallelism = cfg.Argon2Parallelism
+	}
+	if cfg.Argon2SaltLength != 0 {
+		params.Argon2SaltLength = cfg.Argon2SaltLength
+	}
+	if cfg.Argon2KeyLength != 0 {
+		params.Argon2KeyLength = cfg.Argon2KeyLength
+	}
+	if cfg.BcryptCost != 0 {
+		params.BcryptCost = cfg.BcryptCost
+	}
+
+	return params
+}
+
+func newAuthenticators(cfg config.Auth, repository userRepository, hashParams user.HashParams) ([]authenticator, error) {
+	backends := cfg.Backends
+	if len(backends) == 0 {
+		backends = []string{user.IdentityProviderLocal}
+	}
+
+	authenticators := make([]authenticator, 0, len(backends))
+	for _, backend := range backends {
+		switch backend {
+		case user.IdentityProviderLocal:
+			authenticators = append(authenticators, localAuthenticator{
+				userRepository: repository,
+				hashParams:     hashParams,
+			})
+		case user.IdentityProviderLDAP:
+			if cfg.LDAP.URL == "" || cfg.LDAP.BaseDN == "" {
+				return nil, errors.New("ldap url and base DN required")
+			}
+
+			authenticators = append(authenticators, ldapAuthenticator{
+				client: ldap.New(ldap.Config{
+					URL:                cfg.LDAP.URL,
+					StartTLS:           cfg.LDAP.StartTLS,
+					InsecureSkipVerify: cfg.LDAP.InsecureSkipVerify,
+					BindDN:             cfg.LDAP.BindDN,
+					BindPassword:       cfg.LDAP.BindPassword,
+					BaseDN:             cfg.LDAP.BaseDN,
+					UserFilter:         cfg.LDAP.UserFilter,
+					LoginAttribute:     cfg.LDAP.LoginAttribute,
+					GroupAttribute:     cfg.LDAP.GroupAttribute,
+					GroupBaseDN:        cfg.LDAP.GroupBaseDN,
+					GroupFilter:        cfg.LDAP.GroupFilter,
+					GroupNameAttribute: cfg.LDAP.GroupNameAttribute,
+					Timeout:            orDefault(cfg.LDAP.Timeout, time.Second*10),
+				}),
+			})
+		default:
+			return nil, fmt.Errorf("unknown auth backend %q", backend)
+		}
+	}
+
+	return authenticators, nil
+}
+
+func newLockoutPolicies(cfg config.Lockout) map[string]user.LockoutPolicy {
+	backoffBase := orDefault(cfg.BackoffBase, time.Second)
+	lockoutDuration := orDefault(cfg.LockoutDuration, time.Minute*15)
+
+	loginMaxAttempts := cfg.LoginMaxAttempts
+	if loginMaxAttempts == 0 {
+		loginMaxAttempts = 5
+	}
+
+	ipMaxAttempts := cfg.IPMaxAttempts
+	if ipMaxAttempts == 0 {
+		ipMaxAttempts = 20
+	}
+
+	return map[string]user.LockoutPolicy{
+		user.LockoutKindLogin: {
+			MaxAttempts:     loginMaxAttempts,
+			BackoffBase:     backoffBase,
+			LockoutDuration: lockoutDuration,
+		},
+		user.LockoutKindIP: {
+			MaxAttempts:     ipMaxAttempts,
+			BackoffBase:     backoffBase,
+			LockoutDuration: lockoutDuration,
+		},
 	}
 }
+
+// orDefault converts config seconds to duration falling back to default on zero value.
+func orDefault(seconds int, def time.Duration) time.Duration {
+	if seconds == 0 {
+		return def
+	}
+
+	return time.Duration(seconds) * time.Second
+}
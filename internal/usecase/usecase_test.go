@@ -0,0 +1,1131 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/srgklmv/astral/internal/config"
+	"github.com/srgklmv/astral/internal/domain/document"
+	"github.com/srgklmv/astral/internal/domain/group"
+	"github.com/srgklmv/astral/internal/domain/invite"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+// fakeRepository is an in-memory repository for usecase tests. Methods not implemented
+// here panic through embedded nil interface, so tests fail loudly on unexpected calls.
+type fakeRepository struct {
+	repository
+
+	mutex      sync.Mutex
+	users      map[string]userDomain.User
+	passwords  map[string]string
+	identities map[string]string
+	oidcStates map[string]userDomain.OIDCState
+	tokens     map[string]userDomain.AuthToken
+	// tokenLogins are owners of tokens by token hash.
+	tokenLogins map[string]string
+	failures    map[string]int
+	// locks are lockout expiration times by kind and key.
+	locks map[string]time.Time
+	totps map[string]userDomain.TOTP
+	// recoveryCodes are used flags by login and code hash.
+	recoveryCodes map[string]map[string]bool
+	challenges    map[string]userDomain.AuthChallenge
+	handles       map[string][]byte
+	passkeys      map[string][]userDomain.Passkey
+	sessions      map[string]userDomain.PasskeySession
+	documents     map[uuid.UUID]document.Document
+	// versions are kept document versions from the oldest one.
+	versions map[uuid.UUID][]document.Version
+	groups   map[string][]string
+	// groupAdmins are admin logins by group name.
+	groupAdmins map[string][]string
+	links       map[string]document.Link
+	// invites are invite codes by code hash.
+	invites map[string]invite.Code
+	// revocations are revocation times by session ID.
+	revocations map[string]time.Time
+	// denylistSyncs counts denylist syncs.
+	denylistSyncs int
+	// beforePasskeyUsage runs before passkey counter update to interleave concurrent logins.
+	beforePasskeyUsage func()
+	// beforeUserAccess runs once before role or disabled flag update to interleave
+	// concurrent admin changes.
+	beforeUserAccess func()
+	// beforeGroupMember runs once before group membership change to interleave
+	// concurrent changes.
+	beforeGroupMember func()
+	// afterGetShareLink runs once after share link is loaded to interleave concurrent requests.
+	afterGetShareLink func()
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		users:         make(map[string]userDomain.User),
+		passwords:     make(map[string]string),
+		identities:    make(map[string]string),
+		oidcStates:    make(map[string]userDomain.OIDCState),
+		tokens:        make(map[string]userDomain.AuthToken),
+		tokenLogins:   make(map[string]string),
+		failures:      make(map[string]int),
+		locks:         make(map[string]time.Time),
+		totps:         make(map[string]userDomain.TOTP),
+		recoveryCodes: make(map[string]map[string]bool),
+		challenges:    make(map[string]userDomain.AuthChallenge),
+		handles:       make(map[string][]byte),
+		passkeys:      make(map[string][]userDomain.Passkey),
+		sessions:      make(map[string]userDomain.PasskeySession),
+		documents:     make(map[uuid.UUID]document.Document),
+		versions:      make(map[uuid.UUID][]document.Version),
+		groups:        make(map[string][]string),
+		groupAdmins:   make(map[string][]string),
+		links:         make(map[string]document.Link),
+		invites:       make(map[string]invite.Code),
+		revocations:   make(map[string]time.Time),
+	}
+}
+
+// newTestUsecase returns usecase backed by repo with default policies.
+func newTestUsecase(repo *fakeRepository) usecase {
+	return usecase{
+		userRepository:       repo,
+		documentRepository:   repo,
+		inviteRepository:     repo,
+		lockoutRepository:    repo,
+		twoFactorRepository:  repo,
+		apiKeyRepository:     repo,
+		revocationRepository: repo,
+		identityRepository:   repo,
+		passkeyRepository:    repo,
+		groupRepository:      repo,
+		shareLinkRepository:  repo,
+		tokenTTL:             time.Hour,
+		tokenPepper:          "pepper",
+		defaultRole:          userDomain.RoleViewer,
+		lockoutPolicies:      newLockoutPolicies(config.Lockout{}),
+		lockoutWindow:        time.Minute,
+		loginPolicy:          userDomain.DefaultLoginPolicy(),
+		passwordPolicy:       userDomain.DefaultPasswordPolicy(),
+		hashParams:           testHashParams,
+		authenticators:       []authenticator{localAuthenticator{userRepository: repo, hashParams: testHashParams}},
+	}
+}
+
+// testHashParams make hashing fast in tests.
+var testHashParams = userDomain.HashParams{Algorithm: userDomain.HashAlgorithmBcrypt, BcryptCost: 4}
+
+// addUserWithPassword adds local user with password hashed by testHashParams.
+func (r *fakeRepository) addUserWithPassword(user userDomain.User, password string) userDomain.User {
+	hashed, err := testHashParams.HashPassword(password)
+	if err != nil {
+		panic(err)
+	}
+
+	user = r.addUser(user)
+
+	r.mutex.Lock()
+	r.passwords[user.Login] = hashed
+	r.mutex.Unlock()
+
+	return user
+}
+
+func (r *fakeRepository) failureCount(kind, key string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.failures[kind+":"+key]
+}
+
+func (r *fakeRepository) addUser(user userDomain.User) userDomain.User {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user.ID = len(r.users) + 1
+	r.users[user.Login] = user
+
+	return user
+}
+
+func (r *fakeRepository) IsLoginExists(_ context.Context, login string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, ok := r.users[login]
+	return ok, nil
+}
+
+func (r *fakeRepository) GetUserByLogin(_ context.Context, login string) (userDomain.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, ok := r.users[login]
+	if !ok {
+		return user, sql.ErrNoRows
+	}
+
+	return user, nil
+}
+
+func (r *fakeRepository) GetUserHashedPassword(_ context.Context, login string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.passwords[login], nil
+}
+
+func (r *fakeRepository) UpdateUserPassword(_ context.Context, login, hashedPassword string, mustChangePassword bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user := r.users[login]
+	user.MustChangePassword = mustChangePassword
+	r.users[login] = user
+	r.passwords[login] = hashedPassword
+
+	return nil
+}
+
+func (r *fakeRepository) CountAdmins(context.Context) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.countAdmins(), nil
+}
+
+func (r *fakeRepository) countAdmins() int {
+	var count int
+	for _, user := range r.users {
+		if user.IsAdmin() && !user.IsDisabled {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (r *fakeRepository) UpdateUserAccess(_ context.Context, login string, role *string, isDisabled *bool) error {
+	if hook := r.beforeUserAccess; hook != nil {
+		r.beforeUserAccess = nil
+		hook()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	admins := r.countAdmins()
+	previous := r.users[login]
+	user := previous
+	if role != nil {
+		user.Role = *role
+	}
+	if isDisabled != nil {
+		user.IsDisabled = *isDisabled
+	}
+	r.users[login] = user
+
+	if admins > 0 && r.countAdmins() == 0 {
+		r.users[login] = previous
+		return userDomain.ErrLastAdmin
+	}
+
+	return nil
+}
+
+func (r *fakeRepository) DeleteAllUserTokens(_ context.Context, login string) (int, error) {
+	return r.deleteUserTokens(login, "")
+}
+
+func (r *fakeRepository) UpdateLastLogin(context.Context, string) error {
+	return nil
+}
+
+func (r *fakeRepository) SaveAuthToken(_ context.Context, login string, token userDomain.AuthToken) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token.ID = login + "-" + token.TokenHash
+	token.CreatedAt = time.Now()
+	r.tokens[token.TokenHash] = token
+	r.tokenLogins[token.TokenHash] = login
+
+	return token.ID, nil
+}
+
+func (r *fakeRepository) GetUserByAuthToken(_ context.Context, tokenHash string) (userDomain.User, userDomain.AuthToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return userDomain.User{}, token, sql.ErrNoRows
+	}
+
+	return r.users[r.tokenLogins[tokenHash]], token, nil
+}
+
+func (r *fakeRepository) TouchAuthToken(_ context.Context, tokenHash string, expiresAt time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	token.ExpiresAt = expiresAt
+	token.LastUsedAt = &now
+	r.tokens[tokenHash] = token
+
+	return nil
+}
+
+func (r *fakeRepository) RotateAuthToken(_ context.Context, tokenHash, newTokenHash string, expiresAt time.Time) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return false, nil
+	}
+	token.TokenHash = newTokenHash
+	token.ExpiresAt = expiresAt
+	r.tokens[newTokenHash] = token
+	r.tokenLogins[newTokenHash] = r.tokenLogins[tokenHash]
+	delete(r.tokens, tokenHash)
+	delete(r.tokenLogins, tokenHash)
+
+	return true, nil
+}
+
+func (r *fakeRepository) DeleteToken(_ context.Context, tokenHash string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.tokens, tokenHash)
+	delete(r.tokenLogins, tokenHash)
+
+	return nil
+}
+
+func (r *fakeRepository) GetUserAuthTokens(_ context.Context, login string) ([]userDomain.AuthToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var tokens []userDomain.AuthToken
+	for hash, token := range r.tokens {
+		if r.tokenLogins[hash] == login && !token.IsExpired(time.Now()) {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens, nil
+}
+
+func (r *fakeRepository) DeleteUserAuthToken(_ context.Context, login, id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for hash, token := range r.tokens {
+		if r.tokenLogins[hash] == login && token.ID == id {
+			delete(r.tokens, hash)
+			delete(r.tokenLogins, hash)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *fakeRepository) DeleteUserTokensExcept(_ context.Context, login, id string) (int, error) {
+	return r.deleteUserTokens(login, id)
+}
+
+// deleteUserTokens deletes user tokens except one with given ID.
+func (r *fakeRepository) deleteUserTokens(login, exceptID string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var count int
+	for hash, token := range r.tokens {
+		if r.tokenLogins[hash] == login && token.ID != exceptID {
+			delete(r.tokens, hash)
+			delete(r.tokenLogins, hash)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *fakeRepository) GetTOTP(_ context.Context, login string) (userDomain.TOTP, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.totps[login], nil
+}
+
+func (r *fakeRepository) SaveTOTPSecret(_ context.Context, login, secret string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.totps[login] = userDomain.TOTP{Secret: secret, CreatedAt: time.Now()}
+
+	return nil
+}
+
+func (r *fakeRepository) ConfirmTOTP(_ context.Context, login string, step int64, recoveryCodeHashes []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	totp := r.totps[login]
+	totp.ConfirmedAt = &now
+	totp.LastUsedStep = step
+	r.totps[login] = totp
+
+	r.recoveryCodes[login] = make(map[string]bool)
+	for _, hash := range recoveryCodeHashes {
+		r.recoveryCodes[login][hash] = false
+	}
+
+	return nil
+}
+
+func (r *fakeRepository) UseTOTPStep(_ context.Context, login string, step int64) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	totp := r.totps[login]
+	if totp.LastUsedStep >= step {
+		return false, nil
+	}
+	totp.LastUsedStep = step
+	r.totps[login] = totp
+
+	return true, nil
+}
+
+func (r *fakeRepository) UseRecoveryCode(_ context.Context, login, codeHash string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	used, ok := r.recoveryCodes[login][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	r.recoveryCodes[login][codeHash] = true
+
+	return true, nil
+}
+
+func (r *fakeRepository) CreateAuthChallenge(_ context.Context, challenge userDomain.AuthChallenge) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.challenges[challenge.ChallengeHash] = challenge
+
+	return nil
+}
+
+func (r *fakeRepository) GetAuthChallenge(_ context.Context, challengeHash string) (userDomain.AuthChallenge, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	challenge, ok := r.challenges[challengeHash]
+	if !ok {
+		return challenge, sql.ErrNoRows
+	}
+
+	return challenge, nil
+}
+
+func (r *fakeRepository) RegisterAuthChallengeAttempt(_ context.Context, challengeHash string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	challenge := r.challenges[challengeHash]
+	challenge.Attempts++
+	r.challenges[challengeHash] = challenge
+
+	return challenge.Attempts, nil
+}
+
+func (r *fakeRepository) DeleteAuthChallenge(_ context.Context, challengeHash string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.challenges, challengeHash)
+
+	return nil
+}
+
+func (r *fakeRepository) ReserveAuthAttempt(_ context.Context, kind, key string, _ time.Duration, policy userDomain.LockoutPolicy) (int, bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if time.Now().Before(r.locks[kind+":"+key]) {
+		return 0, false, nil
+	}
+
+	r.failures[kind+":"+key]++
+	if r.failures[kind+":"+key] >= policy.MaxAttempts {
+		r.locks[kind+":"+key] = time.Now().Add(policy.LockoutDuration)
+	}
+
+	return r.failures[kind+":"+key], true, nil
+}
+
+func (r *fakeRepository) ReleaseAuthAttempt(_ context.Context, kind, key string, maxAttempts int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.failures[kind+":"+key] >= maxAttempts {
+		delete(r.locks, kind+":"+key)
+	}
+	if r.failures[kind+":"+key] > 0 {
+		r.failures[kind+":"+key]--
+	}
+
+	return nil
+}
+
+func (r *fakeRepository) LockAuthKey(_ context.Context, kind, key string, lockedUntil time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if lockedUntil.After(r.locks[kind+":"+key]) {
+		r.locks[kind+":"+key] = lockedUntil
+	}
+
+	return nil
+}
+
+func (r *fakeRepository) DeleteLockout(_ context.Context, kind, key string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, ok := r.failures[kind+":"+key]
+	delete(r.failures, kind+":"+key)
+	delete(r.locks, kind+":"+key)
+
+	return ok, nil
+}
+
+func (r *fakeRepository) SaveOIDCState(_ context.Context, state userDomain.OIDCState) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.oidcStates[state.StateHash] = state
+	return nil
+}
+
+func (r *fakeRepository) ConsumeOIDCState(_ context.Context, stateHash string) (userDomain.OIDCState, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	state, ok := r.oidcStates[stateHash]
+	if !ok {
+		return state, sql.ErrNoRows
+	}
+	delete(r.oidcStates, stateHash)
+
+	return state, nil
+}
+
+func (r *fakeRepository) GetUserByIdentity(_ context.Context, provider, subject string) (userDomain.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	login, ok := r.identities[provider+":"+subject]
+	if !ok {
+		return userDomain.User{}, sql.ErrNoRows
+	}
+
+	return r.users[login], nil
+}
+
+func (r *fakeRepository) CreateUserWithIdentity(_ context.Context, identity userDomain.Identity, role string) (userDomain.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user := userDomain.User{
+		ID:    len(r.users) + 1,
+		Login: identity.Login,
+		Role:  role,
+	}
+	r.users[user.Login] = user
+	r.identities[identity.Provider+":"+identity.Subject] = user.Login
+
+	return user, nil
+}
+
+func (r *fakeRepository) CreatePasskey(_ context.Context, login string, passkey userDomain.Passkey) (userDomain.Passkey, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	passkey.ID = fmt.Sprintf("passkey-%d", len(r.passkeys[login])+1)
+	passkey.CreatedAt = time.Now()
+	r.passkeys[login] = append(r.passkeys[login], passkey)
+
+	return passkey, nil
+}
+
+func (r *fakeRepository) GetUserPasskeys(_ context.Context, login string) ([]userDomain.Passkey, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return slices.Clone(r.passkeys[login]), nil
+}
+
+func (r *fakeRepository) GetPasskeyHandle(_ context.Context, login string) ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.users[login]; !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return r.handles[login], nil
+}
+
+func (r *fakeRepository) SetPasskeyHandle(_ context.Context, login string, handle []byte) ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.handles[login]; !ok {
+		r.handles[login] = handle
+	}
+
+	return r.handles[login], nil
+}
+
+func (r *fakeRepository) GetUserByPasskeyHandle(_ context.Context, handle []byte) (userDomain.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for login, h := range r.handles {
+		if bytes.Equal(h, handle) {
+			return r.users[login], nil
+		}
+	}
+
+	return userDomain.User{}, sql.ErrNoRows
+}
+
+// UpdatePasskeyUsage follows repository query: counter must increase unless it is always zero.
+func (r *fakeRepository) UpdatePasskeyUsage(_ context.Context, credentialID []byte, signCount uint32, backupState bool) (bool, error) {
+	if r.beforePasskeyUsage != nil {
+		hook := r.beforePasskeyUsage
+		r.beforePasskeyUsage = nil
+		hook()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for login, passkeys := range r.passkeys {
+		for i, passkey := range passkeys {
+			if !bytes.Equal(passkey.CredentialID, credentialID) {
+				continue
+			}
+			if passkey.SignCount >= signCount && !(passkey.SignCount == 0 && signCount == 0) {
+				return false, nil
+			}
+
+			now := time.Now()
+			r.passkeys[login][i].SignCount = signCount
+			r.passkeys[login][i].BackupState = backupState
+			r.passkeys[login][i].LastUsedAt = &now
+
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *fakeRepository) SavePasskeySession(_ context.Context, session userDomain.PasskeySession) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.sessions[session.SessionHash] = session
+	return nil
+}
+
+func (r *fakeRepository) ConsumePasskeySession(_ context.Context, sessionHash string) (userDomain.PasskeySession, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	session, ok := r.sessions[sessionHash]
+	if !ok {
+		return session, sql.ErrNoRows
+	}
+	delete(r.sessions, sessionHash)
+
+	return session, nil
+}
+
+func (r *fakeRepository) addDocument(doc document.Document) document.Document {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	doc.ID = uuid.New()
+	doc.Version = 1
+	r.documents[doc.ID] = doc
+	r.addVersion(doc, doc.Owner, 0)
+
+	return doc
+}
+
+// addVersion keeps current content of doc as a version dropping ones beyond keepVersions
+// as insertDocumentVersion does. Caller holds the mutex.
+func (r *fakeRepository) addVersion(doc document.Document, author string, keepVersions int) {
+	versions := append(r.versions[doc.ID], document.Version{
+		DocumentID: doc.ID,
+		Number:     doc.Version,
+		Filename:   doc.Filename,
+		IsFile:     doc.IsFile,
+		Mimetype:   doc.Mimetype,
+		JSON:       doc.JSON,
+		File:       doc.File,
+		Author:     author,
+		CreatedAt:  time.Now(),
+	})
+	if keepVersions > 0 {
+		versions = slices.DeleteFunc(versions, func(v document.Version) bool {
+			return v.Number <= doc.Version-keepVersions
+		})
+	}
+	r.versions[doc.ID] = versions
+}
+
+func (r *fakeRepository) GetDocument(_ context.Context, id uuid.UUID) (document.Document, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	doc, ok := r.documents[id]
+	if !ok {
+		return doc, sql.ErrNoRows
+	}
+	doc.Grants = slices.Clone(doc.Grants)
+
+	return doc, nil
+}
+
+// UploadDocument fails on repeated grant target as grant primary key does.
+func (r *fakeRepository) UploadDocument(_ context.Context, login, filename string, isFile bool, mimetype string, isPublic bool, grants []document.Grant, tags []string, json map[string]any, file *bytes.Buffer) (document.Document, error) {
+	err := checkGrantTargets(grants)
+	if err != nil {
+		return document.Document{}, err
+	}
+
+	doc := document.Document{
+		Data: document.Data{
+			Filename: filename,
+			IsPublic: isPublic,
+			IsFile:   isFile,
+			Mimetype: mimetype,
+			Tags:     tags,
+			Owner:    login,
+		},
+		JSON:   json,
+		File:   file.Bytes(),
+		Grants: grants,
+	}
+
+	return r.addDocument(doc), nil
+}
+
+func (r *fakeRepository) UpdateDocument(_ context.Context, id uuid.UUID, author, filename string, isFile bool, mimetype string, isPublic bool, json map[string]any, file *bytes.Buffer, keepVersions int) (document.Document, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	doc, ok := r.documents[id]
+	if !ok {
+		return doc, sql.ErrNoRows
+	}
+	doc.Filename = filename
+	doc.IsFile = isFile
+	doc.Mimetype = mimetype
+	doc.IsPublic = isPublic
+	doc.JSON = json
+	doc.File = file.Bytes()
+	doc.Version++
+	r.documents[id] = doc
+	r.addVersion(doc, author, keepVersions)
+
+	return doc, nil
+}
+
+func (r *fakeRepository) GetDocumentVersions(_ context.Context, id uuid.UUID) ([]document.Version, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	versions := slices.Clone(r.versions[id])
+	slices.Reverse(versions)
+
+	return versions, nil
+}
+
+func (r *fakeRepository) GetDocumentVersion(_ context.Context, id uuid.UUID, number int) (document.Version, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, v := range r.versions[id] {
+		if v.Number == number {
+			return v, nil
+		}
+	}
+
+	return document.Version{}, sql.ErrNoRows
+}
+
+func (r *fakeRepository) RestoreDocumentVersion(_ context.Context, id uuid.UUID, number int, author string, keepVersions int) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	doc, ok := r.documents[id]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+
+	i := slices.IndexFunc(r.versions[id], func(v document.Version) bool { return v.Number == number })
+	if i < 0 {
+		return 0, sql.ErrNoRows
+	}
+	v := r.versions[id][i]
+
+	doc.Filename = v.Filename
+	doc.IsFile = v.IsFile
+	doc.Mimetype = v.Mimetype
+	doc.JSON = v.JSON
+	doc.File = v.File
+	doc.Version++
+	r.documents[id] = doc
+	r.addVersion(doc, author, keepVersions)
+
+	return doc.Version, nil
+}
+
+// AddDocumentGrants fails on repeated grant target as upsert can't affect the same row twice.
+func (r *fakeRepository) AddDocumentGrants(_ context.Context, id uuid.UUID, grants []document.Grant) error {
+	err := checkGrantTargets(grants)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	doc := r.documents[id]
+	for _, grant := range grants {
+		i := slices.IndexFunc(doc.Grants, func(g document.Grant) bool {
+			return g.Login == grant.Login && g.Group == grant.Group
+		})
+		if i >= 0 {
+			doc.Grants[i].Level = grant.Level
+			continue
+		}
+		doc.Grants = append(doc.Grants, grant)
+	}
+	r.documents[id] = doc
+
+	return nil
+}
+
+func checkGrantTargets(grants []document.Grant) error {
+	for i, grant := range grants {
+		for _, other := range grants[:i] {
+			if grant.Login == other.Login && grant.Group == other.Group {
+				return fmt.Errorf("grant target %q%q repeated", grant.Login, grant.Group)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *fakeRepository) IsGroupExists(_ context.Context, name string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, ok := r.groups[name]
+	return ok, nil
+}
+
+func (r *fakeRepository) GetMemberGroups(_ context.Context, login string, groups []string) ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var memberGroups []string
+	for _, name := range groups {
+		if slices.Contains(r.groups[name], login) {
+			memberGroups = append(memberGroups, name)
+		}
+	}
+
+	return memberGroups, nil
+}
+
+func (r *fakeRepository) GetGroup(_ context.Context, name string) (group.Group, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	logins, ok := r.groups[name]
+	if !ok {
+		return group.Group{}, sql.ErrNoRows
+	}
+
+	g := group.Group{Name: name}
+	for _, login := range logins {
+		g.Members = append(g.Members, group.Member{Login: login, IsAdmin: slices.Contains(r.groupAdmins[name], login)})
+	}
+
+	return g, nil
+}
+
+func (r *fakeRepository) SetGroupMember(_ context.Context, name, login string, isAdmin bool) error {
+	r.runBeforeGroupMember()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	admins := r.groupAdmins[name]
+	if !slices.Contains(r.groups[name], login) {
+		r.groups[name] = append(r.groups[name], login)
+	}
+
+	updated := slices.DeleteFunc(slices.Clone(admins), func(admin string) bool { return admin == login })
+	if isAdmin {
+		updated = append(updated, login)
+	}
+	if len(admins) > 0 && len(updated) == 0 {
+		return group.ErrLastAdmin
+	}
+	r.groupAdmins[name] = updated
+
+	return nil
+}
+
+func (r *fakeRepository) RemoveGroupMember(_ context.Context, name, login string) (bool, error) {
+	r.runBeforeGroupMember()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	admins := r.groupAdmins[name]
+	updated := slices.DeleteFunc(slices.Clone(admins), func(admin string) bool { return admin == login })
+	if len(admins) > 0 && len(updated) == 0 {
+		return false, group.ErrLastAdmin
+	}
+	r.groupAdmins[name] = updated
+
+	members := r.groups[name]
+	r.groups[name] = slices.DeleteFunc(slices.Clone(members), func(member string) bool { return member == login })
+
+	return len(r.groups[name]) < len(members), nil
+}
+
+func (r *fakeRepository) runBeforeGroupMember() {
+	if hook := r.beforeGroupMember; hook != nil {
+		r.beforeGroupMember = nil
+		hook()
+	}
+}
+
+func (r *fakeRepository) GetShareLink(_ context.Context, tokenHash string) (document.Link, error) {
+	r.mutex.Lock()
+	link, ok := r.links[tokenHash]
+	r.mutex.Unlock()
+
+	if hook := r.afterGetShareLink; hook != nil {
+		r.afterGetShareLink = nil
+		hook()
+	}
+
+	if !ok {
+		return link, sql.ErrNoRows
+	}
+
+	return link, nil
+}
+
+func (r *fakeRepository) RegisterShareLinkPasswordFailure(_ context.Context, id string, maxFailures int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for hash, link := range r.links {
+		if link.ID == id && link.PasswordFailures < maxFailures {
+			link.PasswordFailures++
+			r.links[hash] = link
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *fakeRepository) UseShareLink(_ context.Context, id string, maxPasswordFailures int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for hash, link := range r.links {
+		if link.ID == id && link.IsActive(time.Now()) && link.PasswordFailures < maxPasswordFailures {
+			link.Downloads++
+			r.links[hash] = link
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *fakeRepository) IsAdminExists(context.Context) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, user := range r.users {
+		if user.IsAdmin() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *fakeRepository) CreateUser(_ context.Context, login, hashedPassword, role string) (userDomain.User, error) {
+	user := r.addUser(userDomain.User{Login: login, Role: role})
+
+	r.mutex.Lock()
+	r.passwords[login] = hashedPassword
+	r.mutex.Unlock()
+
+	return user, nil
+}
+
+// CreateUserWithInviteCode uses invite code up and creates user with its role.
+func (r *fakeRepository) CreateUserWithInviteCode(ctx context.Context, codeHash, login, hashedPassword string) (userDomain.User, error) {
+	r.mutex.Lock()
+	code, ok := r.invites[codeHash]
+	if !ok || !code.IsActive(time.Now()) {
+		r.mutex.Unlock()
+		return userDomain.User{}, invite.ErrInvalidCode
+	}
+	code.Uses++
+	r.invites[codeHash] = code
+	r.mutex.Unlock()
+
+	return r.CreateUser(ctx, login, hashedPassword, code.Role)
+}
+
+func (r *fakeRepository) CreateInviteCode(_ context.Context, codeHash, role string, maxUses int, createdBy *string, expiresAt time.Time) (invite.Code, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	code := invite.Code{
+		ID:        fmt.Sprint(len(r.invites) + 1),
+		Role:      role,
+		MaxUses:   maxUses,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	r.invites[codeHash] = code
+
+	return code, nil
+}
+
+func (r *fakeRepository) RevokeInviteCode(_ context.Context, id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for hash, code := range r.invites {
+		if code.ID == id && code.RevokedAt == nil {
+			now := time.Now()
+			code.RevokedAt = &now
+			r.invites[hash] = code
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *fakeRepository) RevokeBootstrapInviteCodes(context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for hash, code := range r.invites {
+		if code.CreatedBy == nil && code.RevokedAt == nil {
+			now := time.Now()
+			code.RevokedAt = &now
+			r.invites[hash] = code
+		}
+	}
+
+	return nil
+}
+
+func (r *fakeRepository) GetDatabaseTime(context.Context) (time.Time, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.denylistSyncs++
+
+	return time.Now(), nil
+}
+
+func (r *fakeRepository) GetSessionRevocations(_ context.Context, since time.Time) (map[string]time.Time, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	revocations := make(map[string]time.Time)
+	for id, revokedAt := range r.revocations {
+		if revokedAt.After(since) {
+			revocations[id] = revokedAt
+		}
+	}
+
+	return revocations, nil
+}
+
+func (r *fakeRepository) DeleteSessionRevocations(_ context.Context, before time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, revokedAt := range r.revocations {
+		if !revokedAt.After(before) {
+			delete(r.revocations, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *fakeRepository) RevokeUserSessions(_ context.Context, login string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for hash, token := range r.tokens {
+		if r.tokenLogins[hash] == login {
+			r.revocations[token.ID] = time.Now()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,368 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+func (u usecase) DeleteAccount(ctx context.Context, principal userDomain.Principal, request dto.DeleteUserRequest) (dto.APIResponse[*dto.DeleteUserResponse, any], int) {
+	return u.deleteUser(ctx, principal.User, request)
+}
+
+func (u usecase) DeleteUser(ctx context.Context, principal userDomain.Principal, login string, request dto.DeleteUserRequest) (dto.APIResponse[*dto.DeleteUserResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	user, err := u.userRepository.GetUserByLogin(ctx, login)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.UserNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return u.deleteUser(ctx, user, request)
+}
+
+// deleteUser deletes user handing owned documents off according to request mode.
+// In dry run mode only report is returned.
+func (u usecase) deleteUser(ctx context.Context, user userDomain.User, request dto.DeleteUserRequest) (dto.APIResponse[*dto.DeleteUserResponse, any], int) {
+	if !userDomain.IsValidDeletionMode(request.Mode) {
+		return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.BadDeletionModeErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	switch request.Mode {
+	case userDomain.DeletionModeArchive:
+		request.TransferTo = u.archiveLogin
+	case userDomain.DeletionModePurge:
+		request.TransferTo = ""
+	}
+
+	if request.Mode == userDomain.DeletionModeTransfer {
+		exists, err := u.userRepository.IsLoginExists(ctx, request.TransferTo)
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+		if !exists {
+			return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+				Code: apperrors.BadRequestErrorCode,
+				Text: apperrors.BadTransferTargetErrorText,
+			}, nil, nil), http.StatusBadRequest
+		}
+	}
+
+	if request.TransferTo == user.Login {
+		return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.BadTransferTargetErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	// Checked here to be reported on dry run too, deletion checks it again atomically.
+	if user.IsAdmin() && !user.IsDisabled {
+		admins, err := u.userRepository.CountAdmins(ctx)
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+		if admins <= 1 {
+			return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+				Code: apperrors.BadRequestErrorCode,
+				Text: apperrors.LastAdminDeletionErrorText,
+			}, nil, nil), http.StatusBadRequest
+		}
+	}
+
+	report, err := u.userRepository.GetUserDeletionReport(ctx, user.Login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	response := &dto.DeleteUserResponse{
+		Login:           user.Login,
+		Mode:            request.Mode,
+		TransferTo:      request.TransferTo,
+		DryRun:          request.DryRun,
+		OwnedDocuments:  report.OwnedDocuments,
+		SharedDocuments: report.SharedDocuments,
+		ReceivedGrants:  report.ReceivedGrants,
+		Sessions:        report.Sessions,
+	}
+
+	if request.DryRun {
+		return dto.NewAPIResponse[*dto.DeleteUserResponse, any](nil, response, nil), http.StatusOK
+	}
+
+	if request.Mode == userDomain.DeletionModeArchive {
+		err = u.userRepository.EnsureArchiveUser(ctx, u.archiveLogin)
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+	}
+
+	err = u.userRepository.DeleteUser(ctx, user.Login, request.TransferTo)
+	if err != nil && errors.Is(err, userDomain.ErrLastAdmin) {
+		return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.LastAdminDeletionErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.DeleteUserResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.DeleteUserResponse, any](nil, response, nil), http.StatusOK
+}
+
+// EnsureArchiveUser creates archive account on start, so its login can not be registered
+// by anyone. Start fails if the login is already taken by regular account.
+func (u usecase) EnsureArchiveUser(ctx context.Context) error {
+	err := u.userRepository.EnsureArchiveUser(ctx, u.archiveLogin)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+func (u usecase) GetUsers(ctx context.Context, principal userDomain.Principal, request dto.GetUsersRequest) (dto.APIResponse[*dto.GetUsersResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.GetUsersResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	switch {
+	case request.Limit <= 0:
+		request.Limit = 20
+	case request.Limit > 100:
+		request.Limit = 100
+	}
+	if request.Offset < 0 {
+		request.Offset = 0
+	}
+
+	users, total, err := u.userRepository.GetUsers(ctx, request.Search, request.Limit, request.Offset)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.GetUsersResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	usersDTO := dto.NewGetUsersResponse().FromDomain(users, total)
+
+	return dto.NewAPIResponse[*dto.GetUsersResponse, any](nil, &usersDTO, nil), http.StatusOK
+}
+
+// UpdateUser assigns user role and disables or enables account.
+// Disabled user sessions are revoked. Deprecated admin flag is still accepted.
+func (u usecase) UpdateUser(ctx context.Context, principal userDomain.Principal, login string, request dto.UpdateUserRequest) (dto.APIResponse[*dto.UpdateUserResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	user, err := u.userRepository.GetUserByLogin(ctx, login)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.UserNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	if request.Role == nil && request.IsAdmin == nil && request.IsDisabled == nil {
+		return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.EmptyUserUpdateErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	if request.IsAdmin != nil && request.Role != nil && *request.IsAdmin != (*request.Role == userDomain.RoleAdmin) {
+		return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.AdminFlagConflictErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	// Deprecated admin flag is mapped to role, false keeps other roles as is.
+	if request.IsAdmin != nil && request.Role == nil {
+		role := user.Role
+		switch {
+		case *request.IsAdmin:
+			role = userDomain.RoleAdmin
+		case user.IsAdmin():
+			role = u.defaultRole
+		}
+		request.Role = &role
+	}
+
+	if request.Role != nil && !userDomain.IsValidRole(*request.Role) {
+		return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.BadRoleErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	isDemoted := request.Role != nil && *request.Role != userDomain.RoleAdmin
+	isDisabled := request.IsDisabled != nil && *request.IsDisabled
+	if user.IsAdmin() && !user.IsDisabled && (isDemoted || isDisabled) {
+		admins, err := u.userRepository.CountAdmins(ctx)
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+		if admins <= 1 {
+			return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+				Code: apperrors.BadRequestErrorCode,
+				Text: apperrors.LastAdminDemotionErrorText,
+			}, nil, nil), http.StatusBadRequest
+		}
+	}
+
+	// Concurrent demotions may pass the check above, update checks it again atomically.
+	err = u.userRepository.UpdateUserAccess(ctx, login, request.Role, request.IsDisabled)
+	if err != nil && errors.Is(err, userDomain.ErrLastAdmin) {
+		return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.LastAdminDemotionErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	if request.Role != nil && *request.Role != user.Role {
+		user.Role = *request.Role
+
+		// Access tokens carry role, so they are revoked to be refreshed with actual one.
+		if u.accessTokenSigner != nil {
+			err = u.revocationRepository.RevokeUserSessions(ctx, login)
+			if err != nil {
+				logger.Error("repository call error", slog.String("error", err.Error()))
+				return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+					Code: apperrors.RepositoryCallErrorCode,
+					Text: apperrors.InternalErrorText,
+				}, nil, nil), http.StatusInternalServerError
+			}
+		}
+	}
+
+	if request.IsDisabled != nil {
+		user.IsDisabled = *request.IsDisabled
+	}
+
+	if isDisabled {
+		_, err = u.userRepository.DeleteAllUserTokens(ctx, login)
+		if err != nil {
+			logger.Error("repository call error", slog.String("error", err.Error()))
+			return dto.NewAPIResponse[*dto.UpdateUserResponse, any](&dto.Error{
+				Code: apperrors.RepositoryCallErrorCode,
+				Text: apperrors.InternalErrorText,
+			}, nil, nil), http.StatusInternalServerError
+		}
+	}
+
+	return dto.NewAPIResponse[*dto.UpdateUserResponse, any](nil, &dto.UpdateUserResponse{
+		Login:      user.Login,
+		Role:       user.Role,
+		IsAdmin:    user.IsAdmin(),
+		IsDisabled: user.IsDisabled,
+	}, nil), http.StatusOK
+}
+
+// GetRoles lists roles which may be assigned to users along with their permissions.
+func (u usecase) GetRoles(_ context.Context, principal userDomain.Principal) (dto.APIResponse[*dto.GetRolesResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.GetRolesResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	rolesDTO := dto.NewGetRolesResponse().FromDomain(userDomain.Roles)
+
+	return dto.NewAPIResponse[*dto.GetRolesResponse, any](nil, &rolesDTO, nil), http.StatusOK
+}
+
+func (u usecase) LogoutUser(ctx context.Context, principal userDomain.Principal, login string) (dto.APIResponse[*dto.LogoutUserResponse, any], int) {
+	if !principal.HasPermission(userDomain.PermissionUsersManage) {
+		return dto.NewAPIResponse[*dto.LogoutUserResponse, any](&dto.Error{
+			Code: apperrors.ForbiddenErrorCode,
+			Text: apperrors.ForbiddenErrorText,
+		}, nil, nil), http.StatusForbidden
+	}
+
+	revoked, err := u.userRepository.DeleteAllUserTokens(ctx, login)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[*dto.LogoutUserResponse, any](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	return dto.NewAPIResponse[*dto.LogoutUserResponse, any](nil, &dto.LogoutUserResponse{
+		RevokedSessions: revoked,
+	}, nil), http.StatusOK
+}
@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/dto"
+)
+
+func adminPrincipal(user userDomain.User) userDomain.Principal {
+	return userDomain.Principal{User: user}
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func stringPtr(v string) *string {
+	return &v
+}
+
+func TestUpdateUserAdminFlag(t *testing.T) {
+	repo := newFakeRepository()
+	admin := repo.addUser(userDomain.User{Login: "admin", Role: userDomain.RoleAdmin})
+	repo.addUser(userDomain.User{Login: "editor", Role: userDomain.RoleEditor})
+	u := newTestUsecase(repo)
+
+	steps := []struct {
+		name    string
+		request dto.UpdateUserRequest
+		status  int
+		want    string
+	}{
+		{name: "admin flag promotes", request: dto.UpdateUserRequest{IsAdmin: boolPtr(true)}, status: http.StatusOK, want: userDomain.RoleAdmin},
+		{name: "admin flag demotes to default role", request: dto.UpdateUserRequest{IsAdmin: boolPtr(false)}, status: http.StatusOK, want: userDomain.RoleViewer},
+		{name: "admin flag keeps other role", request: dto.UpdateUserRequest{IsAdmin: boolPtr(false)}, status: http.StatusOK, want: userDomain.RoleViewer},
+		{name: "admin flag with matching role", request: dto.UpdateUserRequest{IsAdmin: boolPtr(false), Role: stringPtr(userDomain.RoleAuditor)}, status: http.StatusOK, want: userDomain.RoleAuditor},
+		{name: "admin flag conflicts with role", request: dto.UpdateUserRequest{IsAdmin: boolPtr(true), Role: stringPtr(userDomain.RoleAuditor)}, status: http.StatusBadRequest, want: userDomain.RoleAuditor},
+		{name: "nothing to update", request: dto.UpdateUserRequest{}, status: http.StatusBadRequest, want: userDomain.RoleAuditor},
+	}
+
+	for _, step := range steps {
+		_, status := u.UpdateUser(context.Background(), adminPrincipal(admin), "editor", step.request)
+		if status != step.status {
+			t.Fatalf("%s: UpdateUser status = %d, want %d", step.name, status, step.status)
+		}
+
+		user, _ := repo.GetUserByLogin(context.Background(), "editor")
+		if user.Role != step.want {
+			t.Fatalf("%s: role = %q, want %q", step.name, user.Role, step.want)
+		}
+	}
+}
+
+func TestUpdateUserLastAdmin(t *testing.T) {
+	repo := newFakeRepository()
+	admin := repo.addUser(userDomain.User{Login: "admin", Role: userDomain.RoleAdmin})
+	repo.addUser(userDomain.User{Login: "disabled", Role: userDomain.RoleAdmin, IsDisabled: true})
+	u := newTestUsecase(repo)
+
+	// Disabled admin can't manage users, so admin is the last one.
+	for _, request := range []dto.UpdateUserRequest{
+		{Role: stringPtr(userDomain.RoleEditor)},
+		{IsAdmin: boolPtr(false)},
+		{IsDisabled: boolPtr(true)},
+	} {
+		_, status := u.UpdateUser(context.Background(), adminPrincipal(admin), "admin", request)
+		if status != http.StatusBadRequest {
+			t.Fatalf("UpdateUser status = %d, want %d", status, http.StatusBadRequest)
+		}
+	}
+
+	// Disabled admin may be demoted while enabled one is left.
+	_, status := u.UpdateUser(context.Background(), adminPrincipal(admin), "disabled", dto.UpdateUserRequest{Role: stringPtr(userDomain.RoleViewer)})
+	if status != http.StatusOK {
+		t.Fatalf("UpdateUser of disabled admin status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+// Both demotions pass the count check while two admins are enabled, the latter must be
+// rejected by the update itself.
+func TestUpdateUserConcurrentDemotion(t *testing.T) {
+	repo := newFakeRepository()
+	first := repo.addUser(userDomain.User{Login: "first", Role: userDomain.RoleAdmin})
+	second := repo.addUser(userDomain.User{Login: "second", Role: userDomain.RoleAdmin})
+	u := newTestUsecase(repo)
+
+	var secondStatus int
+	repo.beforeUserAccess = func() {
+		_, secondStatus = u.UpdateUser(context.Background(), adminPrincipal(first), "second", dto.UpdateUserRequest{IsDisabled: boolPtr(true)})
+	}
+
+	_, status := u.UpdateUser(context.Background(), adminPrincipal(second), "first", dto.UpdateUserRequest{Role: stringPtr(userDomain.RoleEditor)})
+	if secondStatus != http.StatusOK {
+		t.Fatalf("concurrent UpdateUser status = %d, want %d", secondStatus, http.StatusOK)
+	}
+	if status != http.StatusBadRequest {
+		t.Fatalf("UpdateUser status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if admins, _ := repo.CountAdmins(context.Background()); admins != 1 {
+		t.Fatalf("enabled admins = %d, want 1", admins)
+	}
+}
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/srgklmv/astral/internal/domain/document"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/apperrors"
+	"github.com/srgklmv/astral/internal/models/dto"
+	"github.com/srgklmv/astral/pkg/logger"
+)
+
+// GetDocumentVersions lists kept versions of document from the newest one.
+func (u usecase) GetDocumentVersions(ctx context.Context, user userDomain.Principal, id string) (dto.APIResponse[any, *dto.GetDocumentVersionsResponse], int) {
+	doc, response, status := u.authorizeDocument(ctx, user, id, readDocument)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.GetDocumentVersionsResponse](response, nil, nil), status
+	}
+
+	versions, err := u.documentRepository.GetDocumentVersions(ctx, doc.ID)
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[any, *dto.GetDocumentVersionsResponse](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	versionsDTO := dto.NewGetDocumentVersionsResponse().FromDomain(versions, doc.Version)
+
+	return dto.NewAPIResponse[any, *dto.GetDocumentVersionsResponse](nil, nil, &versionsDTO), http.StatusOK
+}
+
+// RestoreDocumentVersion makes content of old version current by saving it as a new version.
+func (u usecase) RestoreDocumentVersion(ctx context.Context, user userDomain.Principal, id, version string) (dto.APIResponse[any, *dto.DocumentVersion], int) {
+	doc, response, status := u.authorizeDocument(ctx, user, id, writeDocument)
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.DocumentVersion](response, nil, nil), status
+	}
+
+	number, err := strconv.Atoi(version)
+	if err != nil || number <= 0 {
+		return dto.NewAPIResponse[any, *dto.DocumentVersion](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.BadVersionProvidedErrorText,
+		}, nil, nil), http.StatusBadRequest
+	}
+
+	number, err = u.documentRepository.RestoreDocumentVersion(ctx, doc.ID, number, user.Login, u.versionRetention)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return dto.NewAPIResponse[any, *dto.DocumentVersion](&dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.VersionNotFoundErrorText,
+		}, nil, nil), http.StatusNotFound
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return dto.NewAPIResponse[any, *dto.DocumentVersion](&dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, nil, nil), http.StatusInternalServerError
+	}
+
+	v, response, status := u.getDocumentVersion(ctx, doc.ID, strconv.Itoa(number))
+	if status != http.StatusOK {
+		return dto.NewAPIResponse[any, *dto.DocumentVersion](response, nil, nil), status
+	}
+
+	versionDTO := dto.NewDocumentVersion(v, number)
+
+	return dto.NewAPIResponse[any, *dto.DocumentVersion](nil, nil, &versionDTO), http.StatusOK
+}
+
+// getDocumentVersion loads version by its number returning error response with status
+// other than 200 on failure.
+func (u usecase) getDocumentVersion(ctx context.Context, id uuid.UUID, version string) (document.Version, *dto.Error, int) {
+	number, err := strconv.Atoi(version)
+	if err != nil || number <= 0 {
+		return document.Version{}, &dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.BadVersionProvidedErrorText,
+		}, http.StatusBadRequest
+	}
+
+	v, err := u.documentRepository.GetDocumentVersion(ctx, id, number)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return v, &dto.Error{
+			Code: apperrors.BadRequestErrorCode,
+			Text: apperrors.VersionNotFoundErrorText,
+		}, http.StatusNotFound
+	}
+	if err != nil {
+		logger.Error("repository call error", slog.String("error", err.Error()))
+		return v, &dto.Error{
+			Code: apperrors.RepositoryCallErrorCode,
+			Text: apperrors.InternalErrorText,
+		}, http.StatusInternalServerError
+	}
+
+	return v, nil, http.StatusOK
+}
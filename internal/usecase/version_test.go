@@ -0,0 +1,175 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/srgklmv/astral/internal/domain/document"
+	userDomain "github.com/srgklmv/astral/internal/domain/user"
+	"github.com/srgklmv/astral/internal/models/dto"
+)
+
+// newVersionTestUsecase returns usecase with file document owned by owner and writable by writer.
+func newVersionTestUsecase(t *testing.T) (usecase, *fakeRepository, document.Document, userDomain.User) {
+	t.Helper()
+
+	repo := newFakeRepository()
+	owner := repo.addUser(userDomain.User{Login: "owner", Role: userDomain.RoleEditor})
+	writer := repo.addUser(userDomain.User{Login: "writer", Role: userDomain.RoleEditor})
+	doc := repo.addDocument(document.Document{
+		Data:   document.Data{Owner: owner.Login, Filename: "report.pdf", IsFile: true, Mimetype: "application/pdf"},
+		File:   []byte("%PDF-1"),
+		Grants: []document.Grant{{Login: writer.Login, Level: document.LevelWrite}},
+	})
+
+	return newTestUsecase(repo), repo, doc, writer
+}
+
+func updateFile(t *testing.T, u usecase, user userDomain.User, doc document.Document, content string) {
+	t.Helper()
+
+	_, status := u.UpdateDocument(context.Background(), userDomain.Principal{User: user}, doc.ID.String(), dto.DocumentMetadata{
+		Name:     doc.Filename,
+		IsFile:   true,
+		Mimetype: doc.Mimetype,
+	}, nil, bytes.NewBufferString(content))
+	if status != http.StatusOK {
+		t.Fatalf("UpdateDocument status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func versionNumbers(t *testing.T, u usecase, user userDomain.User, doc document.Document) []int {
+	t.Helper()
+
+	result, status := u.GetDocumentVersions(context.Background(), userDomain.Principal{User: user}, doc.ID.String())
+	if status != http.StatusOK {
+		t.Fatalf("GetDocumentVersions status = %d, want %d", status, http.StatusOK)
+	}
+
+	var numbers []int
+	for _, v := range result.Data.Versions {
+		numbers = append(numbers, v.Version)
+	}
+
+	return numbers
+}
+
+func TestDocumentVersionRetention(t *testing.T) {
+	u, _, doc, writer := newVersionTestUsecase(t)
+	u.versionRetention = 2
+
+	for _, content := range []string{"%PDF-2", "%PDF-3", "%PDF-4"} {
+		updateFile(t, u, writer, doc, content)
+	}
+
+	numbers := versionNumbers(t, u, writer, doc)
+	if len(numbers) != 2 || numbers[0] != 4 || numbers[1] != 3 {
+		t.Fatalf("versions = %v, want [4 3]", numbers)
+	}
+
+	result, _ := u.GetDocumentVersions(context.Background(), userDomain.Principal{User: writer}, doc.ID.String())
+	if v := result.Data.Versions[0]; !v.IsCurrent || v.Author != writer.Login {
+		t.Fatalf("newest version = %+v, want current one authored by %s", v, writer.Login)
+	}
+
+	_, _, _, status := u.GetDocument(context.Background(), userDomain.Principal{User: writer}, doc.ID.String(), "1")
+	if status != http.StatusNotFound {
+		t.Fatalf("GetDocument of dropped version status = %d, want %d", status, http.StatusNotFound)
+	}
+
+	_, file, _, status := u.GetDocument(context.Background(), userDomain.Principal{User: writer}, doc.ID.String(), "3")
+	if status != http.StatusOK || string(file) != "%PDF-3" {
+		t.Fatalf("GetDocument of kept version = %q, %d, want %q", file, status, "%PDF-3")
+	}
+}
+
+func TestDocumentVersionRetentionDisabled(t *testing.T) {
+	u, _, doc, writer := newVersionTestUsecase(t)
+
+	for _, content := range []string{"%PDF-2", "%PDF-3", "%PDF-4"} {
+		updateFile(t, u, writer, doc, content)
+	}
+
+	if numbers := versionNumbers(t, u, writer, doc); len(numbers) != 4 {
+		t.Fatalf("versions = %v, want all 4 kept", numbers)
+	}
+}
+
+func TestRestoreDocumentVersion(t *testing.T) {
+	u, repo, doc, writer := newVersionTestUsecase(t)
+	updateFile(t, u, writer, doc, "%PDF-2")
+
+	result, status := u.RestoreDocumentVersion(context.Background(), userDomain.Principal{User: writer}, doc.ID.String(), "1")
+	if status != http.StatusOK {
+		t.Fatalf("RestoreDocumentVersion status = %d, want %d", status, http.StatusOK)
+	}
+	if result.Data.Version != 3 || !result.Data.IsCurrent || result.Data.Author != writer.Login {
+		t.Fatalf("restored version = %+v, want current version 3 authored by %s", result.Data, writer.Login)
+	}
+
+	_, file, _, status := u.GetDocument(context.Background(), userDomain.Principal{User: writer}, doc.ID.String(), "")
+	if status != http.StatusOK || string(file) != "%PDF-1" {
+		t.Fatalf("GetDocument after restore = %q, %d, want %q", file, status, "%PDF-1")
+	}
+
+	// History is not rewritten, replaced content is still kept.
+	if numbers := versionNumbers(t, u, writer, doc); len(numbers) != 3 {
+		t.Fatalf("versions = %v, want [3 2 1]", numbers)
+	}
+
+	stored, _ := repo.GetDocument(context.Background(), doc.ID)
+	if stored.Version != 3 {
+		t.Fatalf("document version = %d, want 3", stored.Version)
+	}
+}
+
+func TestRestoreDocumentVersionRetention(t *testing.T) {
+	u, _, doc, writer := newVersionTestUsecase(t)
+	u.versionRetention = 2
+	updateFile(t, u, writer, doc, "%PDF-2")
+
+	_, status := u.RestoreDocumentVersion(context.Background(), userDomain.Principal{User: writer}, doc.ID.String(), "1")
+	if status != http.StatusOK {
+		t.Fatalf("RestoreDocumentVersion status = %d, want %d", status, http.StatusOK)
+	}
+
+	if numbers := versionNumbers(t, u, writer, doc); len(numbers) != 2 || numbers[0] != 3 || numbers[1] != 2 {
+		t.Fatalf("versions = %v, want [3 2]", numbers)
+	}
+
+	_, status = u.RestoreDocumentVersion(context.Background(), userDomain.Principal{User: writer}, doc.ID.String(), "1")
+	if status != http.StatusNotFound {
+		t.Fatalf("RestoreDocumentVersion of dropped version status = %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestRestoreDocumentVersionErrors(t *testing.T) {
+	u, repo, doc, _ := newVersionTestUsecase(t)
+	reader := repo.addUser(userDomain.User{Login: "reader", Role: userDomain.RoleEditor})
+	_ = repo.AddDocumentGrants(context.Background(), doc.ID, []document.Grant{{Login: reader.Login, Level: document.LevelRead}})
+	owner := userDomain.Principal{User: repo.users["owner"]}
+
+	for _, test := range []struct {
+		name    string
+		user    userDomain.Principal
+		version string
+		status  int
+	}{
+		{name: "zero version", user: owner, version: "0", status: http.StatusBadRequest},
+		{name: "not a number", user: owner, version: "latest", status: http.StatusBadRequest},
+		{name: "missing version", user: owner, version: "7", status: http.StatusNotFound},
+		{name: "read level", user: userDomain.Principal{User: reader}, version: "1", status: http.StatusForbidden},
+	} {
+		_, status := u.RestoreDocumentVersion(context.Background(), test.user, doc.ID.String(), test.version)
+		if status != test.status {
+			t.Fatalf("%s: RestoreDocumentVersion status = %d, want %d", test.name, status, test.status)
+		}
+	}
+
+	stored, _ := repo.GetDocument(context.Background(), doc.ID)
+	if stored.Version != 1 {
+		t.Fatalf("document version = %d, want 1", stored.Version)
+	}
+}
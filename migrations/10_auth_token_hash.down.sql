@@ -0,0 +1,3 @@
+DELETE FROM auth_token;
+
+ALTER TABLE auth_token RENAME COLUMN token_hash TO token;
@@ -0,0 +1,4 @@
+-- Plaintext tokens can not be hashed by server pepper here, so all sessions are invalidated.
+DELETE FROM auth_token;
+
+ALTER TABLE auth_token RENAME COLUMN token TO token_hash;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS invite_code;
+
+INSERT INTO secrets(name, value) VALUES ('admin_token', 'test') ON CONFLICT (name) DO NOTHING;
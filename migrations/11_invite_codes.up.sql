@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS invite_code (
+    id VARCHAR PRIMARY KEY DEFAULT gen_random_uuid()::varchar,
+    code_hash VARCHAR(255) NOT NULL UNIQUE,
+    role VARCHAR(50) NOT NULL,
+    max_uses INTEGER NOT NULL DEFAULT 1,
+    uses INTEGER NOT NULL DEFAULT 0,
+    created_by VARCHAR(255) REFERENCES "user"(login) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+DELETE FROM secrets WHERE name = 'admin_token';
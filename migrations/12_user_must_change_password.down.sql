@@ -0,0 +1 @@
+ALTER TABLE "user" DROP COLUMN IF EXISTS must_change_password;
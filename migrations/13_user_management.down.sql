@@ -0,0 +1,3 @@
+ALTER TABLE "user" DROP COLUMN IF EXISTS last_login_at;
+
+ALTER TABLE "user" DROP COLUMN IF EXISTS is_disabled;
@@ -0,0 +1,3 @@
+ALTER TABLE "user" ADD COLUMN is_disabled BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE "user" ADD COLUMN last_login_at TIMESTAMPTZ;
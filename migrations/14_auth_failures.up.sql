@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS auth_failure (
+    kind VARCHAR(10) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMPTZ,
+    PRIMARY KEY (kind, key)
+);
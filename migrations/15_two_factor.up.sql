@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_login VARCHAR(255) PRIMARY KEY REFERENCES "user"(login) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    confirmed_at TIMESTAMPTZ,
+    last_used_step BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_code (
+    user_login VARCHAR(255) NOT NULL REFERENCES user_totp(user_login) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ,
+    PRIMARY KEY (user_login, code_hash)
+);
+
+CREATE TABLE IF NOT EXISTS auth_challenge (
+    challenge_hash VARCHAR(64) PRIMARY KEY,
+    user_login VARCHAR(255) NOT NULL REFERENCES "user"(login) ON DELETE CASCADE,
+    device VARCHAR(255) NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL
+);
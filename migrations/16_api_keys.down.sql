@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS api_key;
+
+ALTER TABLE document DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,16 @@
+ALTER TABLE document ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS api_key (
+    id VARCHAR PRIMARY KEY DEFAULT gen_random_uuid()::varchar,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    user_login VARCHAR(255) NOT NULL REFERENCES "user"(login) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    scopes TEXT[] NOT NULL,
+    document_ids TEXT[] NOT NULL DEFAULT '{}',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS api_key_user_login_idx ON api_key(user_login);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS auth_token_revoke_session ON auth_token;
+DROP FUNCTION IF EXISTS revoke_deleted_session();
+DROP TABLE IF EXISTS session_revocation;
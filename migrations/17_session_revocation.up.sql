@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS session_revocation (
+    session_id VARCHAR PRIMARY KEY,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS session_revocation_revoked_at_idx ON session_revocation(revoked_at);
+
+-- Deleted sessions are revoked, so access tokens issued for them are rejected
+-- without looking sessions up.
+CREATE OR REPLACE FUNCTION revoke_deleted_session() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO session_revocation(session_id, revoked_at) VALUES (OLD.id, clock_timestamp())
+    ON CONFLICT (session_id) DO UPDATE SET revoked_at = excluded.revoked_at;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS auth_token_revoke_session ON auth_token;
+CREATE TRIGGER auth_token_revoke_session AFTER DELETE ON auth_token
+    FOR EACH ROW EXECUTE FUNCTION revoke_deleted_session();
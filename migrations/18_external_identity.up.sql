@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS user_identity (
+    provider VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_login VARCHAR(255) NOT NULL REFERENCES "user"(login) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identity_user_login_idx ON user_identity(user_login);
+
+CREATE TABLE IF NOT EXISTS oidc_state (
+    state_hash VARCHAR(64) PRIMARY KEY,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    device VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL
+);
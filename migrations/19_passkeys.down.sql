@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS passkey_session;
+
+DROP TABLE IF EXISTS passkey;
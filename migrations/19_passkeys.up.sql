@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS passkey (
+    id VARCHAR PRIMARY KEY DEFAULT gen_random_uuid()::varchar,
+    credential_id BYTEA NOT NULL UNIQUE,
+    user_login VARCHAR(255) NOT NULL REFERENCES "user"(login) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    public_key BYTEA NOT NULL,
+    attestation_type VARCHAR(64) NOT NULL DEFAULT '',
+    transports TEXT[] NOT NULL DEFAULT '{}',
+    aaguid BYTEA,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    backup_eligible BOOLEAN NOT NULL DEFAULT false,
+    backup_state BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS passkey_user_login_idx ON passkey(user_login);
+
+CREATE TABLE IF NOT EXISTS passkey_session (
+    session_hash VARCHAR(64) PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL,
+    user_login VARCHAR(255) REFERENCES "user"(login) ON DELETE CASCADE,
+    data BYTEA NOT NULL,
+    device VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,5 @@
+UPDATE invite_code SET role = 'user' WHERE role <> 'admin';
+
+ALTER TABLE "user" ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+UPDATE "user" SET is_admin = true WHERE role = 'admin';
+ALTER TABLE "user" DROP COLUMN role;
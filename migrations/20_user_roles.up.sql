@@ -0,0 +1,5 @@
+ALTER TABLE "user" ADD COLUMN role VARCHAR(50) NOT NULL DEFAULT 'editor';
+UPDATE "user" SET role = 'admin' WHERE is_admin;
+ALTER TABLE "user" DROP COLUMN is_admin;
+
+UPDATE invite_code SET role = 'editor' WHERE role = 'user';
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS group_document_access;
+
+DROP TABLE IF EXISTS user_group_member;
+
+DROP TABLE IF EXISTS user_group;
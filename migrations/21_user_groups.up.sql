@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS user_group (
+    name VARCHAR(64) PRIMARY KEY,
+    created_by VARCHAR(255) REFERENCES "user"(login) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_group_member (
+    group_name VARCHAR(64) NOT NULL REFERENCES user_group(name) ON DELETE CASCADE,
+    user_login VARCHAR(255) NOT NULL REFERENCES "user"(login) ON DELETE CASCADE,
+    is_admin BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (group_name, user_login)
+);
+
+CREATE INDEX IF NOT EXISTS user_group_member_user_login_idx ON user_group_member(user_login);
+
+CREATE TABLE IF NOT EXISTS group_document_access (
+    group_name VARCHAR(64) NOT NULL REFERENCES user_group(name) ON DELETE CASCADE,
+    document_id VARCHAR NOT NULL REFERENCES document(id) ON DELETE CASCADE,
+    PRIMARY KEY (group_name, document_id)
+);
+
+CREATE INDEX IF NOT EXISTS group_document_access_document_id_idx ON group_document_access(document_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS document_version;
+
+DROP FUNCTION IF EXISTS document_version_immutable();
+
+ALTER TABLE document DROP COLUMN IF EXISTS version;
@@ -0,0 +1,34 @@
+ALTER TABLE document ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- Author is kept as plain login, so history survives user deletion.
+CREATE TABLE IF NOT EXISTS document_version (
+    document_id VARCHAR NOT NULL REFERENCES document(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    is_file BOOLEAN NOT NULL,
+    mimetype VARCHAR(100),
+    json JSONB,
+    file BYTEA,
+    size BIGINT NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    author_login VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (document_id, version)
+);
+
+CREATE OR REPLACE FUNCTION document_version_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'document versions are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER document_version_immutable
+    BEFORE UPDATE ON document_version
+    FOR EACH ROW EXECUTE FUNCTION document_version_immutable();
+
+INSERT INTO document_version (document_id, version, name, is_file, mimetype, json, file, size, checksum, author_login, created_at)
+SELECT d.id, d.version, d.name, d.is_file, d.mimetype, d.json, d.file,
+    octet_length(c.content), encode(sha256(c.content), 'hex'), d.owner_login, coalesce(d.created_at, CURRENT_TIMESTAMP)
+FROM document d,
+    LATERAL (SELECT CASE WHEN d.is_file THEN coalesce(d.file, ''::BYTEA) ELSE convert_to(coalesce(d.json::TEXT, ''), 'UTF8') END AS content) c
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+ALTER TABLE group_document_access DROP COLUMN IF EXISTS level;
+
+ALTER TABLE user_document_access DROP COLUMN IF EXISTS level;
+
+ALTER TABLE user_document_access DROP CONSTRAINT IF EXISTS user_document_access_pkey;
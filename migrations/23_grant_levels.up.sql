@@ -0,0 +1,12 @@
+-- Grants lost primary key with user_id column, duplicates are dropped to restore it.
+DELETE FROM user_document_access a
+USING user_document_access b
+WHERE a.ctid < b.ctid AND a.user_login = b.user_login AND a.document_id = b.document_id;
+
+ALTER TABLE user_document_access ADD CONSTRAINT user_document_access_pkey PRIMARY KEY (user_login, document_id);
+
+ALTER TABLE user_document_access ADD COLUMN IF NOT EXISTS level VARCHAR(16) NOT NULL DEFAULT 'read'
+    CHECK (level IN ('read', 'comment', 'write', 'delete', 'share'));
+
+ALTER TABLE group_document_access ADD COLUMN IF NOT EXISTS level VARCHAR(16) NOT NULL DEFAULT 'read'
+    CHECK (level IN ('read', 'comment', 'write', 'delete', 'share'));
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS share_link (
+    id VARCHAR PRIMARY KEY DEFAULT gen_random_uuid()::varchar,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    document_id VARCHAR NOT NULL REFERENCES document(id) ON DELETE CASCADE,
+    created_by VARCHAR(255) NOT NULL REFERENCES "user"(login) ON DELETE CASCADE,
+    password VARCHAR(255),
+    max_downloads INTEGER CHECK (max_downloads > 0),
+    downloads INTEGER NOT NULL DEFAULT 0,
+    password_failures INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS share_link_document_id_idx ON share_link(document_id);
@@ -0,0 +1,4 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS is_system BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Archive accounts were created with unusable password marker only.
+UPDATE "user" SET is_system = TRUE WHERE password = '!';
@@ -0,0 +1,5 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS passkey_handle BYTEA UNIQUE;
+
+-- Authenticators return handle they got on registration, so users with passkeys keep
+-- handles made of user ID. Others get random handle with their first passkey.
+UPDATE "user" SET passkey_handle = int8send(id::bigint) WHERE login IN (SELECT user_login FROM passkey);
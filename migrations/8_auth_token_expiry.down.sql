@@ -0,0 +1,5 @@
+ALTER TABLE auth_token DROP COLUMN IF EXISTS last_used_at;
+
+ALTER TABLE auth_token DROP COLUMN IF EXISTS expires_at;
+
+ALTER TABLE auth_token DROP COLUMN IF EXISTS created_at;
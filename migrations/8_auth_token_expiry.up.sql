@@ -0,0 +1,7 @@
+ALTER TABLE auth_token ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE auth_token ADD COLUMN expires_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP + INTERVAL '1 day';
+
+ALTER TABLE auth_token ADD COLUMN last_used_at TIMESTAMPTZ;
+
+ALTER TABLE auth_token ALTER COLUMN expires_at DROP DEFAULT;
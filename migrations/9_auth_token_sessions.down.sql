@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS auth_token_user_login_idx;
+
+ALTER TABLE auth_token DROP COLUMN IF EXISTS ip;
+
+ALTER TABLE auth_token DROP COLUMN IF EXISTS user_agent;
+
+ALTER TABLE auth_token DROP COLUMN IF EXISTS device;
+
+ALTER TABLE auth_token DROP COLUMN IF EXISTS id;
@@ -0,0 +1,9 @@
+ALTER TABLE auth_token ADD COLUMN id VARCHAR NOT NULL UNIQUE DEFAULT gen_random_uuid()::varchar;
+
+ALTER TABLE auth_token ADD COLUMN device VARCHAR(255) NOT NULL DEFAULT '';
+
+ALTER TABLE auth_token ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE auth_token ADD COLUMN ip VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS auth_token_user_login_idx ON auth_token(user_login);
@@ -0,0 +1,74 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const (
+	// MergePatch is a JSON Merge Patch media type, RFC 7396.
+	MergePatch = "application/merge-patch+json"
+	// JSONPatch is a JSON Patch media type, RFC 6902.
+	JSONPatch = "application/json-patch+json"
+)
+
+// copySizeLimit caps document growth caused by copy operations of single patch.
+const copySizeLimit = 10 << 20
+
+var (
+	ErrUnsupportedType = errors.New("unsupported patch media type")
+	ErrInvalidPatch    = errors.New("invalid patch")
+	// ErrPatchFailed is returned if patch is valid but can not be applied to document,
+	// like on failed test operation or missing path.
+	ErrPatchFailed = errors.New("patch can not be applied")
+)
+
+// Patch is a decoded patch ready to be applied to documents.
+type Patch func(doc []byte) ([]byte, error)
+
+// Decode parses patch of given Content-Type. Media type parameters like charset are ignored.
+func Decode(contentType string, patch []byte) (Patch, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	switch mediaType {
+	case MergePatch:
+		if !json.Valid(patch) {
+			return nil, ErrInvalidPatch
+		}
+
+		return func(doc []byte) ([]byte, error) {
+			patched, err := jsonpatch.MergePatch(doc, patch)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrPatchFailed, err)
+			}
+
+			return patched, nil
+		}, nil
+	case JSONPatch:
+		decoded, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+		}
+
+		options := jsonpatch.NewApplyOptions()
+		options.AccumulatedCopySizeLimit = copySizeLimit
+
+		return func(doc []byte) ([]byte, error) {
+			patched, err := decoded.ApplyWithOptions(doc, options)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrPatchFailed, err)
+			}
+
+			return patched, nil
+		}, nil
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
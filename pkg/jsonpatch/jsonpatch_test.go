@@ -0,0 +1,115 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func apply(t *testing.T, contentType, patch, doc string) (map[string]any, error) {
+	t.Helper()
+
+	p, err := Decode(contentType, []byte(patch))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	patched, err := p([]byte(doc))
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]any
+	err = json.Unmarshal(patched, &result)
+	if err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+
+	return result, nil
+}
+
+func TestMergePatch(t *testing.T) {
+	result, err := apply(t, MergePatch, `{"a":null,"b":{"c":2},"d":"new"}`, `{"a":1,"b":{"c":1,"e":3}}`)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	want := map[string]any{"b": map[string]any{"c": 2.0, "e": 3.0}, "d": "new"}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("got %v, want %v", result, want)
+	}
+}
+
+func TestMergePatchInvalid(t *testing.T) {
+	_, err := Decode(MergePatch, []byte(`{"a":`))
+	if !errors.Is(err, ErrInvalidPatch) {
+		t.Fatalf("got %v, want ErrInvalidPatch", err)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	patch := `[
+		{"op":"test","path":"/a","value":1},
+		{"op":"add","path":"/list/-","value":"c"},
+		{"op":"remove","path":"/a"}
+	]`
+
+	result, err := apply(t, JSONPatch, patch, `{"a":1,"list":["b"]}`)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	want := map[string]any{"list": []any{"b", "c"}}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("got %v, want %v", result, want)
+	}
+}
+
+func TestJSONPatchTestFailed(t *testing.T) {
+	patch := `[{"op":"test","path":"/a","value":2},{"op":"remove","path":"/a"}]`
+
+	_, err := apply(t, JSONPatch, patch, `{"a":1}`)
+	if !errors.Is(err, ErrPatchFailed) {
+		t.Fatalf("got %v, want ErrPatchFailed", err)
+	}
+}
+
+func TestJSONPatchMissingPath(t *testing.T) {
+	_, err := apply(t, JSONPatch, `[{"op":"remove","path":"/missing"}]`, `{"a":1}`)
+	if !errors.Is(err, ErrPatchFailed) {
+		t.Fatalf("got %v, want ErrPatchFailed", err)
+	}
+}
+
+func TestJSONPatchInvalid(t *testing.T) {
+	_, err := Decode(JSONPatch, []byte(`{"op":"add"}`))
+	if !errors.Is(err, ErrInvalidPatch) {
+		t.Fatalf("got %v, want ErrInvalidPatch", err)
+	}
+}
+
+func TestDecodeUnsupportedType(t *testing.T) {
+	for _, contentType := range []string{"application/json", "text/plain", "", "application/merge-patch+json; ="} {
+		_, err := Decode(contentType, []byte(`{}`))
+		if !errors.Is(err, ErrUnsupportedType) {
+			t.Fatalf("%q: got %v, want ErrUnsupportedType", contentType, err)
+		}
+	}
+}
+
+func TestDecodeCharset(t *testing.T) {
+	result, err := apply(t, "application/merge-patch+json; charset=utf-8", `{"a":2}`, `{"a":1}`)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	if result["a"] != 2.0 {
+		t.Fatalf("got %v, want a=2", result)
+	}
+
+	_, err = apply(t, "Application/JSON-Patch+JSON;charset=UTF-8", `[{"op":"replace","path":"/a","value":3}]`, `{"a":1}`)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+type Config struct {
+	// URL is a server address like ldap://host:389 or ldaps://host:636.
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	// BindDN and BindPassword are service account credentials to search users.
+	// Anonymous bind is used if BindDN is empty.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter is a user search filter with %s placeholder for escaped login, like (uid=%s).
+	UserFilter     string
+	LoginAttribute string
+	// GroupAttribute is a user attribute listing groups, like memberOf.
+	// It is used if GroupBaseDN is empty.
+	GroupAttribute string
+	// GroupBaseDN enables groups search with GroupFilter, which has %s placeholder
+	// for escaped user DN. Groups are named by GroupNameAttribute.
+	GroupBaseDN        string
+	GroupFilter        string
+	GroupNameAttribute string
+	Timeout            time.Duration
+}
+
+// Entry is an authenticated directory user.
+type Entry struct {
+	DN     string
+	Login  string
+	Groups []string
+}
+
+// Client authenticates users by search and simple bind. Each call uses its own connection.
+type Client struct {
+	cfg Config
+}
+
+func New(cfg Config) *Client {
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(uid=%s)"
+	}
+	if cfg.LoginAttribute == "" {
+		cfg.LoginAttribute = "uid"
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "memberOf"
+	}
+	if cfg.GroupFilter == "" {
+		cfg.GroupFilter = "(member=%s)"
+	}
+	if cfg.GroupNameAttribute == "" {
+		cfg.GroupNameAttribute = "cn"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second * 10
+	}
+
+	return &Client{cfg: cfg}
+}
+
+// Authenticate finds user by login and binds as it with password.
+// ErrInvalidCredentials is returned if user is not found or password is wrong.
+func (c *Client) Authenticate(login, password string) (Entry, error) {
+	var entry Entry
+
+	// Empty password makes unauthenticated bind which succeeds on many servers.
+	if login == "" || password == "" {
+		return entry, ErrInvalidCredentials
+	}
+
+	conn, err := c.dial()
+	if err != nil {
+		return entry, err
+	}
+	defer conn.Close()
+
+	if c.cfg.BindDN != "" {
+		err = conn.Bind(c.cfg.BindDN, c.cfg.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		return entry, fmt.Errorf("service bind: %w", err)
+	}
+
+	attributes := []string{c.cfg.LoginAttribute}
+	if c.cfg.GroupBaseDN == "" {
+		attributes = append(attributes, c.cfg.GroupAttribute)
+	}
+
+	result, err := conn.Search(goldap.NewSearchRequest(
+		c.cfg.BaseDN,
+		goldap.ScopeWholeSubtree,
+		goldap.NeverDerefAliases,
+		2,
+		int(c.cfg.Timeout.Seconds()),
+		false,
+		fmt.Sprintf(c.cfg.UserFilter, goldap.EscapeFilter(login)),
+		attributes,
+		nil,
+	))
+	if err != nil && !goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded) {
+		return entry, fmt.Errorf("user search: %w", err)
+	}
+	// Ambiguous login is rejected as well as unknown one.
+	if result == nil || len(result.Entries) != 1 {
+		return entry, ErrInvalidCredentials
+	}
+
+	user := result.Entries[0]
+	entry.DN = user.DN
+	entry.Login = user.GetAttributeValue(c.cfg.LoginAttribute)
+	entry.Groups = user.GetAttributeValues(c.cfg.GroupAttribute)
+
+	err = conn.Bind(user.DN, password)
+	if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+		return entry, ErrInvalidCredentials
+	}
+	if err != nil {
+		return entry, fmt.Errorf("user bind: %w", err)
+	}
+
+	if c.cfg.GroupBaseDN == "" {
+		return entry, nil
+	}
+
+	// Groups are searched as service account, user may not be allowed to read them.
+	if c.cfg.BindDN != "" {
+		err = conn.Bind(c.cfg.BindDN, c.cfg.BindPassword)
+		if err != nil {
+			return entry, fmt.Errorf("service bind: %w", err)
+		}
+	}
+
+	groups, err := conn.Search(goldap.NewSearchRequest(
+		c.cfg.GroupBaseDN,
+		goldap.ScopeWholeSubtree,
+		goldap.NeverDerefAliases,
+		0,
+		int(c.cfg.Timeout.Seconds()),
+		false,
+		strings.ReplaceAll(c.cfg.GroupFilter, "%s", goldap.EscapeFilter(user.DN)),
+		[]string{c.cfg.GroupNameAttribute},
+		nil,
+	))
+	if err != nil {
+		return entry, fmt.Errorf("group search: %w", err)
+	}
+
+	entry.Groups = make([]string, 0, len(groups.Entries))
+	for _, group := range groups.Entries {
+		entry.Groups = append(entry.Groups, group.GetAttributeValue(c.cfg.GroupNameAttribute))
+	}
+
+	return entry, nil
+}
+
+func (c *Client) dial() (*goldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.cfg.InsecureSkipVerify}
+
+	conn, err := goldap.DialURL(
+		c.cfg.URL,
+		goldap.DialWithDialer(&net.Dialer{Timeout: c.cfg.Timeout}),
+		goldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(c.cfg.Timeout)
+
+	if c.cfg.StartTLS {
+		err = conn.StartTLS(tlsConfig)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
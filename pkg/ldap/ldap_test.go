@@ -0,0 +1,399 @@
+package ldap
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+const (
+	serviceDN       = "cn=service,dc=example,dc=com"
+	servicePassword = "service-password"
+)
+
+// directoryEntry is an entry of stand-in directory. Password is empty for entries
+// one can't bind as.
+type directoryEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// bindRecord is a bind stand-in directory received.
+type bindRecord struct {
+	dn       string
+	password string
+}
+
+// searchRecord is a search stand-in directory received.
+type searchRecord struct {
+	baseDN string
+	filter *ber.Packet
+}
+
+// directory is an in-process LDAP server supporting simple bind and search
+// with equality, presence, and and or filters.
+type directory struct {
+	listener net.Listener
+	entries  []directoryEntry
+
+	mutex    sync.Mutex
+	binds    []bindRecord
+	searches []searchRecord
+}
+
+func newDirectory(t *testing.T, entries ...directoryEntry) *directory {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+
+	d := &directory{listener: listener, entries: entries}
+	go d.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+
+	return d
+}
+
+func (d *directory) url() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+func (d *directory) serve() {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(conn)
+	}
+}
+
+func (d *directory) handle(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		messageID := packet.Children[0].Value
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case goldap.ApplicationBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			d.record(func() { d.binds = append(d.binds, bindRecord{dn: dn, password: password}) })
+
+			code := goldap.LDAPResultSuccess
+			if !d.checkPassword(dn, password) {
+				code = goldap.LDAPResultInvalidCredentials
+			}
+			d.reply(conn, messageID, result(goldap.ApplicationBindResponse, code))
+		case goldap.ApplicationSearchRequest:
+			baseDN := op.Children[0].Value.(string)
+			sizeLimit := int(op.Children[3].Value.(int64))
+			filter := op.Children[6]
+			d.record(func() { d.searches = append(d.searches, searchRecord{baseDN: baseDN, filter: filter}) })
+
+			code := goldap.LDAPResultSuccess
+			var sent int
+			for _, entry := range d.entries {
+				if !strings.HasSuffix(entry.dn, baseDN) || !matches(entry, filter) {
+					continue
+				}
+				if sizeLimit > 0 && sent == sizeLimit {
+					code = goldap.LDAPResultSizeLimitExceeded
+					break
+				}
+				d.reply(conn, messageID, searchEntry(entry))
+				sent++
+			}
+			d.reply(conn, messageID, result(goldap.ApplicationSearchResultDone, code))
+		case goldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (d *directory) record(f func()) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	f()
+}
+
+// checkPassword allows anonymous bind and binds with entry password.
+func (d *directory) checkPassword(dn, password string) bool {
+	if dn == "" && password == "" {
+		return true
+	}
+	if dn == serviceDN {
+		return password == servicePassword
+	}
+
+	for _, entry := range d.entries {
+		if entry.dn == dn {
+			return entry.password != "" && entry.password == password
+		}
+	}
+
+	return false
+}
+
+func (d *directory) reply(conn net.Conn, messageID any, op *ber.Packet) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	packet.AppendChild(op)
+	_, _ = conn.Write(packet.Bytes())
+}
+
+func (d *directory) recordedBinds() []bindRecord {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]bindRecord(nil), d.binds...)
+}
+
+func (d *directory) recordedSearches() []searchRecord {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]searchRecord(nil), d.searches...)
+}
+
+func result(tag ber.Tag, code int) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return op
+}
+
+func searchEntry(entry directoryEntry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "Object Name"))
+
+	attributes := ber.NewSequence("Attributes")
+	for name, values := range entry.attributes {
+		attribute := ber.NewSequence("Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	op.AppendChild(attributes)
+
+	return op
+}
+
+// matches evaluates filter against entry. Unsupported filters match nothing.
+func matches(entry directoryEntry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case goldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matches(entry, child) {
+				return false
+			}
+		}
+		return true
+	case goldap.FilterOr:
+		for _, child := range filter.Children {
+			if matches(entry, child) {
+				return true
+			}
+		}
+		return false
+	case goldap.FilterEqualityMatch:
+		name := filter.Children[0].Data.String()
+		value := filter.Children[1].Data.String()
+		for _, v := range entry.attributes[name] {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case goldap.FilterPresent:
+		return len(entry.attributes[filter.Data.String()]) > 0
+	default:
+		return false
+	}
+}
+
+func person(login, password string, groups ...string) directoryEntry {
+	return directoryEntry{
+		dn:       "uid=" + login + ",ou=people,dc=example,dc=com",
+		password: password,
+		attributes: map[string][]string{
+			"uid":      {login},
+			"memberOf": groups,
+		},
+	}
+}
+
+func newTestClient(d *directory, cfg Config) *Client {
+	cfg.URL = d.url()
+	cfg.BindDN = serviceDN
+	cfg.BindPassword = servicePassword
+	cfg.BaseDN = "dc=example,dc=com"
+	cfg.Timeout = time.Second * 5
+
+	return New(cfg)
+}
+
+func TestAuthenticate(t *testing.T) {
+	d := newDirectory(t, person("alice", "secret", "admins", "staff"))
+	client := newTestClient(d, Config{})
+
+	entry, err := client.Authenticate("alice", "secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if entry.Login != "alice" || entry.DN != "uid=alice,ou=people,dc=example,dc=com" {
+		t.Fatalf("entry = %+v", entry)
+	}
+	if strings.Join(entry.Groups, ",") != "admins,staff" {
+		t.Fatalf("groups = %v, want [admins staff]", entry.Groups)
+	}
+
+	_, err = client.Authenticate("alice", "wrong")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate with wrong password error = %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	_, err = client.Authenticate("bob", "secret")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate of unknown user error = %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestAuthenticateEscapesFilter(t *testing.T) {
+	d := newDirectory(t, person("alice", "secret"))
+	client := newTestClient(d, Config{})
+
+	login := "*)(uid=*"
+	_, err := client.Authenticate(login, "secret")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate error = %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	searches := d.recordedSearches()
+	if len(searches) != 1 {
+		t.Fatalf("searches = %d, want 1", len(searches))
+	}
+	filter := searches[0].filter
+	if filter.Tag != goldap.FilterEqualityMatch || filter.Children[1].Data.String() != login {
+		t.Fatalf("login was not sent as equality assertion value")
+	}
+
+	for _, bind := range d.recordedBinds() {
+		if bind.dn != serviceDN {
+			t.Fatalf("bound as %q", bind.dn)
+		}
+	}
+}
+
+func TestAuthenticateEmptyPassword(t *testing.T) {
+	d := newDirectory(t, person("alice", "secret"))
+	client := newTestClient(d, Config{})
+
+	_, err := client.Authenticate("alice", "")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate error = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if binds := d.recordedBinds(); len(binds) != 0 {
+		t.Fatalf("directory received %d binds, want none", len(binds))
+	}
+}
+
+func TestAuthenticateAmbiguousLogin(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []directoryEntry
+	}{
+		{
+			name: "two entries",
+			entries: []directoryEntry{
+				person("alice", "secret"),
+				{dn: "uid=alice,ou=contractors,dc=example,dc=com", password: "secret", attributes: map[string][]string{"uid": {"alice"}}},
+			},
+		},
+		{
+			name: "size limit exceeded",
+			entries: []directoryEntry{
+				person("alice", "secret"),
+				{dn: "uid=alice,ou=contractors,dc=example,dc=com", password: "secret", attributes: map[string][]string{"uid": {"alice"}}},
+				{dn: "uid=alice,ou=partners,dc=example,dc=com", password: "secret", attributes: map[string][]string{"uid": {"alice"}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDirectory(t, tt.entries...)
+			client := newTestClient(d, Config{})
+
+			_, err := client.Authenticate("alice", "secret")
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("Authenticate error = %v, want %v", err, ErrInvalidCredentials)
+			}
+
+			for _, bind := range d.recordedBinds() {
+				if bind.dn != serviceDN {
+					t.Fatalf("bound as %q with ambiguous login", bind.dn)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticateGroupSearch(t *testing.T) {
+	alice := person("alice", "secret")
+	alice.dn = "cn=Alice (Ops)*,ou=people,dc=example,dc=com"
+	group := func(name string, members ...string) directoryEntry {
+		return directoryEntry{
+			dn:         "cn=" + name + ",ou=groups,dc=example,dc=com",
+			attributes: map[string][]string{"cn": {name}, "member": members},
+		}
+	}
+
+	d := newDirectory(t,
+		alice,
+		group("admins", alice.dn),
+		group("staff", "uid=bob,ou=people,dc=example,dc=com", alice.dn),
+		group("guests", "uid=bob,ou=people,dc=example,dc=com"),
+	)
+	client := newTestClient(d, Config{GroupBaseDN: "ou=groups,dc=example,dc=com"})
+
+	entry, err := client.Authenticate("alice", "secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if strings.Join(entry.Groups, ",") != "admins,staff" {
+		t.Fatalf("groups = %v, want [admins staff]", entry.Groups)
+	}
+
+	searches := d.recordedSearches()
+	if len(searches) != 2 || searches[1].baseDN != "ou=groups,dc=example,dc=com" {
+		t.Fatalf("searches = %+v, want user and group search", searches)
+	}
+	if filter := searches[1].filter; filter.Tag != goldap.FilterEqualityMatch || filter.Children[1].Data.String() != alice.dn {
+		t.Fatal("user DN was not sent as equality assertion value")
+	}
+
+	// Groups are searched as service account after user bind.
+	binds := d.recordedBinds()
+	if len(binds) != 3 || binds[1].dn != alice.dn || binds[2].dn != serviceDN {
+		t.Fatalf("binds = %+v, want service, user, service", binds)
+	}
+}
@@ -0,0 +1,129 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrNoIDToken     = errors.New("token response has no id_token")
+	ErrNonceMismatch = errors.New("id token nonce mismatch")
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// HTTPClient is used for discovery, JWKS and token requests. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+}
+
+// Client runs authorization code flow with PKCE against OpenID provider.
+// Provider metadata is discovered on first use, so app starts while IdP is down.
+type Client struct {
+	cfg Config
+
+	mutex    sync.Mutex
+	oauth    *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+func New(cfg Config) *Client {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"profile", "email"}
+	}
+
+	return &Client{cfg: cfg}
+}
+
+// GenerateVerifier returns new PKCE code verifier.
+func GenerateVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// AuthCodeURL returns provider URL to redirect user to.
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	oauth, _, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return oauth.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+// Exchange redeems authorization code and returns claims of verified ID token.
+func (c *Client) Exchange(ctx context.Context, code, codeVerifier, nonce string) (map[string]any, error) {
+	oauth, verifier, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = c.clientContext(ctx)
+
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, ErrNoIDToken
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	claims := make(map[string]any)
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (c *Client) discover(ctx context.Context) (*oauth2.Config, *gooidc.IDTokenVerifier, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.oauth != nil {
+		return c.oauth, c.verifier, nil
+	}
+
+	// Provider keeps context for JWKS fetching, so request context must not be used here.
+	provider, err := gooidc.NewProvider(c.clientContext(context.WithoutCancel(ctx)), c.cfg.Issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.oauth = &oauth2.Config{
+		ClientID:     c.cfg.ClientID,
+		ClientSecret: c.cfg.ClientSecret,
+		RedirectURL:  c.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{gooidc.ScopeOpenID}, c.cfg.Scopes...),
+	}
+	c.verifier = provider.Verifier(&gooidc.Config{ClientID: c.cfg.ClientID})
+
+	return c.oauth, c.verifier, nil
+}
+
+func (c *Client) clientContext(ctx context.Context) context.Context {
+	if c.cfg.HTTPClient == nil {
+		return ctx
+	}
+
+	return gooidc.ClientContext(ctx, c.cfg.HTTPClient)
+}
@@ -0,0 +1,252 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID = "astral"
+	testKeyID    = "test-key"
+	testCode     = "test-code"
+)
+
+// mockIdP is an OpenID provider serving discovery, JWKS and token endpoints.
+// Token endpoint checks PKCE S256 challenge sent with authorization request.
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mutex     sync.Mutex
+	challenge string
+	// claims overrides default id_token claims.
+	claims map[string]any
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+
+	idp := &mockIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *mockIdP) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                idp.server.URL,
+		"authorization_endpoint":                idp.server.URL + "/authorize",
+		"token_endpoint":                        idp.server.URL + "/token",
+		"jwks_uri":                              idp.server.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (idp *mockIdP) jwks(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]any{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": testKeyID,
+			"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil || r.PostForm.Get("code") != testCode {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	idp.mutex.Lock()
+	challenge := idp.challenge
+	claims := map[string]any{
+		"iss":   idp.server.URL,
+		"sub":   "subject",
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": "nonce",
+	}
+	for k, v := range idp.claims {
+		claims[k] = v
+	}
+	idp.mutex.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if challenge == "" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idp.sign(claims),
+	})
+}
+
+// authorize records PKCE challenge of authorization URL as if user came back from provider.
+func (idp *mockIdP) authorize(t *testing.T, authURL string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+	if method := u.Query().Get("code_challenge_method"); method != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", method)
+	}
+
+	idp.mutex.Lock()
+	idp.challenge = u.Query().Get("code_challenge")
+	idp.mutex.Unlock()
+}
+
+func (idp *mockIdP) setClaims(claims map[string]any) {
+	idp.mutex.Lock()
+	idp.claims = claims
+	idp.mutex.Unlock()
+}
+
+func (idp *mockIdP) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]any{"alg": "RS256", "typ": "JWT", "kid": testKeyID})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signingInput))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, sum[:])
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func newTestClient(idp *mockIdP) *Client {
+	return New(Config{
+		Issuer:      idp.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost/callback",
+		HTTPClient:  idp.server.Client(),
+	})
+}
+
+// startLogin returns code verifier of login which provider already authorized.
+func startLogin(t *testing.T, idp *mockIdP, client *Client, nonce string) string {
+	t.Helper()
+
+	verifier := GenerateVerifier()
+	authURL, err := client.AuthCodeURL(context.Background(), "state", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	idp.authorize(t, authURL)
+
+	return verifier
+}
+
+func TestExchange(t *testing.T) {
+	idp := newMockIdP(t)
+	client := newTestClient(idp)
+	verifier := startLogin(t, idp, client, "nonce")
+
+	claims, err := client.Exchange(context.Background(), testCode, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims["sub"] != "subject" {
+		t.Fatalf("sub = %v, want subject", claims["sub"])
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	idp := newMockIdP(t)
+	client := newTestClient(idp)
+	startLogin(t, idp, client, "nonce")
+
+	_, err := client.Exchange(context.Background(), testCode, GenerateVerifier(), "nonce")
+	if err == nil {
+		t.Fatal("Exchange with other code verifier succeeded")
+	}
+}
+
+func TestExchangeNonceMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	client := newTestClient(idp)
+	verifier := startLogin(t, idp, client, "nonce")
+
+	_, err := client.Exchange(context.Background(), testCode, verifier, "other-nonce")
+	if !errors.Is(err, ErrNonceMismatch) {
+		t.Fatalf("Exchange error = %v, want %v", err, ErrNonceMismatch)
+	}
+}
+
+func TestExchangeRejectsIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]any
+	}{
+		{
+			name: "expired",
+			claims: map[string]any{
+				"iat": time.Now().Add(-time.Hour).Unix(),
+				"exp": time.Now().Add(-time.Minute).Unix(),
+			},
+		},
+		{
+			name:   "wrong audience",
+			claims: map[string]any{"aud": "other-client"},
+		},
+		{
+			name:   "wrong issuer",
+			claims: map[string]any{"iss": "https://attacker.example"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			idp.setClaims(tt.claims)
+			client := newTestClient(idp)
+			verifier := startLogin(t, idp, client, "nonce")
+
+			_, err := client.Exchange(context.Background(), testCode, verifier, "nonce")
+			if err == nil {
+				t.Fatal("Exchange accepted invalid id token")
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package webauthn
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	gowebauthn "github.com/go-webauthn/webauthn/webauthn"
+)
+
+var ErrCredentialCloned = errors.New("credential sign counter did not increase")
+
+type Config struct {
+	// RPID is a relying party ID, usually site domain without scheme and port.
+	RPID          string
+	RPDisplayName string
+	// RPOrigins are origins allowed to run ceremonies, like https://astral.example.com.
+	RPOrigins []string
+	Timeout   time.Duration
+}
+
+// Credential is a public key credential record to be stored.
+type Credential struct {
+	ID              []byte
+	PublicKey       []byte
+	AttestationType string
+	Transports      []string
+	AAGUID          []byte
+	SignCount       uint32
+	BackupEligible  bool
+	BackupState     bool
+}
+
+// User is a credentials owner. ID is a user handle kept by authenticator
+// and returned on discoverable login.
+type User struct {
+	ID          []byte
+	Name        string
+	Credentials []Credential
+}
+
+// Client runs registration and login ceremonies. Sessions are returned serialized
+// to be kept by caller between begin and finish steps.
+type Client struct {
+	webAuthn *gowebauthn.WebAuthn
+}
+
+func New(cfg Config) (*Client, error) {
+	webAuthn, err := gowebauthn.New(&gowebauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		Timeouts: gowebauthn.TimeoutsConfig{
+			Login:        gowebauthn.TimeoutConfig{Enforce: true, Timeout: cfg.Timeout, TimeoutUVD: cfg.Timeout},
+			Registration: gowebauthn.TimeoutConfig{Enforce: true, Timeout: cfg.Timeout, TimeoutUVD: cfg.Timeout},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{webAuthn: webAuthn}, nil
+}
+
+// BeginRegistration returns credential creation options for browser. Discoverable credential
+// with user verification is requested, credentials user already has are excluded.
+func (c *Client) BeginRegistration(user User) (any, []byte, error) {
+	u := webAuthnUser(user)
+	requireResidentKey := true
+
+	options, session, err := c.webAuthn.BeginRegistration(
+		u,
+		gowebauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			RequireResidentKey: &requireResidentKey,
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		}),
+		gowebauthn.WithExclusions(gowebauthn.Credentials(u.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return options, data, nil
+}
+
+// FinishRegistration verifies attestation response and returns new credential.
+func (c *Client) FinishRegistration(user User, session, response []byte) (Credential, error) {
+	var sessionData gowebauthn.SessionData
+	err := json.Unmarshal(session, &sessionData)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	credential, err := c.webAuthn.CreateCredential(webAuthnUser(user), sessionData, parsed)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	return fromWebAuthnCredential(*credential), nil
+}
+
+// BeginLogin returns assertion options for browser. User verification is required, so passkey
+// is both possession and knowledge or biometric factor. Login is discoverable if user is nil,
+// so authenticator chooses account by itself.
+func (c *Client) BeginLogin(user *User) (any, []byte, error) {
+	var options *protocol.CredentialAssertion
+	var session *gowebauthn.SessionData
+	var err error
+
+	if user == nil {
+		options, session, err = c.webAuthn.BeginDiscoverableLogin(
+			gowebauthn.WithUserVerification(protocol.VerificationRequired),
+		)
+	} else {
+		options, session, err = c.webAuthn.BeginLogin(
+			webAuthnUser(*user),
+			gowebauthn.WithUserVerification(protocol.VerificationRequired),
+		)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return options, data, nil
+}
+
+// FinishLogin verifies assertion response and returns its user with updated credential.
+// findUser loads user by handle. ErrCredentialCloned is returned if sign counter did not increase.
+func (c *Client) FinishLogin(session, response []byte, findUser func(userHandle []byte) (User, error)) (User, Credential, error) {
+	var sessionData gowebauthn.SessionData
+	err := json.Unmarshal(session, &sessionData)
+	if err != nil {
+		return User{}, Credential{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return User{}, Credential{}, err
+	}
+
+	var user gowebauthn.User
+	var credential *gowebauthn.Credential
+
+	if len(sessionData.UserID) == 0 {
+		user, credential, err = c.webAuthn.ValidatePasskeyLogin(func(_, userHandle []byte) (gowebauthn.User, error) {
+			u, err := findUser(userHandle)
+			return webAuthnUser(u), err
+		}, sessionData, parsed)
+	} else {
+		var u User
+		u, err = findUser(sessionData.UserID)
+		if err != nil {
+			return User{}, Credential{}, err
+		}
+
+		user = webAuthnUser(u)
+		credential, err = c.webAuthn.ValidateLogin(user, sessionData, parsed)
+	}
+	if err != nil {
+		return User{}, Credential{}, err
+	}
+	if credential.Authenticator.CloneWarning {
+		return User{}, Credential{}, ErrCredentialCloned
+	}
+
+	return User(user.(webAuthnUser)), fromWebAuthnCredential(*credential), nil
+}
+
+type webAuthnUser User
+
+func (u webAuthnUser) WebAuthnID() []byte {
+	return u.ID
+}
+
+func (u webAuthnUser) WebAuthnName() string {
+	return u.Name
+}
+
+func (u webAuthnUser) WebAuthnDisplayName() string {
+	return u.Name
+}
+
+func (u webAuthnUser) WebAuthnCredentials() []gowebauthn.Credential {
+	credentials := make([]gowebauthn.Credential, 0, len(u.Credentials))
+	for _, credential := range u.Credentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(credential.Transports))
+		for _, transport := range credential.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		credentials = append(credentials, gowebauthn.Credential{
+			ID:              credential.ID,
+			PublicKey:       credential.PublicKey,
+			AttestationType: credential.AttestationType,
+			Transport:       transports,
+			Flags: gowebauthn.CredentialFlags{
+				BackupEligible: credential.BackupEligible,
+				BackupState:    credential.BackupState,
+			},
+			Authenticator: gowebauthn.Authenticator{
+				AAGUID:    credential.AAGUID,
+				SignCount: credential.SignCount,
+			},
+		})
+	}
+
+	return credentials
+}
+
+func fromWebAuthnCredential(credential gowebauthn.Credential) Credential {
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	return Credential{
+		ID:              credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+}
@@ -0,0 +1,172 @@
+package webauthn
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/srgklmv/astral/pkg/webauthn/webauthntest"
+)
+
+const testOrigin = "https://astral.example.com"
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	client, err := New(Config{
+		RPID:          "astral.example.com",
+		RPDisplayName: "astral",
+		RPOrigins:     []string{testOrigin},
+		Timeout:       time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return client
+}
+
+// register runs registration ceremony and returns user with new credential.
+func register(t *testing.T, client *Client, authenticator *webauthntest.Authenticator, user User) (User, error) {
+	t.Helper()
+
+	options, session, err := client.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+
+	response, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatalf("authenticator Register: %v", err)
+	}
+
+	credential, err := client.FinishRegistration(user, session, response)
+	if err != nil {
+		return user, err
+	}
+	user.Credentials = append(user.Credentials, credential)
+
+	return user, nil
+}
+
+// login runs login ceremony, discoverable one if user is nil, against stored user.
+func login(t *testing.T, client *Client, authenticator *webauthntest.Authenticator, user *User, stored User) (Credential, error) {
+	t.Helper()
+
+	options, session, err := client.BeginLogin(user)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+
+	response, err := authenticator.Login(options)
+	if err != nil {
+		t.Fatalf("authenticator Login: %v", err)
+	}
+
+	_, credential, err := client.FinishLogin(session, response, func(userHandle []byte) (User, error) {
+		if !bytes.Equal(userHandle, stored.ID) {
+			return User{}, errors.New("unknown user handle")
+		}
+		return stored, nil
+	})
+
+	return credential, err
+}
+
+func testUser() User {
+	return User{ID: bytes.Repeat([]byte{7}, 32), Name: "alice"}
+}
+
+func TestRegistrationAndLogin(t *testing.T) {
+	client := newTestClient(t)
+	authenticator := webauthntest.New(testOrigin)
+
+	user, err := register(t, client, authenticator, testUser())
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		user *User
+	}{
+		{name: "discoverable", user: nil},
+		{name: "with login", user: &user},
+	} {
+		credential, err := login(t, client, authenticator, tt.user, user)
+		if err != nil {
+			t.Fatalf("%s: FinishLogin: %v", tt.name, err)
+		}
+		if !bytes.Equal(credential.ID, user.Credentials[0].ID) {
+			t.Fatalf("%s: logged in with other credential", tt.name)
+		}
+		user.Credentials[0].SignCount = credential.SignCount
+	}
+}
+
+func TestRegistrationWithoutUserVerification(t *testing.T) {
+	client := newTestClient(t)
+	authenticator := webauthntest.New(testOrigin)
+	authenticator.SkipUserVerification = true
+
+	_, err := register(t, client, authenticator, testUser())
+	if err == nil {
+		t.Fatal("registration without user verification succeeded")
+	}
+}
+
+func TestLoginWithoutUserVerification(t *testing.T) {
+	client := newTestClient(t)
+	authenticator := webauthntest.New(testOrigin)
+
+	user, err := register(t, client, authenticator, testUser())
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+
+	authenticator.SkipUserVerification = true
+	_, err = login(t, client, authenticator, nil, user)
+	if err == nil {
+		t.Fatal("login without user verification succeeded")
+	}
+}
+
+func TestLoginWrongOrigin(t *testing.T) {
+	client := newTestClient(t)
+	authenticator := webauthntest.New(testOrigin)
+
+	user, err := register(t, client, authenticator, testUser())
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+
+	authenticator.Origin = "https://phishing.example.com"
+	_, err = login(t, client, authenticator, nil, user)
+	if err == nil {
+		t.Fatal("login from other origin succeeded")
+	}
+}
+
+func TestLoginClonedCredential(t *testing.T) {
+	client := newTestClient(t)
+	authenticator := webauthntest.New(testOrigin)
+
+	user, err := register(t, client, authenticator, testUser())
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+
+	credential, err := login(t, client, authenticator, nil, user)
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	user.Credentials[0].SignCount = credential.SignCount
+
+	// Clone made before the login reports the same counter again.
+	authenticator.Credentials()[0].SignCount = 0
+	_, err = login(t, client, authenticator, nil, user)
+	if !errors.Is(err, ErrCredentialCloned) {
+		t.Fatalf("FinishLogin error = %v, want %v", err, ErrCredentialCloned)
+	}
+}
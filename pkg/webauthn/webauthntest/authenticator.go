@@ -0,0 +1,220 @@
+// Package webauthntest provides software passkey authenticator for WebAuthn ceremony tests.
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+var ErrNoCredential = errors.New("authenticator has no credential for request")
+
+// Authenticator is a software authenticator creating ES256 discoverable credentials
+// with "none" attestation.
+type Authenticator struct {
+	// Origin is put into client data as if ceremony ran on this page.
+	Origin string
+	// SkipUserVerification clears UV flag, like security key used without PIN.
+	SkipUserVerification bool
+
+	credentials []*Credential
+}
+
+// Credential is a credential kept by authenticator.
+type Credential struct {
+	ID         []byte
+	RPID       string
+	UserHandle []byte
+	SignCount  uint32
+
+	key *ecdsa.PrivateKey
+}
+
+func New(origin string) *Authenticator {
+	return &Authenticator{Origin: origin}
+}
+
+// Credentials returns credentials created by authenticator. They may be changed
+// to simulate cloned or reset authenticator.
+func (a *Authenticator) Credentials() []*Credential {
+	return a.credentials
+}
+
+// Register creates credential for creation options and returns attestation response JSON.
+func (a *Authenticator) Register(options any) ([]byte, error) {
+	var creation protocol.CredentialCreation
+	err := convert(options, &creation)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, _ := creation.Response.User.ID.(string)
+	userHandle, err := base64.RawURLEncoding.DecodeString(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	credential := &Credential{
+		ID:         make([]byte, 16),
+		RPID:       creation.Response.RelyingParty.ID,
+		UserHandle: userHandle,
+		key:        key,
+	}
+	_, err = rand.Read(credential.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: key.X.FillBytes(make([]byte, 32)),
+		YCoord: key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authenticatorData(credential, protocol.FlagAttestedCredentialData)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(credential.ID)))
+	authData = append(authData, credential.ID...)
+	authData = append(authData, publicKey...)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clientData, err := a.clientData(protocol.CreateCeremony, creation.Response.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	a.credentials = append(a.credentials, credential)
+
+	return json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(credential.ID),
+		"rawId": base64.RawURLEncoding.EncodeToString(credential.ID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+			"transports":        []string{"internal"},
+		},
+	})
+}
+
+// Login signs assertion options with the newest matching credential, increasing its
+// sign counter, and returns assertion response JSON.
+func (a *Authenticator) Login(options any) ([]byte, error) {
+	var assertion protocol.CredentialAssertion
+	err := convert(options, &assertion)
+	if err != nil {
+		return nil, err
+	}
+
+	credential := a.find(assertion.Response.RelyingPartyID, assertion.Response.AllowedCredentials)
+	if credential == nil {
+		return nil, ErrNoCredential
+	}
+
+	credential.SignCount++
+	authData := a.authenticatorData(credential, 0)
+
+	clientData, err := a.clientData(protocol.AssertCeremony, assertion.Response.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(bytes.Clone(authData), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, credential.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(credential.ID),
+		"rawId": base64.RawURLEncoding.EncodeToString(credential.ID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString(credential.UserHandle),
+		},
+	})
+}
+
+func (a *Authenticator) find(rpID string, allowed []protocol.CredentialDescriptor) *Credential {
+	for i := len(a.credentials) - 1; i >= 0; i-- {
+		credential := a.credentials[i]
+		if credential.RPID != rpID {
+			continue
+		}
+		if len(allowed) == 0 {
+			return credential
+		}
+		for _, descriptor := range allowed {
+			if bytes.Equal(descriptor.CredentialID, credential.ID) {
+				return credential
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *Authenticator) authenticatorData(credential *Credential, flags protocol.AuthenticatorFlags) []byte {
+	flags |= protocol.FlagUserPresent
+	if !a.SkipUserVerification {
+		flags |= protocol.FlagUserVerified
+	}
+
+	rpIDHash := sha256.Sum256([]byte(credential.RPID))
+	data := append(rpIDHash[:], byte(flags))
+
+	return binary.BigEndian.AppendUint32(data, credential.SignCount)
+}
+
+func (a *Authenticator) clientData(ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+// convert copies options of any type into protocol type through JSON, as browser gets them.
+func convert(options any, v any) error {
+	data, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}